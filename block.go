@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tkrehbiel/activitylace/server"
+)
+
+const blockUsage = "usage: activitylace block [list | add <actor id, @user@host or domain> [reason] | remove <actor id, @user@host or domain>]"
+
+// blockCommand lists, adds and removes blocked actors and domains
+func blockCommand(cfg server.Config, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		m, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		blocks, err := m.Blocks()
+		if err != nil {
+			return err
+		}
+		for _, block := range blocks {
+			fmt.Printf("%s [%s] %s\n", block.ID, block.Type, block.Reason)
+		}
+		return nil
+	case "add":
+		if len(args) < 2 {
+			return errors.New(blockUsage)
+		}
+		m, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		return m.Block(args[1], strings.Join(args[2:], " "))
+	case "remove":
+		if len(args) != 2 {
+			return errors.New(blockUsage)
+		}
+		m, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		return m.Unblock(args[1])
+	default:
+		return errors.New(blockUsage)
+	}
+}
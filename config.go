@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tkrehbiel/activitylace/server"
+)
+
+const configUsage = "usage: activitylace config validate"
+
+// configCommand checks the config file, with environment overrides, and reports every problem in it
+func configCommand(filename string, args []string) error {
+	if len(args) != 1 || args[0] != "validate" {
+		return errors.New(configUsage)
+	}
+	cfg, err := server.LoadConfig(filename)
+	if err != nil {
+		return err
+	}
+	problems := cfg.Validate()
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filename, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found in %s", len(problems), filename)
+	}
+	fmt.Printf("%s is valid\n", filename)
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tkrehbiel/activitylace/server"
+)
+
+const dbUsage = "usage: activitylace db migrate"
+
+// dbCommand creates or updates the database tables without starting the server
+func dbCommand(cfg server.Config, args []string) error {
+	if len(args) != 1 || args[0] != "migrate" {
+		return errors.New(dbUsage)
+	}
+	names, err := server.Migrate(cfg)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Printf("migrated %s\n", name)
+	}
+	return nil
+}
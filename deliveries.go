@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+const deliveriesUsage = "usage: activitylace deliveries [-status pending,delivered,failed] [-limit n] [list | retry <delivery id>]"
+
+// deliveriesCommand shows the note delivery log and retries failed deliveries
+func deliveriesCommand(cfg server.Config, args []string) error {
+	flags := flag.NewFlagSet("deliveries", flag.ContinueOnError)
+	status := flags.String("status", storage.DeliveryFailed, "comma-separated statuses to list, or all")
+	limit := flags.Int("limit", 50, "how many deliveries to list")
+	if err := flags.Parse(args); err != nil {
+		return errors.New(deliveriesUsage)
+	}
+	args = flags.Args()
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		statuses := splitList(*status)
+		if *status == "all" {
+			statuses = nil
+		}
+		m, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		deliveries, err := m.Deliveries(statuses, *limit)
+		if err != nil {
+			return err
+		}
+		for _, d := range deliveries {
+			fmt.Printf("%s [%s] %s\n", d.ID, d.Status, d.UpdatedAt.Format(time.RFC3339))
+			fmt.Printf("  %s to %s, %d attempts\n", d.ObjectID, d.Target, d.Attempts)
+			if d.LastError != "" {
+				fmt.Printf("  %s\n", d.LastError)
+			}
+		}
+		return nil
+	case "retry":
+		if len(args) != 2 {
+			return errors.New(deliveriesUsage)
+		}
+		m, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		return m.RetryDelivery(args[1])
+	default:
+		return errors.New(deliveriesUsage)
+	}
+}
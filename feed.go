@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/tkrehbiel/activitylace/server"
+)
+
+const feedUsage = "usage: activitylace feed [-user name] check"
+
+// feedCommand checks a user's feed for new items now
+func feedCommand(cfg server.Config, args []string) error {
+	flags := flag.NewFlagSet("feed", flag.ContinueOnError)
+	name := flags.String("user", "", "local user name")
+	if err := flags.Parse(args); err != nil {
+		return errors.New(feedUsage)
+	}
+	args = flags.Args()
+	if len(args) != 1 || args[0] != "check" {
+		return errors.New(feedUsage)
+	}
+	user, err := userName(cfg, *name)
+	if err != nil {
+		return err
+	}
+
+	m, err := connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	result, err := m.CheckFeed(user)
+	if err != nil {
+		return err
+	}
+	fmt.Println(result)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/tkrehbiel/activitylace/server"
+)
+
+const followersUsage = "usage: activitylace followers [-user name] [list | remove <actor id or @user@host>]"
+
+// followersCommand lists a user's followers or removes one
+func followersCommand(cfg server.Config, args []string) error {
+	flags := flag.NewFlagSet("followers", flag.ContinueOnError)
+	name := flags.String("user", "", "local user name")
+	if err := flags.Parse(args); err != nil {
+		return errors.New(followersUsage)
+	}
+	args = flags.Args()
+	user, err := userName(cfg, *name)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		m, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		followers, err := m.Followers(user)
+		if err != nil {
+			return err
+		}
+		for _, follower := range followers {
+			fmt.Printf("%s [%s]\n", follower.ID, follower.RequestStatus)
+		}
+		return nil
+	case "remove":
+		if len(args) != 2 {
+			return errors.New(followersUsage)
+		}
+		m, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		return m.RemoveFollower(user, args[1])
+	default:
+		return errors.New(followersUsage)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/tkrehbiel/activitylace
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/glebarez/sqlite v1.5.0
 	github.com/go-fed/httpsig v1.1.0
 	github.com/google/uuid v1.3.0
@@ -11,6 +12,8 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/mmcdole/gofeed v1.1.3
 	github.com/stretchr/testify v1.8.1
+	golang.org/x/net v0.0.0-20201021035429-f5854403a974
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/gorm v1.24.2
 )
 
@@ -30,10 +33,8 @@ require (
 	github.com/remyoudompheng/bigfft v0.0.0-20200410134404-eec4a21b6bb0 // indirect
 	github.com/stretchr/objx v0.5.0 // indirect
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
-	golang.org/x/net v0.0.0-20201021035429-f5854403a974 // indirect
 	golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab // indirect
 	golang.org/x/text v0.3.3 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	modernc.org/libc v1.19.0 // indirect
 	modernc.org/mathutil v1.5.0 // indirect
 	modernc.org/memory v1.4.0 // indirect
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/tkrehbiel/activitylace/server"
+	"github.com/tkrehbiel/activitylace/server/page"
+)
+
+const keysUsage = `usage: activitylace [flags] keys <command> <user> [args]
+
+commands:
+  show <user>                  print the public key and key id
+  export <user> [private]      print the public (default) or private key PEM
+  import <user> <private.pem>  replace the user's keypair with an existing private key
+  rotate <user>                generate a new keypair, keeping the old one as .old`
+
+// keysCommand manages user signing keys without starting the server
+func keysCommand(cfg server.Config, args []string) error {
+	if len(args) < 2 {
+		return errors.New(keysUsage)
+	}
+	command, name := args[0], args[1]
+
+	found := false
+	for _, u := range cfg.Users {
+		if u.Name != name {
+			continue
+		}
+		found = true
+		if u.PrivKeyFile != "" {
+			return fmt.Errorf("user %s uses the configured key file [%s], manage it directly", name, u.PrivKeyFile)
+		}
+	}
+	if !found {
+		return fmt.Errorf("no user named %s in config", name)
+	}
+
+	keys := server.NewKeyStore(cfg.Server.KeyDir)
+	switch command {
+	case "show":
+		_, pub, err := keys.Load(name)
+		if err != nil {
+			return noKey(name, err)
+		}
+		if u, err := url.Parse(cfg.URL); err == nil {
+			fmt.Printf("key id: %s\n", page.NewMetaData(u).NewUserMetaData(name).UserPublicKeyID)
+		}
+		fmt.Print(pub)
+	case "export":
+		if len(args) > 2 && args[2] == "private" {
+			b, err := keys.PrivateKeyPEM(name)
+			if err != nil {
+				return noKey(name, err)
+			}
+			os.Stdout.Write(b)
+			return nil
+		}
+		_, pub, err := keys.Load(name)
+		if err != nil {
+			return noKey(name, err)
+		}
+		fmt.Print(pub)
+	case "import":
+		if len(args) < 3 {
+			return errors.New(keysUsage)
+		}
+		b, err := os.ReadFile(args[2])
+		if err != nil {
+			return err
+		}
+		return printResult(keys.Import(name, b))
+	case "rotate":
+		// Remote servers refetch our actor when a signature fails to verify,
+		// so they should pick up the new key on their own.
+		return printResult(keys.Rotate(name))
+	default:
+		return errors.New(keysUsage)
+	}
+	return nil
+}
+
+// noKey explains a missing keypair rather than reporting a missing file
+func noKey(name string, err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("user %s has no keypair yet, one is generated when the server starts or by keys rotate", name)
+	}
+	return err
+}
+
+func printResult(_ crypto.PrivateKey, pub string, err error) error {
+	if err != nil {
+		return err
+	}
+	fmt.Print(pub)
+	return nil
+}
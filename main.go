@@ -4,32 +4,26 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/tkrehbiel/activitylace/server"
 	"github.com/tkrehbiel/activitylace/server/telemetry"
 )
 
-func readConfig(filename string) server.Config {
-	var cfg server.Config
-	b, err := os.ReadFile(filename)
-	if err != nil {
-		telemetry.Error(err, "opening config [%s]", filename)
-	} else {
-		c, err := server.ReadConfig(b)
-		if err != nil {
-			telemetry.Error(err, "parsing config [%s]", filename)
-		}
-		cfg = c
+// fail prints errors and exits
+func fail(errs ...error) {
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
 	}
-
-	return cfg
+	os.Exit(1)
 }
 
 func main() {
-	configFile := flag.String("config", "config.json", "config json file")
+	configFile := flag.String("config", "config.json", "config file, json, yaml or toml")
 	host := flag.String("host", "", "this hostname")
 	pubCert := flag.String("cert", "", "public certificate")
 	privCert := flag.String("key", "", "private key")
@@ -37,32 +31,101 @@ func main() {
 
 	flag.Parse()
 
-	telemetry.Log("starting activitylace")
+	if flag.Arg(0) == "config" {
+		// Reports problems itself
+		if err := configCommand(*configFile, flag.Args()[1:]); err != nil {
+			fail(err)
+		}
+		return
+	}
 
-	cfg := readConfig(*configFile)
-	if *host != "" {
-		cfg.Server.HostName = *host
+	// Flags override the config file, including when it's reloaded
+	load := func() (server.Config, error) {
+		cfg, err := server.LoadConfig(*configFile)
+		if err != nil {
+			return cfg, err
+		}
+		if *host != "" {
+			cfg.Server.HostName = *host
+		}
+		if *port != 0 {
+			cfg.Server.Port = *port
+		}
+		if *pubCert != "" {
+			cfg.Server.Certificate = *pubCert
+		}
+		if *privCert != "" {
+			cfg.Server.PrivateKey = *privCert
+		}
+		return cfg, nil
 	}
-	if *port != 0 {
-		cfg.Server.Port = *port
+
+	cfg, err := load()
+	if err != nil {
+		fail(err)
 	}
-	if *pubCert != "" {
-		cfg.Server.Certificate = *pubCert
+	if problems := cfg.Validate(); len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%s has problems:\n", *configFile)
+		fail(problems...)
 	}
-	if *privCert != "" {
-		cfg.Server.PrivateKey = *privCert
+
+	if flag.NArg() > 0 && flag.Arg(0) != "serve" {
+		// Run a management command instead of the server
+		switch flag.Arg(0) {
+		case "db":
+			err = dbCommand(cfg, flag.Args()[1:])
+		case "followers":
+			err = followersCommand(cfg, flag.Args()[1:])
+		case "notes":
+			err = notesCommand(cfg, flag.Args()[1:])
+		case "deliveries":
+			err = deliveriesCommand(cfg, flag.Args()[1:])
+		case "block":
+			err = blockCommand(cfg, flag.Args()[1:])
+		case "feed":
+			err = feedCommand(cfg, flag.Args()[1:])
+		case "keys":
+			err = keysCommand(cfg, flag.Args()[1:])
+		case "resolve":
+			err = resolveCommand(cfg, flag.Args()[1:])
+		case "reprocess":
+			err = reprocessCommand(cfg, flag.Args()[1:])
+		case "move":
+			err = moveCommand(cfg, flag.Args()[1:])
+		case "reports":
+			err = reportsCommand(cfg, flag.Args()[1:])
+		case "replies":
+			err = repliesCommand(cfg, flag.Args()[1:])
+		default:
+			err = fmt.Errorf("unknown command %s", flag.Arg(0))
+		}
+		if err != nil {
+			fail(err)
+		}
+		return
 	}
 
+	telemetry.Log("starting activitylace")
+
 	svc := server.NewService(cfg)
+	svc.SetConfigSource(load)
 
 	// Startup the service to listen for http requests
 	svc.Start(context.Background())
 
-	// Wait for ^C
+	// Reload on SIGHUP, wait for ^C
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGHUP)
 
-	<-c
+	for sig := range c {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		telemetry.Log("reloading %s", *configFile)
+		if _, err := svc.ReloadConfig(); err != nil {
+			telemetry.Error(err, "reloading %s", *configFile)
+		}
+	}
 	telemetry.Log("stopping activitylace")
 
 	// Shut down the service
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+// manager is what the admin commands need, whether the server is running or not
+type manager interface {
+	Followers(user string) ([]storage.Follow, error)
+	RemoveFollower(user string, id string) error
+	Notes(user string, limit int) ([]storage.Note, error)
+	ResendNote(user string, id string) (int, error)
+	Deliveries(statuses []string, limit int) ([]storage.Delivery, error)
+	RetryDelivery(id string) error
+	Blocks() ([]storage.Block, error)
+	Block(target string, reason string) error
+	Unblock(target string) error
+	CheckFeed(user string) (string, error)
+	Close()
+}
+
+// connect returns a manager that goes through the admin api if the server is running,
+// or works on the databases directly if it isn't
+func connect(cfg server.Config) (manager, error) {
+	if api := newAPIManager(cfg); api != nil {
+		running, err := api.running()
+		if err != nil {
+			return nil, err
+		}
+		if running {
+			return api, nil
+		}
+	}
+	return newLocalManager(cfg), nil
+}
+
+// userName returns the user a command is about, which may be left out when there's only one
+func userName(cfg server.Config, name string) (string, error) {
+	if name != "" {
+		return name, nil
+	}
+	if len(cfg.Users) == 1 {
+		return cfg.Users[0].Name, nil
+	}
+	return "", errors.New("-user is required when there's more than one user")
+}
+
+// localManager works on the databases while the server is stopped
+type localManager struct {
+	*server.ActivityService
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newLocalManager(cfg server.Config) *localManager {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	return &localManager{
+		ActivityService: server.NewService(cfg),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+}
+
+func (m *localManager) ResendNote(user string, id string) (n int, err error) {
+	m.SendNow(m.ctx, func() {
+		n, err = m.ActivityService.ResendNote(user, id)
+	})
+	return n, err
+}
+
+func (m *localManager) RetryDelivery(id string) (err error) {
+	m.SendNow(m.ctx, func() {
+		err = m.ActivityService.RetryDelivery(id)
+	})
+	return err
+}
+
+func (m *localManager) RemoveFollower(user string, id string) error {
+	return m.ActivityService.RemoveFollower(m.ctx, user, id)
+}
+
+func (m *localManager) Block(target string, reason string) error {
+	return m.ActivityService.Block(m.ctx, target, reason)
+}
+
+func (m *localManager) Unblock(target string) error {
+	return m.ActivityService.Unblock(m.ctx, target)
+}
+
+func (m *localManager) CheckFeed(user string) (string, error) {
+	n, err := m.PollFeed(m.ctx, user)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("found %d new items", n), nil
+}
+
+func (m *localManager) Close() {
+	m.Stop(m.ctx)
+	m.cancel()
+}
+
+// apiManager uses the admin api of the running server
+type apiManager struct {
+	base   string
+	token  string
+	client http.Client
+}
+
+// newAPIManager returns nil if the admin api isn't configured
+func newAPIManager(cfg server.Config) *apiManager {
+	base := cfg.AdminURL()
+	if base == "" {
+		return nil
+	}
+	return &apiManager{
+		base:   base,
+		token:  cfg.AdminToken(),
+		client: http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// running returns true if the server answers admin requests
+func (m *apiManager) running() (bool, error) {
+	err := m.do(http.MethodGet, "/counters", nil, nil)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// nothing listening
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// do makes an admin api request and decodes the json response into v
+func (m *apiManager) do(method string, path string, query url.Values, v any) error {
+	target := m.base + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	r, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", "Bearer "+m.token)
+	resp, err := m.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return errors.New(apiErr.Error)
+		}
+		return fmt.Errorf("admin api returned %s", resp.Status)
+	}
+	if v != nil {
+		return json.Unmarshal(body, v)
+	}
+	return nil
+}
+
+func (m *apiManager) Followers(user string) (followers []storage.Follow, err error) {
+	err = m.do(http.MethodGet, "/followers", url.Values{"user": {user}}, &followers)
+	return followers, err
+}
+
+func (m *apiManager) RemoveFollower(user string, id string) error {
+	return m.do(http.MethodDelete, "/followers", url.Values{"user": {user}, "id": {id}}, nil)
+}
+
+func (m *apiManager) Notes(user string, limit int) (notes []storage.Note, err error) {
+	err = m.do(http.MethodGet, "/notes", url.Values{"user": {user}, "limit": {strconv.Itoa(limit)}}, &notes)
+	return notes, err
+}
+
+func (m *apiManager) ResendNote(user string, id string) (int, error) {
+	var queued map[string]int
+	err := m.do(http.MethodPost, "/notes/resend", url.Values{"user": {user}, "id": {id}}, &queued)
+	return queued["queued"], err
+}
+
+func (m *apiManager) Deliveries(statuses []string, limit int) (deliveries []storage.Delivery, err error) {
+	err = m.do(http.MethodGet, "/deliveries", url.Values{"status": statuses, "limit": {strconv.Itoa(limit)}}, &deliveries)
+	return deliveries, err
+}
+
+func (m *apiManager) RetryDelivery(id string) error {
+	return m.do(http.MethodPost, "/deliveries/retry", url.Values{"id": {id}}, nil)
+}
+
+func (m *apiManager) Blocks() (blocks []storage.Block, err error) {
+	err = m.do(http.MethodGet, "/blocks", nil, &blocks)
+	return blocks, err
+}
+
+func (m *apiManager) Block(target string, reason string) error {
+	return m.do(http.MethodPost, "/blocks", url.Values{"target": {target}, "reason": {reason}}, nil)
+}
+
+func (m *apiManager) Unblock(target string) error {
+	return m.do(http.MethodDelete, "/blocks", url.Values{"target": {target}}, nil)
+}
+
+func (m *apiManager) CheckFeed(user string) (string, error) {
+	if err := m.do(http.MethodPost, "/feeds/check", url.Values{"user": {user}}, nil); err != nil {
+		return "", err
+	}
+	return "the running server is checking the feed", nil
+}
+
+func (m *apiManager) Close() {}
+
+// splitList splits a comma-separated flag value
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
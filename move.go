@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server"
+)
+
+// moveCommand moves a local user to another account, notifying all followers
+func moveCommand(cfg server.Config, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: activitylace move <user> <new actor id or @user@host>")
+	}
+
+	svc := server.NewService(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	defer svc.Stop(ctx)
+
+	n, err := svc.Move(context.Background(), args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("sent move to %d followers\n", n)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server"
+)
+
+const notesUsage = "usage: activitylace notes [-user name] [-limit n] [list | resend <note id>]"
+
+// notesCommand lists a user's notes or sends one to all followers again
+func notesCommand(cfg server.Config, args []string) error {
+	flags := flag.NewFlagSet("notes", flag.ContinueOnError)
+	name := flags.String("user", "", "local user name")
+	limit := flags.Int("limit", 20, "how many notes to list")
+	if err := flags.Parse(args); err != nil {
+		return errors.New(notesUsage)
+	}
+	args = flags.Args()
+	user, err := userName(cfg, *name)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		m, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		notes, err := m.Notes(user, *limit)
+		if err != nil {
+			return err
+		}
+		for _, note := range notes {
+			fmt.Printf("%s %s\n", note.ID, note.Published.Format(time.RFC3339))
+			if note.URL != "" && note.URL != note.ID {
+				fmt.Printf("  %s\n", note.URL)
+			}
+		}
+		return nil
+	case "resend":
+		if len(args) != 2 {
+			return errors.New(notesUsage)
+		}
+		m, err := connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		n, err := m.ResendNote(user, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("resent to %d followers\n", n)
+		return nil
+	default:
+		return errors.New(notesUsage)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+const repliesUsage = "usage: activitylace replies [list [visible|pending|hidden|rejected|all] | approve|hide|reject|pend <id>]"
+
+// repliesCommand lists replies for moderation and changes their state
+func repliesCommand(cfg server.Config, args []string) error {
+	svc := server.NewService(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	defer svc.Stop(ctx)
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	actions := map[string]string{
+		"approve": storage.ReplyVisible,
+		"hide":    storage.ReplyHidden,
+		"reject":  storage.ReplyRejected,
+		"pend":    storage.ReplyPending,
+	}
+	switch args[0] {
+	case "list":
+		states := []string{storage.ReplyPending}
+		if len(args) > 1 {
+			states = []string{args[1]}
+			if args[1] == "all" {
+				states = nil
+			}
+		}
+		replies, err := svc.Replies(states...)
+		if err != nil {
+			return err
+		}
+		for _, reply := range replies {
+			fmt.Printf("%s [%s] %s\n", reply.ID, reply.State, reply.Published.Format(time.RFC3339))
+			fmt.Printf("  by %s to %s on %s\n", reply.ActorID, reply.User, reply.InReplyTo)
+			fmt.Printf("  %q\n", reply.Content)
+		}
+		return nil
+	default:
+		state, ok := actions[args[0]]
+		if !ok || len(args) != 2 {
+			return errors.New(repliesUsage)
+		}
+		return svc.SetReplyState(args[1], state)
+	}
+}
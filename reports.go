@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+const reportsUsage = "usage: activitylace reports [list [all] | dismiss|hide|block-actor|block-domain|forward <id>]"
+
+// reportsCommand shows the queue of reports from remote moderators and acts on them
+func reportsCommand(cfg server.Config, args []string) error {
+	svc := server.NewService(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	defer svc.Stop(ctx)
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		statuses := []string{storage.ReportOpen}
+		if len(args) > 1 && args[1] == "all" {
+			statuses = nil
+		}
+		reports, err := svc.Reports(statuses...)
+		if err != nil {
+			return err
+		}
+		for _, report := range reports {
+			printReport(report)
+		}
+		return nil
+	case "forward":
+		if len(args) != 2 {
+			return errors.New(reportsUsage)
+		}
+		n, err := svc.ForwardReport(context.Background(), args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("forwarded to %d servers\n", n)
+		return nil
+	case server.ReportDismiss, server.ReportHide, server.ReportBlockActor, server.ReportBlockDomain:
+		if len(args) != 2 {
+			return errors.New(reportsUsage)
+		}
+		return svc.ResolveReport(args[1], args[0])
+	default:
+		return errors.New(reportsUsage)
+	}
+}
+
+func printReport(report server.ReportQueueItem) {
+	fmt.Printf("%s [%s] %s\n", report.ID, report.Status, report.ReceivedAt.Format(time.RFC3339))
+	fmt.Printf("  reported by %s to %s\n", report.ActorID, report.User)
+	if report.Content != "" {
+		fmt.Printf("  %q\n", report.Content)
+	}
+	if len(report.Actors) > 0 {
+		fmt.Printf("  actors: %s\n", strings.Join(report.Actors, " "))
+	}
+	for _, reply := range report.Replies {
+		fmt.Printf("  reply %s by %s: %q\n", reply.ID, reply.ActorID, reply.Content)
+	}
+	if report.Action != "" {
+		fmt.Printf("  %s at %s\n", report.Action, report.ResolvedAt.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server"
+)
+
+// reprocessCommand runs stored inbox activities through processing again
+func reprocessCommand(cfg server.Config, args []string) error {
+	flags := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	user := flags.String("user", "", "only this user's inbox")
+	id := flags.String("id", "", "a single stored activity")
+	statuses := flags.String("status", "rejected,failed", "comma-separated statuses to reprocess")
+	since := flags.String("since", "", "only activities received since this date (2006-01-02)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	filter := server.ReprocessFilter{
+		User: *user,
+		ID:   *id,
+	}
+	if *statuses != "" {
+		filter.Statuses = strings.Split(*statuses, ",")
+	}
+	if *since != "" {
+		t, err := time.Parse("2006-01-02", *since)
+		if err != nil {
+			return fmt.Errorf("bad date %s: %w", *since, err)
+		}
+		filter.Since = t
+	}
+
+	svc := server.NewService(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	defer svc.Stop(ctx)
+
+	succeeded, failed, err := svc.Reprocess(context.Background(), filter)
+	fmt.Printf("%d succeeded, %d failed\n", succeeded, failed)
+	return err
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tkrehbiel/activitylace/server"
+)
+
+// resolveCommand prints the actor IDs for one or more @user@host handles
+func resolveCommand(cfg server.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: activitylace resolve <@user@host>...")
+	}
+	finger := server.NewWebFinger(server.NewFetcher(cfg.Server.FetchAllow))
+	for _, handle := range args {
+		id, err := finger.Resolve(context.Background(), handle)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", handle, id)
+	}
+	return nil
+}
@@ -1,13 +1,27 @@
 package activity
 
 type Activity struct {
-	Context interface{} `json:"@context,omitempty"`
-	Type    string      `json:"type"`
-	ID      string      `json:"id,omitempty"`
-	Name    string      `json:"name,omitempty"`
-	Actor   interface{} `json:"actor,omitempty"`
-	Object  interface{} `json:"object,omitempty"`
-	Target  interface{} `json:"target,omitempty"`
-	To      []string    `json:"to,omitempty"`
-	CC      []string    `json:"cc,omitempty"`
+	Context   interface{} `json:"@context,omitempty"`
+	Type      string      `json:"type"`
+	ID        string      `json:"id,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	Actor     interface{} `json:"actor,omitempty"`
+	Object    interface{} `json:"object,omitempty"`
+	Target    interface{} `json:"target,omitempty"`
+	To        []string    `json:"to,omitempty"`
+	CC        []string    `json:"cc,omitempty"`
+	Published string      `json:"published,omitempty"`
+}
+
+// IsPublic returns true if the activity is addressed to the public collection
+func (a Activity) IsPublic() bool {
+	for _, list := range [][]string{a.To, a.CC} {
+		for _, address := range list {
+			switch address {
+			case PublicAddress, "as:Public", "Public":
+				return true
+			}
+		}
+	}
+	return false
 }
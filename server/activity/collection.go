@@ -7,3 +7,11 @@ type OrderedNoteCollection struct {
 	NumItems int         `json:"numItems,omitempty"`
 	Items    []Note      `json:"orderedItems,omitempty"` // TODO: should support any object
 }
+
+// Collection is an unordered collection that only reports its size
+type Collection struct {
+	Context    interface{} `json:"@context,omitempty"`
+	Type       string      `json:"type"`
+	ID         string      `json:"id"`
+	TotalItems int         `json:"totalItems"`
+}
@@ -16,14 +16,22 @@ type Object struct {
 }
 
 type Note struct {
-	Context   interface{} `json:"@context,omitempty"`
-	Type      string      `json:"type"`
-	ID        string      `json:"id"`
-	Title     string      `json:"title,omitempty"`
-	Content   string      `json:"content,omitempty"`
-	MediaType string      `json:"mediaType,omitempty"`
-	Published string      `json:"published"`
-	URL       string      `json:"url"` // plain url string
+	Context      interface{} `json:"@context,omitempty"`
+	Type         string      `json:"type"`
+	ID           string      `json:"id"`
+	Title        string      `json:"title,omitempty"`
+	Name         string      `json:"name,omitempty"`
+	Summary      string      `json:"summary,omitempty"`
+	Content      string      `json:"content,omitempty"`
+	MediaType    string      `json:"mediaType,omitempty"`
+	Published    string      `json:"published"`
+	Updated      string      `json:"updated,omitempty"`
+	URL          string      `json:"url"`                    // plain url string
+	InReplyTo    interface{} `json:"inReplyTo,omitempty"`    // id or object
+	AttributedTo interface{} `json:"attributedTo,omitempty"` // id or object
+	Likes        interface{} `json:"likes,omitempty"`        // id or collection
+	Shares       interface{} `json:"shares,omitempty"`       // id or collection
+	Tag          interface{} `json:"tag,omitempty"`          // object or list of mentions, hashtags, emoji
 }
 
 type publicKey struct {
@@ -36,15 +44,38 @@ func (p publicKey) TransformedKey() string {
 	return strings.ReplaceAll(p.Key, `\n`, "\n")
 }
 
+type endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
 type Actor struct {
-	Context   interface{} `json:"@context,omitempty"`
-	Type      string      `json:"type"`
-	ID        string      `json:"id"`
-	Inbox     string      `json:"inbox"`
-	Outbox    string      `json:"outbox"`
-	Following string      `json:"following,omitempty"`
-	Followers string      `json:"followers,omitempty"`
-	Liked     string      `json:"liked,omitempty"`
-	Preferred string      `json:"preferredUsername,omitempty"`
-	PublicKey publicKey   `json:"publicKey,omitempty"`
+	Context     interface{} `json:"@context,omitempty"`
+	Type        string      `json:"type"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name,omitempty"`
+	Inbox       string      `json:"inbox"`
+	Outbox      string      `json:"outbox"`
+	Following   string      `json:"following,omitempty"`
+	Followers   string      `json:"followers,omitempty"`
+	Liked       string      `json:"liked,omitempty"`
+	Preferred   string      `json:"preferredUsername,omitempty"`
+	PublicKey   publicKey   `json:"publicKey,omitempty"`
+	Endpoints   endpoints   `json:"endpoints,omitempty"`
+	AlsoKnownAs interface{} `json:"alsoKnownAs,omitempty"` // id or list of ids
+	MovedTo     string      `json:"movedTo,omitempty"`
+}
+
+// IsAlsoKnownAs returns true if the actor lists the id as another of its accounts
+func (a Actor) IsAlsoKnownAs(id string) bool {
+	switch aka := a.AlsoKnownAs.(type) {
+	case string:
+		return aka == id
+	case []interface{}:
+		for _, v := range aka {
+			if s, ok := v.(string); ok && s == id {
+				return true
+			}
+		}
+	}
+	return false
 }
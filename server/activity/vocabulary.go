@@ -12,25 +12,49 @@ const (
 	Context       = "https://www.w3.org/ns/activitystreams"
 	ContentTypeLD = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
 	ContentType   = `application/activity+json`
+	PublicAddress = "https://www.w3.org/ns/activitystreams#Public"
 )
 
 // ActivityPub object types
 const (
 	PersonType            = "Person"
+	ServiceType           = "Service"
+	ApplicationType       = "Application"
+	GroupType             = "Group"
+	OrganizationType      = "Organization"
+	TombstoneType         = "Tombstone"
+	EmojiType             = "Emoji"
+	MentionType           = "Mention"
 	NoteType              = "Note"
 	LinkType              = "Link"
 	OrderedCollectionType = "OrderedCollection"
+	CollectionType        = "Collection"
 )
 
 // ActivityPub activity types
 const (
-	AcceptType = "Accept"
-	RejectType = "Reject"
-	FollowType = "Follow"
-	UndoType   = "Undo"
-	CreateType = "Create"
+	AcceptType   = "Accept"
+	RejectType   = "Reject"
+	FollowType   = "Follow"
+	UndoType     = "Undo"
+	CreateType   = "Create"
+	LikeType     = "Like"
+	AnnounceType = "Announce"
+	DeleteType   = "Delete"
+	UpdateType   = "Update"
+	MoveType     = "Move"
+	FlagType     = "Flag"
 )
 
+// IsActorType returns true if the object type is one of the kinds of actor
+func IsActorType(t string) bool {
+	switch t {
+	case PersonType, ServiceType, ApplicationType, GroupType, OrganizationType:
+		return true
+	}
+	return false
+}
+
 const (
 	// ActivityPub time format string
 	TimeFormat = "2006-01-02T15:04:05Z"
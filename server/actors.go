@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/url"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+const (
+	actorCacheTime  = 10 * time.Minute // how long actors stay in the memory cache
+	actorRefreshAge = 24 * time.Hour   // stored actors older than this are refreshed in the background
+)
+
+// GetActor finds the remote endpoint for the actor ID, which is assumed to be a URL.
+// Handles such as acct:user@host are resolved with webfinger first.
+// Looks in the memory cache first, then the database, and finally fetches from the network.
+// Stale database entries are returned immediately and refreshed in the background.
+// Blocks until we get a response or the context is cancelled or times out.
+func (s *ActivityService) GetActor(ctx context.Context, id string) (*activity.Actor, error) {
+	if IsHandle(id) && s.finger != nil {
+		resolved, err := s.finger.Resolve(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		id = resolved
+	}
+
+	item := s.actorCache.Get(id)
+	if item != nil && !item.Expired() {
+		telemetry.Trace("found actor %s in cache", id)
+		cached := item.Value()
+		return &cached, nil
+	}
+
+	if stored := s.findStoredActor(id); stored != nil {
+		var actor activity.Actor
+		if err := json.Unmarshal([]byte(stored.Source), &actor); err != nil {
+			telemetry.Error(err, "decoding stored actor [%s]", id)
+		} else {
+			telemetry.Trace("found actor %s in database", id)
+			s.actorCache.Set(id, actor, actorCacheTime)
+			if time.Since(stored.FetchedAt) > actorRefreshAge {
+				go s.refreshActor(id)
+			}
+			return &actor, nil
+		}
+	}
+
+	// TODO: retry periodically?
+
+	return s.fetchActor(ctx, id)
+}
+
+// fetchActor retrieves an actor from its server and remembers it
+func (s *ActivityService) fetchActor(ctx context.Context, id string) (*activity.Actor, error) {
+	telemetry.Increment("actor_downloads", 1)
+	raw, err := s.fetcher.Get(ctx, id, activityContentTypes)
+	if err != nil {
+		return nil, err
+	}
+	var actor activity.Actor
+	if err := json.Unmarshal(raw, &actor); err != nil {
+		telemetry.Error(err, "decoding actor")
+		return nil, err
+	}
+
+	s.actorCache.Set(id, actor, actorCacheTime)
+	if actor.ID == id {
+		// Only persist actors that are served from their own ID
+		s.storeActor(actor, raw)
+	}
+
+	return &actor, nil
+}
+
+// refreshActor fetches a fresh copy of a stored actor.
+// Only one refresh per actor runs at a time.
+func (s *ActivityService) refreshActor(id string) {
+	if _, loaded := s.refreshing.LoadOrStore(id, true); loaded {
+		return
+	}
+	defer s.refreshing.Delete(id)
+	telemetry.Trace("refreshing stale actor %s", id)
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	if _, err := s.fetchActor(ctx, id); err != nil {
+		telemetry.Error(err, "refreshing actor [%s]", id)
+	}
+}
+
+func (s *ActivityService) findStoredActor(id string) *storage.Actor {
+	if s.actors == nil {
+		return nil
+	}
+	stored, err := s.actors.FindActor(id)
+	if err != nil {
+		telemetry.Error(err, "database error")
+		return nil
+	}
+	return stored
+}
+
+func (s *ActivityService) storeActor(actor activity.Actor, raw []byte) {
+	if s.actors == nil {
+		return
+	}
+	record := storage.Actor{
+		ID:          actor.ID,
+		DisplayName: actor.Name,
+		Inbox:       actor.Inbox,
+		SharedInbox: actor.Endpoints.SharedInbox,
+		PublicKeyID: actor.PublicKey.ID,
+		PublicKey:   actor.PublicKey.Key,
+		FetchedAt:   time.Now().UTC(),
+		Source:      string(raw),
+	}
+	if u, err := url.Parse(actor.ID); err == nil {
+		record.Server = u.Host
+	}
+	if err := s.actors.SaveActor(&record); err != nil {
+		telemetry.Error(err, "saving actor [%s]", actor.ID)
+	}
+}
+
+// GetActorPublicKey fetches the public key ID associated with the given actor URL.
+// Blocks until a result is returned or the context ends.
+func (s *ActivityService) GetActorPublicKey(ctx context.Context, id string) crypto.PublicKey {
+	url, err := url.Parse(id)
+	if err != nil {
+		telemetry.Error(err, "parsing public key ID [%s]", id)
+		return nil
+	}
+	url.Fragment = "" // remove the fragment
+
+	actor, err := s.GetActor(ctx, url.String())
+	if err != nil {
+		telemetry.Error(err, "fetching remote actor")
+		return nil
+	}
+
+	if actor.ID != url.String() {
+		telemetry.Error(err, "remote actor ID [%s] doesn't match [%s]", actor.ID, url.String())
+		return nil
+	}
+	if actor.PublicKey.ID != id {
+		// The key may have been rotated since we stored the actor
+		telemetry.Trace("public key [%s] not found in actor, refetching", id)
+		actor, err = s.fetchActor(ctx, url.String())
+		if err != nil {
+			telemetry.Error(err, "fetching remote actor")
+			return nil
+		}
+	}
+	if actor.PublicKey.ID != id {
+		telemetry.Error(err, "remote public key ID [%s] doesn't match [%s]", actor.PublicKey.ID, id)
+		return nil
+	}
+	pubKeyPem := actor.PublicKey.TransformedKey()
+	der, _ := pem.Decode([]byte(pubKeyPem))
+	if der == nil {
+		telemetry.Error(nil, "can't decode pem [%s]", pubKeyPem)
+		return nil
+	}
+	pubKey, err := x509.ParsePKIXPublicKey(der.Bytes)
+	if err != nil {
+		telemetry.Error(err, "parsing public key [%s]", pubKeyPem)
+		return nil
+	}
+	return pubKey
+}
+
+// forgetActor removes a deleted actor from the cache and database
+func (s *ActivityService) forgetActor(id string) {
+	s.actorCache.Delete(id)
+	if s.actors == nil {
+		return
+	}
+	if err := s.actors.DeleteActor(id); err != nil {
+		telemetry.Error(err, "deleting actor [%s]", id)
+	}
+}
+
+// knowsActor returns true if we have a copy of the actor
+func (s *ActivityService) knowsActor(id string) bool {
+	if item := s.actorCache.Get(id); item != nil {
+		return true
+	}
+	return s.findStoredActor(id) != nil
+}
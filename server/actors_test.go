@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+func TestGetActor_FromDatabase(t *testing.T) {
+	// A freshly stored actor should be returned without any network request
+	const id = "https://remote.example/users/test"
+	database := &mockActors{}
+	database.On("FindActor", id).Return(&storage.Actor{
+		ID:        id,
+		Inbox:     "https://remote.example/users/test/inbox",
+		FetchedAt: time.Now().UTC(),
+		Source:    `{"type":"Person","id":"https://remote.example/users/test","inbox":"https://remote.example/users/test/inbox"}`,
+	}, nil).Once()
+
+	svc := ActivityService{
+		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		fetcher:    testFetcher(),
+		actors:     database,
+	}
+
+	actor, err := svc.GetActor(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://remote.example/users/test/inbox", actor.Inbox)
+
+	// second lookup should come from the memory cache
+	_, err = svc.GetActor(context.Background(), id)
+	require.NoError(t, err)
+
+	database.AssertExpectations(t)
+}
+
+func TestGetActor_FromNetwork(t *testing.T) {
+	var remoteID string
+	fetches := 0
+	remoteActor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		actor := activity.Actor{
+			Type:  activity.PersonType,
+			ID:    remoteID,
+			Inbox: remoteID + "/inbox",
+		}
+		actor.Endpoints.SharedInbox = remoteID + "/shared"
+		w.Header().Set("Content-Type", activity.ContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonBytes(&actor))
+	}))
+	defer remoteActor.Close()
+	remoteID = remoteActor.URL
+
+	database := &mockActors{}
+	database.On("FindActor", remoteID).Return(nil, nil).Once()
+	database.On("SaveActor", mock.MatchedBy(func(a *storage.Actor) bool {
+		return a.ID == remoteID && a.SharedInbox == remoteID+"/shared" && a.Source != "" && !a.FetchedAt.IsZero()
+	})).Return(nil).Once()
+
+	svc := ActivityService{
+		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		fetcher:    testFetcher(),
+		actors:     database,
+	}
+
+	actor, err := svc.GetActor(context.Background(), remoteID)
+	require.NoError(t, err)
+	assert.Equal(t, remoteID+"/inbox", actor.Inbox)
+	assert.Equal(t, 1, fetches)
+
+	database.AssertExpectations(t)
+}
@@ -0,0 +1,413 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// adminConfig sets up the admin api, which is off unless there are tokens
+type adminConfig struct {
+	Tokens []string `json:"tokens"` // bearer tokens allowed to use the admin api
+	Listen string   `json:"listen"` // address of a separate listener, e.g. localhost:8081, or empty to share the main one
+	Prefix string   `json:"prefix"` // path prefix of the admin api, /admin if empty
+}
+
+func (a adminConfig) enabled() bool {
+	return len(a.Tokens) > 0
+}
+
+func (a adminConfig) prefix() string {
+	if a.Prefix == "" {
+		return "/admin"
+	}
+	return "/" + strings.Trim(a.Prefix, "/")
+}
+
+// addAdminHandlers adds the admin api routes to a router
+func (s *ActivityService) addAdminHandlers(router *mux.Router) {
+	if s.dashboard == nil {
+		s.dashboard = newDashboard(s)
+	}
+	s.dashboard.addHandlers(router)
+
+	api := router.PathPrefix(s.config.Server.Admin.prefix()).Subrouter()
+	api.Use(s.adminAuth)
+
+	api.HandleFunc("/notifications", s.NotificationsHTTP).Methods("GET")
+	api.HandleFunc("/followers", s.adminFollowers).Methods("GET", "DELETE")
+	api.HandleFunc("/notes", s.adminNotes).Methods("GET")
+	api.HandleFunc("/notes/resend", s.adminResendNote).Methods("POST")
+	api.HandleFunc("/deliveries", s.adminDeliveries).Methods("GET")
+	api.HandleFunc("/deliveries/retry", s.adminRetryDelivery).Methods("POST")
+	api.HandleFunc("/webhooks", s.adminWebhooks).Methods("GET")
+	api.HandleFunc("/webhooks/retry", s.adminRetryWebhook).Methods("POST")
+	api.HandleFunc("/blocks", s.adminBlocks).Methods("GET", "POST", "DELETE")
+	api.HandleFunc("/actors", s.adminActors).Methods("GET", "DELETE")
+	api.HandleFunc("/feeds", s.adminFeeds).Methods("GET")
+	api.HandleFunc("/feeds/check", s.adminCheckFeed).Methods("POST")
+	api.HandleFunc("/counters", s.adminCounters).Methods("GET")
+	api.HandleFunc("/reload", s.adminReload).Methods("POST")
+}
+
+// adminAuth only lets through requests bearing one of the configured admin tokens
+func (s *ActivityService) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if token == auth || !s.validAdminToken(token) {
+			telemetry.Increment("admin_unauthorized", 1)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="activitylace"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		telemetry.Increment("admin_requests", 1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *ActivityService) validAdminToken(token string) bool {
+	valid := false
+	for _, t := range s.currentConfig().Server.Admin.Tokens {
+		// check every token so the timing doesn't say which one matched
+		if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// writeJSON writes v as a json response
+func writeJSON(w http.ResponseWriter, v any) {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		telemetry.Error(err, "marshaling admin response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonBytes)
+}
+
+// adminError writes an error as a json response
+func adminError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusInternalServerError {
+		telemetry.Error(err, "admin request")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// targetStatus is the status for an error about an actor or domain given in a request,
+// a bad request if it was neither, otherwise the given status
+func targetStatus(err error, status int) int {
+	if errors.Is(err, errBadTarget) {
+		return http.StatusBadRequest
+	}
+	return status
+}
+
+// adminUser returns the name of the user a request is about.
+// It may be left out when there's only one user.
+func (s *ActivityService) adminUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := r.URL.Query().Get("user")
+	if users := s.currentUsers(); name == "" && len(users) == 1 {
+		name = users[0].name
+	}
+	if s.findUser(name) == nil {
+		adminError(w, http.StatusNotFound, fmt.Errorf("no user [%s]", name))
+		return "", false
+	}
+	return name, true
+}
+
+// queryLimit returns the limit query parameter, or def if there isn't one
+func queryLimit(w http.ResponseWriter, r *http.Request, def int) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		adminError(w, http.StatusBadRequest, fmt.Errorf("limit must be a positive number"))
+		return 0, false
+	}
+	return n, true
+}
+
+// requireQuery returns a query parameter that must be present
+func requireQuery(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		adminError(w, http.StatusBadRequest, fmt.Errorf("%s is required", name))
+		return "", false
+	}
+	return v, true
+}
+
+// Notifications returns the newest notifications since the given time, optionally of the given types
+func (s *ActivityService) Notifications(since time.Time, types []string, limit int) ([]storage.Notification, error) {
+	if s.notifier == nil {
+		return nil, fmt.Errorf("no notification storage")
+	}
+	return s.notifier.store.GetNotifications(since, types, limit)
+}
+
+// NotificationsHTTP serves notifications as json.
+// Query parameters: since (RFC 3339 time), type (may be repeated), limit (default 50).
+func (s *ActivityService) NotificationsHTTP(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			adminError(w, http.StatusBadRequest, fmt.Errorf("since must be an RFC 3339 time"))
+			return
+		}
+		since = t
+	}
+	limit, ok := queryLimit(w, r, 50)
+	if !ok {
+		return
+	}
+	notifications, err := s.Notifications(since, r.URL.Query()["type"], limit)
+	if err != nil {
+		adminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if notifications == nil {
+		notifications = []storage.Notification{}
+	}
+	writeJSON(w, notifications)
+}
+
+// adminFollowers lists a user's followers, or removes one given by id
+func (s *ActivityService) adminFollowers(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.adminUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodDelete {
+		id, ok := requireQuery(w, r, "id")
+		if !ok {
+			return
+		}
+		if err := s.RemoveFollower(r.Context(), name, id); err != nil {
+			adminError(w, targetStatus(err, http.StatusNotFound), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	followers, err := s.Followers(name)
+	if err != nil {
+		adminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if followers == nil {
+		followers = []storage.Follow{}
+	}
+	writeJSON(w, followers)
+}
+
+// adminNotes lists a user's latest notes
+func (s *ActivityService) adminNotes(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.adminUser(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(w, r, 20)
+	if !ok {
+		return
+	}
+	notes, err := s.Notes(name, limit)
+	if err != nil {
+		adminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if notes == nil {
+		notes = []storage.Note{}
+	}
+	writeJSON(w, notes)
+}
+
+// adminResendNote sends a note to all followers again
+func (s *ActivityService) adminResendNote(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.adminUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := requireQuery(w, r, "id")
+	if !ok {
+		return
+	}
+	n, err := s.ResendNote(name, id)
+	if err != nil {
+		adminError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, map[string]int{"queued": n})
+}
+
+// adminDeliveries lists the note delivery log.
+// Query parameters: status (may be repeated), limit (default 50).
+func (s *ActivityService) adminDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit, ok := queryLimit(w, r, 50)
+	if !ok {
+		return
+	}
+	deliveries, err := s.Deliveries(r.URL.Query()["status"], limit)
+	if err != nil {
+		adminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []storage.Delivery{}
+	}
+	writeJSON(w, deliveries)
+}
+
+// adminRetryDelivery sends a logged note delivery again
+func (s *ActivityService) adminRetryDelivery(w http.ResponseWriter, r *http.Request) {
+	id, ok := requireQuery(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := s.RetryDelivery(id); err != nil {
+		adminError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// adminWebhooks lists the webhook log.
+// Query parameters: status (may be repeated), limit (default 50).
+func (s *ActivityService) adminWebhooks(w http.ResponseWriter, r *http.Request) {
+	limit, ok := queryLimit(w, r, 50)
+	if !ok {
+		return
+	}
+	if s.webhooks == nil {
+		adminError(w, http.StatusInternalServerError, fmt.Errorf("no database"))
+		return
+	}
+	deliveries, err := s.webhooks.Deliveries(r.URL.Query()["status"], limit)
+	if err != nil {
+		adminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []storage.WebhookDelivery{}
+	}
+	writeJSON(w, deliveries)
+}
+
+// adminRetryWebhook posts a logged webhook event again
+func (s *ActivityService) adminRetryWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := requireQuery(w, r, "id")
+	if !ok {
+		return
+	}
+	if s.webhooks == nil {
+		adminError(w, http.StatusInternalServerError, fmt.Errorf("no database"))
+		return
+	}
+	if err := s.webhooks.Retry(id); err != nil {
+		adminError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// adminBlocks lists blocks, adds one given by target and reason, or removes one given by target
+func (s *ActivityService) adminBlocks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		target, ok := requireQuery(w, r, "target")
+		if !ok {
+			return
+		}
+		if err := s.Block(r.Context(), target, r.URL.Query().Get("reason")); err != nil {
+			adminError(w, targetStatus(err, http.StatusInternalServerError), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		target, ok := requireQuery(w, r, "target")
+		if !ok {
+			return
+		}
+		if err := s.Unblock(r.Context(), target); err != nil {
+			adminError(w, targetStatus(err, http.StatusInternalServerError), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		blocks, err := s.Blocks()
+		if err != nil {
+			adminError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if blocks == nil {
+			blocks = []storage.Block{}
+		}
+		writeJSON(w, blocks)
+	}
+}
+
+// adminActors lists the remote actor cache, or evicts an actor given by id
+func (s *ActivityService) adminActors(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		id, ok := requireQuery(w, r, "id")
+		if !ok {
+			return
+		}
+		s.EvictActor(id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	stored, err := s.StoredActors()
+	if err != nil {
+		adminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, struct {
+		Stored int64         `json:"stored"`
+		Cached []CachedActor `json:"cached"`
+	}{
+		Stored: stored,
+		Cached: s.CachedActors(),
+	})
+}
+
+// adminFeeds lists the health of each user's feed
+func (s *ActivityService) adminFeeds(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.FeedStatus())
+}
+
+// adminCheckFeed checks a user's feed now
+func (s *ActivityService) adminCheckFeed(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.adminUser(w, r)
+	if !ok {
+		return
+	}
+	if err := s.CheckFeed(name); err != nil {
+		adminError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// adminCounters lists the telemetry counters
+func (s *ActivityService) adminCounters(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, telemetry.Counters())
+}
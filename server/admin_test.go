@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+// adminService creates a service with real storage, an outbox and the admin api
+func adminService(t *testing.T) (*ActivityService, http.Handler) {
+	svc := moderatedService(t)
+	svc.config.Server.Admin.Tokens = []string{"first", "second"}
+	svc.deliveries = svc.store.(storage.Deliveries)
+	svc.webhooks = NewWebhooks(nil, svc.store.(storage.WebhookDeliveries))
+	userStore := svc.users[0].store
+	svc.users[0].outbox = ActivityOutbox{
+		service:      svc,
+		ownerID:      "test",
+		actorID:      "https://local/activity/test",
+		notes:        userStore.(storage.Notes),
+		followers:    userStore.(storage.Followers),
+		pipeline:     svc.pipeline,
+		sendUnsigned: true,
+		feed:         &feedState{status: FeedStatus{User: "test", URL: "https://blog/index.xml"}},
+	}
+	router := mux.NewRouter()
+	svc.addAdminHandlers(router)
+	return svc, router
+}
+
+// adminRequest makes an authorized admin api request and returns the response
+func adminRequest(t *testing.T, handler http.Handler, method string, target string, v any) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Authorization", "Bearer second")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if v != nil {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	}
+	return w
+}
+
+func TestAdmin_Unauthorized(t *testing.T) {
+	_, handler := adminService(t)
+	for _, auth := range []string{"", "first", "Bearer ", "Bearer third"} {
+		r := httptest.NewRequest("GET", "/admin/followers", nil)
+		r.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, auth)
+	}
+}
+
+func TestAdmin_Followers(t *testing.T) {
+	svc, handler := adminService(t)
+	followers := svc.users[0].inbox.followers
+	require.NoError(t, followers.SaveFollow(storage.Follow{ID: "https://remote/users/alice", RequestStatus: "accepted"}))
+	require.NoError(t, followers.SaveFollow(storage.Follow{ID: "https://remote/users/spammer", RequestStatus: "accepted"}))
+
+	var list []storage.Follow
+	adminRequest(t, handler, "GET", "/admin/followers", &list)
+	assert.Len(t, list, 2)
+
+	w := adminRequest(t, handler, "DELETE", "/admin/followers?user=test&id=https://remote/users/spammer", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = adminRequest(t, handler, "DELETE", "/admin/followers?id=https://remote/users/spammer", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = adminRequest(t, handler, "GET", "/admin/followers?user=nobody", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	adminRequest(t, handler, "GET", "/admin/followers", &list)
+	require.Len(t, list, 1)
+	assert.Equal(t, "https://remote/users/alice", list[0].ID)
+}
+
+func TestAdmin_Blocks(t *testing.T) {
+	_, handler := adminService(t)
+
+	w := adminRequest(t, handler, "POST", "/admin/blocks?target=Spam.Example&reason=spam", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	var blocks []storage.Block
+	adminRequest(t, handler, "GET", "/admin/blocks", &blocks)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "spam.example", blocks[0].ID)
+	assert.Equal(t, storage.BlockDomain, blocks[0].Type)
+
+	w = adminRequest(t, handler, "DELETE", "/admin/blocks?target=spam.example", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	adminRequest(t, handler, "GET", "/admin/blocks", &blocks)
+	assert.Empty(t, blocks)
+	w = adminRequest(t, handler, "POST", "/admin/blocks", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdmin_ResendAndRetry(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer remote.Close()
+
+	svc, handler := adminService(t)
+	svc.actorCache.Set("https://remote/users/alice", activity.Actor{ID: "https://remote/users/alice", Inbox: remote.URL}, time.Minute)
+	outbox := &svc.users[0].outbox
+	require.NoError(t, outbox.followers.SaveFollow(storage.Follow{ID: "https://remote/users/alice", RequestStatus: "accepted"}))
+	require.NoError(t, outbox.notes.SaveNote(&storage.Note{ID: "https://blog/post", Content: "Post", Published: time.Now()}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go svc.pipeline.Run(ctx)
+
+	var notes []storage.Note
+	adminRequest(t, handler, "GET", "/admin/notes", &notes)
+	require.Len(t, notes, 1)
+
+	var queued map[string]int
+	adminRequest(t, handler, "POST", "/admin/notes/resend?id=https://blog/post", &queued)
+	assert.Equal(t, 1, queued["queued"])
+	svc.pipeline.Flush()
+
+	var deliveries []storage.Delivery
+	adminRequest(t, handler, "GET", "/admin/deliveries?status=failed", &deliveries)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "https://remote/users/alice", deliveries[0].Target)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+
+	failing.Store(false)
+	w := adminRequest(t, handler, "POST", "/admin/deliveries/retry?id="+deliveries[0].ID, nil)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	svc.pipeline.Flush()
+
+	adminRequest(t, handler, "GET", "/admin/deliveries", &deliveries)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, storage.DeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+
+	w = adminRequest(t, handler, "POST", "/admin/deliveries/retry?id=nope", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdmin_Status(t *testing.T) {
+	svc, handler := adminService(t)
+	svc.actorCache.Set("https://remote/users/alice", activity.Actor{ID: "https://remote/users/alice", Name: "Alice"}, time.Minute)
+
+	var actors struct {
+		Stored int64         `json:"stored"`
+		Cached []CachedActor `json:"cached"`
+	}
+	adminRequest(t, handler, "GET", "/admin/actors", &actors)
+	require.Len(t, actors.Cached, 1)
+	assert.Equal(t, "Alice", actors.Cached[0].Name)
+	w := adminRequest(t, handler, "DELETE", "/admin/actors?id=https://remote/users/alice", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	adminRequest(t, handler, "GET", "/admin/actors", &actors)
+	assert.Empty(t, actors.Cached)
+
+	svc.users[0].outbox.StatusCode(http.StatusOK)
+	var feeds []FeedStatus
+	adminRequest(t, handler, "GET", "/admin/feeds", &feeds)
+	require.Len(t, feeds, 1)
+	assert.Equal(t, "https://blog/index.xml", feeds[0].URL)
+	assert.Equal(t, 1, feeds[0].Checks)
+	assert.False(t, feeds[0].Watching)
+	w = adminRequest(t, handler, "POST", "/admin/feeds/check", nil)
+	assert.Equal(t, http.StatusConflict, w.Code, "not watching")
+
+	var counters map[string]int
+	adminRequest(t, handler, "GET", "/admin/counters", &counters)
+	assert.NotZero(t, counters["admin_requests"])
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// authorizeInteractionHandler serves the OStatus subscribe template advertised by webfinger.
+// Local users have no interactive accounts, so the remote account or post is simply opened:
+// handles are resolved to their actor, and only URLs of ActivityPub objects are redirected to,
+// so this can't be used to send people to arbitrary sites.
+func (s *ActivityService) authorizeInteractionHandler(w http.ResponseWriter, r *http.Request) {
+	telemetry.Request(r, "authorizeInteractionHandler")
+	uri := r.URL.Query().Get("uri")
+	switch {
+	case absoluteURL(uri):
+	case IsHandle(uri):
+		actor, err := s.finger.Resolve(r.Context(), uri)
+		if err != nil {
+			telemetry.Error(err, "resolving interaction with [%s]", uri)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		uri = actor
+	default:
+		telemetry.Log("WARNING: authorize interaction request with bad uri [%s]", uri)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := s.checkInteraction(r.Context(), uri); err != nil {
+		telemetry.Error(err, "checking interaction with [%s]", uri)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, uri, http.StatusSeeOther)
+}
+
+// checkInteraction makes sure a URL is an ActivityPub object or actor served from its own host
+func (s *ActivityService) checkInteraction(ctx context.Context, uri string) error {
+	raw, err := s.fetcher.Get(ctx, uri, activityContentTypes)
+	if err != nil {
+		return err
+	}
+	var object struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return err
+	}
+	if object.Type == "" || !absoluteURL(object.ID) || !sameHost(object.ID, uri) {
+		return errors.New("not an activitypub object")
+	}
+	return nil
+}
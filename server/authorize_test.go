@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+)
+
+func TestAuthorizeInteraction(t *testing.T) {
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/webfinger":
+			w.Header().Set("Content-Type", "application/jrd+json")
+			fmt.Fprintf(w, `{"subject":%q,"links":[{"rel":"self","type":"application/activity+json","href":"%s/users/alice"}]}`,
+				r.URL.Query().Get("resource"), remote.URL)
+		case "/notes/1", "/users/alice":
+			w.Header().Set("Content-Type", activity.ContentType)
+			fmt.Fprintf(w, `{"type":"Note","id":"%s%s"}`, remote.URL, r.URL.Path)
+		case "/elsewhere":
+			w.Header().Set("Content-Type", activity.ContentType)
+			fmt.Fprint(w, `{"type":"Note","id":"https://elsewhere.example/notes/1"}`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html></html>")
+		}
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+
+	svc := reloadService(t, reloadConfig(reloadUser("bob", "Bob")))
+	svc.finger.scheme = "http"
+
+	redirect := func(uri string) (int, string) {
+		w := httptest.NewRecorder()
+		svc.ServeHTTP(w, httptest.NewRequest("GET", "/authorize_interaction?uri="+url.QueryEscape(uri), nil))
+		return w.Code, w.Header().Get("Location")
+	}
+
+	code, location := redirect(remote.URL + "/notes/1")
+	assert.Equal(t, http.StatusSeeOther, code)
+	assert.Equal(t, remote.URL+"/notes/1", location)
+
+	code, location = redirect("acct:alice@" + u.Host)
+	assert.Equal(t, http.StatusSeeOther, code)
+	assert.Equal(t, remote.URL+"/users/alice", location)
+
+	// not an activitypub object, or claiming to be one from another host
+	for _, uri := range []string{remote.URL + "/phishing", remote.URL + "/elsewhere", "https://unreachable.example/"} {
+		code, location = redirect(uri)
+		assert.Equal(t, http.StatusNotFound, code, uri)
+		assert.Empty(t, location, uri)
+	}
+
+	code, _ = redirect("javascript:alert(1)")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// the subscribe template doesn't double the slash when the url has a trailing one
+func TestWebFinger_SubscribeTemplate(t *testing.T) {
+	cfg := reloadConfig(reloadUser("bob", "Bob"))
+	cfg.URL = "https://local/"
+	svc := reloadService(t, cfg)
+	w := httptest.NewRecorder()
+	svc.ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/webfinger?resource=acct:bob@local", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"template": "https://local/authorize_interaction?uri={uri}"`)
+}
+
+func TestWebFinger_HandleDomain(t *testing.T) {
+	cfg := reloadConfig(reloadUser("bob", "Bob"))
+	cfg.Server.HandleDomain = "example.com"
+	cfg.Server.AliasDomains = []string{"bob.example"}
+	svc := reloadService(t, cfg)
+	assert.Equal(t, "@bob@example.com", svc.users[0].meta.Handle())
+
+	for _, resource := range []string{"acct:bob@local", "acct:bob@example.com", "acct:BOB@bob.example", "https://local/activity/bob"} {
+		w := httptest.NewRecorder()
+		svc.ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/webfinger?resource="+url.QueryEscape(resource), nil))
+		require.Equal(t, http.StatusOK, w.Code, resource)
+		assert.Contains(t, w.Body.String(), `"subject": "acct:bob@example.com"`, resource)
+	}
+	assert.Equal(t, http.StatusNotFound, statusOf(svc, "/.well-known/webfinger?resource=acct:bob@other.example"))
+
+	// the handle is the account's identity, so changing it waits for a restart
+	cfg.Server.HandleDomain = "other.example"
+	result, err := svc.Reload(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"server.handle_domain"}, result.Restart)
+	assert.Equal(t, http.StatusNotFound, statusOf(svc, "/.well-known/webfinger?resource=acct:bob@other.example"))
+}
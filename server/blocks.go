@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// isBlocked returns true if the actor or its server is blocked
+func (s *ActivityService) isBlocked(actorID string) bool {
+	if s.blocks == nil || actorID == "" {
+		return false
+	}
+	var domain string
+	if u, err := url.Parse(actorID); err == nil {
+		domain = strings.ToLower(u.Hostname())
+	}
+	blocked, err := s.blocks.IsBlocked(actorID, domain)
+	if err != nil {
+		telemetry.Error(err, "database error")
+		return false
+	}
+	return blocked
+}
+
+// blockTarget works out what an admin means to block: an actor, given as an actor ID
+// or a handle such as @user@host, or a whole domain
+func (s *ActivityService) blockTarget(ctx context.Context, target string) (id string, blockType string, err error) {
+	target = strings.TrimSpace(target)
+	if domainName(target) && strings.Contains(target, ".") {
+		return strings.ToLower(target), storage.BlockDomain, nil
+	}
+	id, err = s.resolveActor(ctx, target)
+	return id, storage.BlockActor, err
+}
+
+// Block ignores everything from an actor, given as an actor ID or handle, or from a whole domain.
+// Blocked followers are removed and their replies are hidden.
+func (s *ActivityService) Block(ctx context.Context, target string, reason string) error {
+	id, blockType, err := s.blockTarget(ctx, target)
+	if err != nil {
+		return err
+	}
+	return s.block(storage.Block{ID: id, Type: blockType, Reason: reason})
+}
+
+// block saves a block and applies it to followers and replies already stored
+func (s *ActivityService) block(block storage.Block) error {
+	if s.blocks == nil {
+		return errors.New("no database")
+	}
+	block.CreatedAt = time.Now().UTC()
+	if err := s.blocks.SaveBlock(&block); err != nil {
+		return err
+	}
+	telemetry.Log("blocked %s [%s]", block.Type, block.ID)
+
+	users := s.currentUsers()
+	for i := range users {
+		user := &users[i]
+		followers, err := user.inbox.followers.GetFollowers()
+		if err != nil {
+			return err
+		}
+		for _, follower := range followers {
+			if block.Type == storage.BlockActor && follower.ID != block.ID {
+				continue
+			}
+			if u, err := url.Parse(follower.ID); block.Type == storage.BlockDomain && (err != nil || !strings.EqualFold(u.Hostname(), block.ID)) {
+				continue
+			}
+			if err := user.inbox.followers.DeleteFollow(follower.ID); err != nil {
+				return err
+			}
+		}
+
+		var n int64
+		if block.Type == storage.BlockActor {
+			n, err = user.inbox.replies.SetActorRepliesState(block.ID, storage.ReplyHidden)
+		} else {
+			n, err = user.inbox.replies.SetDomainRepliesState(block.ID, storage.ReplyHidden)
+		}
+		if err != nil {
+			return err
+		}
+		telemetry.Trace("hid %d replies for user %s", n, user.name)
+	}
+	return nil
+}
+
+// Unblock removes a block. Replies hidden by the block stay hidden.
+func (s *ActivityService) Unblock(ctx context.Context, target string) error {
+	if s.blocks == nil {
+		return errors.New("no database")
+	}
+	id, _, err := s.blockTarget(ctx, target)
+	if err != nil {
+		return err
+	}
+	return s.blocks.DeleteBlock(id)
+}
+
+// Blocks returns all blocked actors and domains
+func (s *ActivityService) Blocks() ([]storage.Block, error) {
+	if s.blocks == nil {
+		return nil, errors.New("no database")
+	}
+	return s.blocks.GetBlocks()
+}
@@ -2,18 +2,80 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tkrehbiel/activitylace/server/page"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults for settings left out of the config
+const (
+	defaultPort                  = 8080
+	defaultFeedPollMinutes       = 5  // how often feeds are checked for new items
+	defaultRequestTimeoutSeconds = 15 // time limit for reading a request and writing its response
+	defaultClientTimeoutSeconds  = 15 // time limit for requests made to other servers
 )
 
 type serverConfig struct {
-	HostName        string `json:"host"`
-	Certificate     string `json:"certificate"`
-	PrivateKey      string `json:"privatekey"`
-	Port            int    `json:"port"`
-	AcceptAll       bool   `json:"accept_all"` // for debugging
-	SendUnsigned    bool   `json:"send_unsigned"`
-	ReceiveUnsigned bool   `json:"receive_unsigned"`
-	MaxFollowers    int    `json:"max_followers"`
+	HostName        string      `json:"host"`
+	HandleDomain    string      `json:"handle_domain"` // domain in user handles if not the host in url
+	AliasDomains    []string    `json:"alias_domains"` // other domains whose handles webfinger answers for
+	Certificate     string      `json:"certificate"`
+	PrivateKey      string      `json:"privatekey"`
+	Port            int         `json:"port"`
+	AcceptAll       bool        `json:"accept_all"` // for debugging
+	SendUnsigned    bool        `json:"send_unsigned"`
+	ReceiveUnsigned bool        `json:"receive_unsigned"`
+	MaxFollowers    int         `json:"max_followers"`
+	KeyDir          string      `json:"key_dir"`     // where generated user keys are kept
+	FetchAllow      []string    `json:"fetch_allow"` // private hosts or networks we may fetch from
+	Admin           adminConfig `json:"admin"`       // admin api
+
+	ProcessedRetentionDays int `json:"processed_retention_days"` // how long to remember handled activity IDs
+	InboxRetentionDays     int `json:"inbox_retention_days"`     // how long to keep received activities
+
+	FeedPollMinutes       int `json:"feed_poll_minutes"`       // how often feeds are checked, 5 if zero
+	RequestTimeoutSeconds int `json:"request_timeout_seconds"` // for requests we serve, 15 if zero
+	ClientTimeoutSeconds  int `json:"client_timeout_seconds"`  // for requests we make, 15 if zero
+
+	ProfileTemplate string `json:"profile_template"` // html/template file for profile pages instead of the built-in one
+	TemplateDir     string `json:"template_dir"`     // files here replace built-in templates of the same name
+}
+
+func (s serverConfig) port() int {
+	if s.Port == 0 {
+		return defaultPort
+	}
+	return s.Port
+}
+
+func (s serverConfig) feedPollInterval() time.Duration {
+	if s.FeedPollMinutes == 0 {
+		return defaultFeedPollMinutes * time.Minute
+	}
+	return time.Duration(s.FeedPollMinutes) * time.Minute
+}
+
+func (s serverConfig) requestTimeout() time.Duration {
+	if s.RequestTimeoutSeconds == 0 {
+		return defaultRequestTimeoutSeconds * time.Second
+	}
+	return time.Duration(s.RequestTimeoutSeconds) * time.Second
+}
+
+func (s serverConfig) clientTimeout() time.Duration {
+	if s.ClientTimeoutSeconds == 0 {
+		return defaultClientTimeoutSeconds * time.Second
+	}
+	return time.Duration(s.ClientTimeoutSeconds) * time.Second
 }
 
 func (s serverConfig) useTLS() bool {
@@ -27,12 +89,140 @@ type userConfig struct {
 	SourceURL   string `json:"outboxSource"`
 	PubKeyFile  string `json:"pubKey,omitempty"`
 	PrivKeyFile string `json:"privKey,omitempty"`
+
+	AlsoKnownAs []string `json:"alsoKnownAs,omitempty"` // accounts moving to this one
+
+	Summary      string         `json:"summary,omitempty"`      // markdown
+	Icon         imageConfig    `json:"icon"`                   // avatar
+	Header       imageConfig    `json:"header"`                 // banner
+	Fields       []profileField `json:"fields,omitempty"`       // profile metadata, url values are rel=me links
+	Discoverable bool           `json:"discoverable"`           // may be suggested to others and listed in directories
+	Indexable    bool           `json:"indexable"`              // posts may be found by full text search
+	Published    string         `json:"published,omitempty"`    // when the account was created, 2006-01-02 or RFC 3339
+	FeaturedTags []featuredTag  `json:"featuredTags,omitempty"` // tags shown on the profile
+
+	Replies replyPolicy `json:"replies"` // how replies are moderated
+}
+
+type imageConfig struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+type profileField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type featuredTag struct {
+	Name string `json:"name"` // with or without the #
+	URL  string `json:"url"`  // page listing posts with the tag
+}
+
+type nodeInfoConfig struct {
+	NodeName    string         `json:"nodeName"`
+	Description string         `json:"nodeDescription"`
+	Metadata    map[string]any `json:"metadata"` // anything else for NodeInfo metadata
+}
+
+// pageConfig is an extra static page.
+// The file is a template given the server's URL, HostName and Users.
+type pageConfig struct {
+	Path        string `json:"path"`                  // where it's served, e.g. /robots.txt
+	File        string `json:"file"`                  // relative to server.template_dir if that's set
+	ContentType string `json:"contentType,omitempty"` // guessed from the file extension if not set
+}
+
+// file returns the page's template file
+func (p pageConfig) file(dir string) string {
+	if dir == "" || filepath.IsAbs(p.File) {
+		return p.File
+	}
+	return filepath.Join(dir, p.File)
+}
+
+func (p pageConfig) contentType() string {
+	if p.ContentType != "" {
+		return p.ContentType
+	}
+	if t := mime.TypeByExtension(filepath.Ext(p.File)); t != "" {
+		return t
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// reservedPath returns true if activitylace serves the path itself
+func (c Config) reservedPath(p string) bool {
+	for _, pg := range []page.StaticPage{page.WellKnownHostMeta, page.WellKnownNodeInfo, page.WellKnownWebFinger.StaticPage} {
+		if p == pg.Path {
+			return true
+		}
+	}
+	prefixes := []string{"/" + page.SubPath + "/", "/profile/", "/nodeinfo/"}
+	if c.Server.Admin.enabled() && c.Server.Admin.Listen == "" {
+		prefixes = append(prefixes, c.Server.Admin.prefix()+"/")
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(p+"/", prefix) {
+			return true
+		}
+	}
+	return p == "/" || p == "/authorize_interaction"
+}
+
+// published parses when the account was created, zero if not set
+func (u userConfig) published() (time.Time, error) {
+	if u.Published == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, u.Published); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", u.Published)
+}
+
+// profile sets the profile of a user's actor
+func (u userConfig) profile(umeta *page.UserMetaData) {
+	umeta.UserDisplayName = u.DisplayName
+	umeta.UserSummary = page.Markdown(u.Summary)
+	if source, err := url.Parse(u.SourceURL); err == nil && source.Host != "" {
+		umeta.BlogURL = fmt.Sprintf("%s://%s/", source.Scheme, source.Host)
+	}
+	umeta.AvatarURL, umeta.AvatarWidth, umeta.AvatarHeight = u.Icon.URL, u.Icon.Width, u.Icon.Height
+	umeta.HeaderURL, umeta.HeaderWidth, umeta.HeaderHeight = u.Header.URL, u.Header.Width, u.Header.Height
+	umeta.AlsoKnownAs = u.AlsoKnownAs
+	umeta.Discoverable = u.Discoverable
+	umeta.Indexable = u.Indexable
+	if t, err := u.published(); err == nil && !t.IsZero() {
+		umeta.Published = t.UTC().Format(time.RFC3339)
+	}
+	umeta.Fields = nil
+	for _, field := range u.Fields {
+		umeta.Fields = append(umeta.Fields, page.NewProfileField(field.Name, field.Value))
+	}
+	umeta.FeaturedTags = nil
+	for _, tag := range u.FeaturedTags {
+		umeta.FeaturedTags = append(umeta.FeaturedTags, page.Hashtag{Name: strings.TrimPrefix(tag.Name, "#"), URL: tag.URL})
+	}
+}
+
+// replyPolicy decides the moderation state of new replies.
+// Actors are matched by actor ID or by domain.
+type replyPolicy struct {
+	ApproveFirst bool     `json:"approveFirst"` // replies from actors without an approved reply wait for approval
+	Trusted      []string `json:"trusted"`      // always visible
+	Hidden       []string `json:"hidden"`       // hidden unless the reader reveals them
 }
 
 type Config struct {
-	URL    string       `json:"url"` // public-facing URL
-	Server serverConfig `json:"server"`
-	Users  []userConfig `json:"users"`
+	URL      string          `json:"url"` // public-facing URL
+	Server   serverConfig    `json:"server"`
+	Users    []userConfig    `json:"users"`
+	Notify   notifyConfig    `json:"notify"`   // how the owner is told about new activity
+	Webhooks []webhookConfig `json:"webhooks"` // where events are posted
+	Pages    []pageConfig    `json:"pages"`    // extra static pages, e.g. robots.txt
+	NodeInfo nodeInfoConfig  `json:"nodeinfo"` // how the server describes itself to others
 }
 
 func (c Config) PublicHost() string {
@@ -43,9 +233,271 @@ func (c Config) PublicHost() string {
 	return u.Host
 }
 
+// AdminURL returns the base url of the admin api, or an empty string if it's off
+func (c Config) AdminURL() string {
+	admin := c.Server.Admin
+	if !admin.enabled() {
+		return ""
+	}
+	if admin.Listen != "" {
+		host := admin.Listen
+		if strings.HasPrefix(host, ":") {
+			host = "localhost" + host
+		}
+		return "http://" + host + admin.prefix()
+	}
+	return strings.TrimSuffix(c.URL, "/") + admin.prefix()
+}
+
+// AdminToken returns a token for using the admin api
+func (c Config) AdminToken() string {
+	for _, token := range c.Server.Admin.Tokens {
+		if token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// webhooks returns the configured webhooks, along with notify.webhook for notification events
+func (c Config) webhooks() []webhookConfig {
+	hooks := append([]webhookConfig{}, c.Webhooks...)
+	if c.Notify.Webhook != "" {
+		hooks = append(hooks, webhookConfig{URL: c.Notify.Webhook, Events: []string{EventNotification}})
+	}
+	return hooks
+}
+
+// Validate returns every problem found in the configuration
+func (c Config) Validate() []error {
+	var problems []error
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+	readable := func(name string, filename string) {
+		if filename == "" {
+			return
+		}
+		if f, err := os.Open(filename); err != nil {
+			problem("%s [%s] can't be read: %s", name, filename, errors.Unwrap(err))
+		} else {
+			f.Close()
+		}
+	}
+
+	if c.URL == "" {
+		problem("url is required")
+	} else if !absoluteURL(c.URL) {
+		problem("url [%s] must be an absolute url", c.URL)
+	}
+	if c.Server.Port < 0 || c.Server.Port > 65535 {
+		problem("server.port [%d] is out of range", c.Server.Port)
+	}
+	if (c.Server.Certificate == "") != (c.Server.PrivateKey == "") {
+		problem("server.certificate and server.privatekey must be given together")
+	}
+	if c.Server.HandleDomain != "" && !domainName(c.Server.HandleDomain) {
+		problem("server.handle_domain [%s] must be a bare domain name", c.Server.HandleDomain)
+	}
+	for i, domain := range c.Server.AliasDomains {
+		if !domainName(domain) {
+			problem("server.alias_domains[%d] [%s] must be a bare domain name", i, domain)
+		}
+	}
+	readable("server.certificate", c.Server.Certificate)
+	readable("server.privatekey", c.Server.PrivateKey)
+	if c.Server.ProfileTemplate != "" {
+		if b, err := os.ReadFile(c.Server.ProfileTemplate); err != nil {
+			problem("server.profile_template [%s] can't be read: %s", c.Server.ProfileTemplate, errors.Unwrap(err))
+		} else if err := page.CheckTemplate(page.ProfileTemplateName, string(b)); err != nil {
+			problem("server.profile_template: %s", err)
+		}
+	}
+	if c.Server.TemplateDir != "" {
+		if info, err := os.Stat(c.Server.TemplateDir); err != nil || !info.IsDir() {
+			problem("server.template_dir [%s] isn't a directory", c.Server.TemplateDir)
+		} else if templates, err := page.ReadTemplates(c.Server.TemplateDir); err != nil {
+			problem("server.template_dir: %s", err)
+		} else {
+			for _, name := range page.TemplateNames() {
+				if source, ok := templates[name]; ok {
+					if err := page.CheckTemplate(name, source); err != nil {
+						problem("server.template_dir: %s", err)
+					}
+				}
+			}
+		}
+	}
+	for _, setting := range []struct {
+		name string
+		n    int
+	}{
+		{"server.max_followers", c.Server.MaxFollowers},
+		{"server.processed_retention_days", c.Server.ProcessedRetentionDays},
+		{"server.inbox_retention_days", c.Server.InboxRetentionDays},
+		{"server.feed_poll_minutes", c.Server.FeedPollMinutes},
+		{"server.request_timeout_seconds", c.Server.RequestTimeoutSeconds},
+		{"server.client_timeout_seconds", c.Server.ClientTimeoutSeconds},
+	} {
+		if setting.n < 0 {
+			problem("%s [%d] can't be negative", setting.name, setting.n)
+		}
+	}
+	if c.Server.Admin.Listen != "" && !c.Server.Admin.enabled() {
+		problem("server.admin.listen is set but there are no server.admin.tokens")
+	}
+
+	if len(c.Users) == 0 {
+		problem("at least one user is required")
+	}
+	names := make(map[string]bool)
+	for i, u := range c.Users {
+		if u.Name == "" {
+			problem("users[%d].name is required", i)
+		} else if names[u.Name] {
+			problem("users[%d].name [%s] is used more than once", i, u.Name)
+		} else if strings.ContainsAny(u.Name, "/?#@ ") {
+			problem("users[%d].name [%s] can't contain / ? # @ or spaces", i, u.Name)
+		}
+		names[u.Name] = true
+		if u.SourceURL == "" {
+			problem("users[%d].outboxSource is required", i)
+		} else if !absoluteURL(u.SourceURL) {
+			problem("users[%d].outboxSource [%s] must be an absolute http or https url", i, u.SourceURL)
+		}
+		if u.PubKeyFile != "" && u.PrivKeyFile == "" {
+			problem("users[%d].pubKey is set without privKey", i)
+		}
+		readable(fmt.Sprintf("users[%d].privKey", i), u.PrivKeyFile)
+		readable(fmt.Sprintf("users[%d].pubKey", i), u.PubKeyFile)
+		for _, image := range []struct {
+			name string
+			imageConfig
+		}{{"icon", u.Icon}, {"header", u.Header}} {
+			if image.URL != "" && !absoluteURL(image.URL) {
+				problem("users[%d].%s.url [%s] must be an absolute http or https url", i, image.name, image.URL)
+			}
+			if image.Width < 0 || image.Height < 0 {
+				problem("users[%d].%s size can't be negative", i, image.name)
+			}
+		}
+		if _, err := u.published(); err != nil {
+			problem("users[%d].published [%s] must be a date like 2006-01-02 or 2006-01-02T15:04:05Z", i, u.Published)
+		}
+		for j, field := range u.Fields {
+			if field.Name == "" {
+				problem("users[%d].fields[%d].name is required", i, j)
+			}
+		}
+		for j, tag := range u.FeaturedTags {
+			if name := strings.TrimPrefix(tag.Name, "#"); name == "" || strings.ContainsAny(name, "# ") {
+				problem("users[%d].featuredTags[%d].name [%s] must be a single tag", i, j, tag.Name)
+			}
+			if !absoluteURL(tag.URL) {
+				problem("users[%d].featuredTags[%d].url [%s] must be an absolute http or https url", i, j, tag.URL)
+			}
+		}
+		for j, alias := range u.AlsoKnownAs {
+			if parsed, err := url.Parse(alias); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+				problem("users[%d].alsoKnownAs[%d] [%s] must be an absolute https url", i, j, alias)
+			}
+		}
+	}
+
+	if c.Notify.Webhook != "" && !absoluteURL(c.Notify.Webhook) {
+		problem("notify.webhook [%s] must be an absolute url", c.Notify.Webhook)
+	}
+	if c.Notify.SMTPAddr != "" && (c.Notify.MailFrom == "" || len(c.Notify.MailTo) == 0) {
+		problem("notify.smtpAddr needs notify.mailFrom and notify.mailTo")
+	}
+	for i, hook := range c.Webhooks {
+		if hook.URL != "" && !absoluteURL(hook.URL) {
+			problem("webhooks[%d].url [%s] must be an absolute url", i, hook.URL)
+		}
+	}
+	paths := make(map[string]bool)
+	for i, pg := range c.Pages {
+		if !strings.HasPrefix(pg.Path, "/") {
+			problem("pages[%d].path [%s] must start with /", i, pg.Path)
+		} else if c.reservedPath(pg.Path) {
+			problem("pages[%d].path [%s] is already served by activitylace", i, pg.Path)
+		} else if paths[pg.Path] {
+			problem("pages[%d].path [%s] is used more than once", i, pg.Path)
+		}
+		paths[pg.Path] = true
+		if pg.File == "" {
+			problem("pages[%d].file is required", i)
+		} else if b, err := os.ReadFile(pg.file(c.Server.TemplateDir)); err != nil {
+			problem("pages[%d].file [%s] can't be read: %s", i, pg.file(c.Server.TemplateDir), errors.Unwrap(err))
+		} else if err := page.CheckPage(pg.File, string(b)); err != nil {
+			problem("pages[%d]: %s", i, err)
+		}
+	}
+	return problems
+}
+
+// domainName returns true if s is a host name without a scheme, port, path or user
+func domainName(s string) bool {
+	return s != "" && !strings.ContainsAny(s, ":/@ ")
+}
+
+// absoluteURL returns true if s is an http or https url with a host
+func absoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ReadConfig parses a json config
 func ReadConfig(b []byte) (config Config, err error) {
 	if uErr := json.Unmarshal(b, &config); uErr != nil {
 		return config, uErr
 	}
 	return config, nil
 }
+
+// ReadConfigFormat parses a config in json, yaml or toml.
+// Yaml and toml use the same names as json.
+func ReadConfigFormat(b []byte, format string) (Config, error) {
+	var generic map[string]any
+	switch format {
+	case "json", "":
+		return ReadConfig(b)
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(b, &generic); err != nil {
+			return Config{}, err
+		}
+	case "toml":
+		if err := toml.Unmarshal(b, &generic); err != nil {
+			return Config{}, err
+		}
+	default:
+		return Config{}, fmt.Errorf("unknown config format %s", format)
+	}
+	// go through json so there's only one set of field names
+	b, err := json.Marshal(generic)
+	if err != nil {
+		return Config{}, err
+	}
+	return ReadConfig(b)
+}
+
+// LoadConfig reads a config file, in a format chosen by its extension,
+// and applies ACTIVITYLACE_* environment variable overrides
+func LoadConfig(filename string) (Config, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return Config{}, fmt.Errorf("opening config [%s]: %w", filename, err)
+	}
+	format := strings.TrimPrefix(filepath.Ext(filename), ".")
+	if format != "yaml" && format != "yml" && format != "toml" {
+		format = "json"
+	}
+	cfg, err := ReadConfigFormat(b, format)
+	if err != nil {
+		return cfg, fmt.Errorf("parsing config [%s]: %w", filename, err)
+	}
+	if err := cfg.applyEnv(os.Environ()); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
@@ -2,9 +2,11 @@ package server
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/page"
 )
 
 func TestReadConfig(t *testing.T) {
@@ -58,3 +60,222 @@ func TestReadConfig(t *testing.T) {
 	}
 	assert.Equal(t, expected, cfg)
 }
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := Config{
+		URL: "https://blog.example",
+		Users: []userConfig{
+			{Name: "test", SourceURL: "https://blog.example/index.xml"},
+		},
+	}
+	assert.Empty(t, cfg.Validate())
+
+	cfg = Config{
+		URL: "blog.example",
+		Server: serverConfig{
+			Port:         70000,
+			Certificate:  "cert.pem",
+			HandleDomain: "https://blog.example",
+			AliasDomains: []string{"example.com", "user@example.com"},
+			Admin:        adminConfig{Listen: ":8081"},
+		},
+		Users: []userConfig{
+			{Name: "test"},
+			{Name: "test", SourceURL: "https://blog.example/index.xml", AlsoKnownAs: []string{"https://old.example/users/test", "http://old.example/users/test"}},
+		},
+		Webhooks: []webhookConfig{{URL: "/hook"}},
+	}
+	var problems []string
+	for _, err := range cfg.Validate() {
+		problems = append(problems, err.Error())
+	}
+	assert.Equal(t, []string{
+		"url [blog.example] must be an absolute url",
+		"server.port [70000] is out of range",
+		"server.certificate and server.privatekey must be given together",
+		"server.handle_domain [https://blog.example] must be a bare domain name",
+		"server.alias_domains[1] [user@example.com] must be a bare domain name",
+		"server.certificate [cert.pem] can't be read: no such file or directory",
+		"server.admin.listen is set but there are no server.admin.tokens",
+		"users[0].outboxSource is required",
+		"users[1].name [test] is used more than once",
+		"users[1].alsoKnownAs[1] [http://old.example/users/test] must be an absolute https url",
+		"webhooks[0].url [/hook] must be an absolute url",
+	}, problems)
+}
+
+func TestConfig_AdminURL(t *testing.T) {
+	cfg := Config{URL: "https://blog.example/"}
+	assert.Empty(t, cfg.AdminURL())
+
+	cfg.Server.Admin.Tokens = []string{"", "token"}
+	assert.Equal(t, "https://blog.example/admin", cfg.AdminURL())
+	assert.Equal(t, "token", cfg.AdminToken())
+
+	cfg.Server.Admin.Listen = ":8081"
+	cfg.Server.Admin.Prefix = "/manage"
+	assert.Equal(t, "http://localhost:8081/manage", cfg.AdminURL())
+}
+
+func TestReadConfigFormat(t *testing.T) {
+	expected := Config{
+		URL:    "https://blog.example",
+		Server: serverConfig{Port: 8081, Admin: adminConfig{Tokens: []string{"token"}}},
+		Users:  []userConfig{{Name: "test", SourceURL: "https://blog.example/index.xml", Replies: replyPolicy{ApproveFirst: true}}},
+	}
+
+	cfg, err := ReadConfigFormat([]byte(`
+url: https://blog.example
+server:
+  port: 8081
+  admin:
+    tokens: [token]
+users:
+  - name: test
+    outboxSource: https://blog.example/index.xml
+    replies:
+      approveFirst: true
+`), "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, expected, cfg)
+
+	cfg, err = ReadConfigFormat([]byte(`
+url = "https://blog.example"
+
+[server]
+port = 8081
+
+[server.admin]
+tokens = ["token"]
+
+[[users]]
+name = "test"
+outboxSource = "https://blog.example/index.xml"
+
+[users.replies]
+approveFirst = true
+`), "toml")
+	require.NoError(t, err)
+	assert.Equal(t, expected, cfg)
+
+	_, err = ReadConfigFormat([]byte("url: [oops"), "yaml")
+	assert.Error(t, err)
+	_, err = ReadConfigFormat(nil, "ini")
+	assert.Error(t, err)
+}
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	cfg := Config{
+		URL:   "https://old.example",
+		Users: []userConfig{{Name: "test"}},
+	}
+	require.NoError(t, cfg.applyEnv([]string{
+		"PATH=/bin",
+		"ACTIVITYLACE_URL=https://blog.example",
+		"ACTIVITYLACE_SERVER_PORT=9000",
+		"ACTIVITYLACE_SERVER_ACCEPT_ALL=true",
+		"ACTIVITYLACE_SERVER_ADMIN_TOKENS=one, two",
+		"ACTIVITYLACE_USERS_0_OUTBOX_SOURCE=https://blog.example/index.xml",
+		"ACTIVITYLACE_USERS_0_REPLIES_APPROVE_FIRST=1",
+	}))
+	assert.Equal(t, "https://blog.example", cfg.URL)
+	assert.Equal(t, 9000, cfg.Server.Port)
+	assert.True(t, cfg.Server.AcceptAll)
+	assert.Equal(t, []string{"one", "two"}, cfg.Server.Admin.Tokens)
+	assert.Equal(t, "https://blog.example/index.xml", cfg.Users[0].SourceURL)
+	assert.True(t, cfg.Users[0].Replies.ApproveFirst)
+
+	err := cfg.applyEnv([]string{
+		"ACTIVITYLACE_SERVER_PORT=eighty",
+		"ACTIVITYLACE_USERS_1_NAME=other",
+		"ACTIVITYLACE_SERVR_PORT=80",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ACTIVITYLACE_SERVER_PORT: [eighty] isn't a number")
+	assert.Contains(t, err.Error(), "ACTIVITYLACE_USERS_1_NAME isn't a config setting")
+	assert.Contains(t, err.Error(), "ACTIVITYLACE_SERVR_PORT isn't a config setting")
+}
+
+func TestConfig_ValidateFiles(t *testing.T) {
+	cfg := Config{
+		URL: "https://blog.example",
+		Server: serverConfig{
+			FeedPollMinutes: -1,
+		},
+		Users: []userConfig{
+			{Name: "a/b", SourceURL: "ftp://blog.example/index.xml", PrivKeyFile: "missing.pem"},
+		},
+		Notify: notifyConfig{SMTPAddr: "localhost:25"},
+	}
+	var problems []string
+	for _, err := range cfg.Validate() {
+		problems = append(problems, err.Error())
+	}
+	assert.Equal(t, []string{
+		"server.feed_poll_minutes [-1] can't be negative",
+		"users[0].name [a/b] can't contain / ? # @ or spaces",
+		"users[0].outboxSource [ftp://blog.example/index.xml] must be an absolute http or https url",
+		"users[0].privKey [missing.pem] can't be read: no such file or directory",
+		"notify.smtpAddr needs notify.mailFrom and notify.mailTo",
+	}, problems)
+}
+
+func TestConfig_Defaults(t *testing.T) {
+	var s serverConfig
+	assert.Equal(t, 8080, s.port())
+	assert.Equal(t, 5*time.Minute, s.feedPollInterval())
+	assert.Equal(t, 15*time.Second, s.requestTimeout())
+	assert.Equal(t, 15*time.Second, s.clientTimeout())
+
+	s = serverConfig{Port: 80, FeedPollMinutes: 1, RequestTimeoutSeconds: 2, ClientTimeoutSeconds: 3}
+	assert.Equal(t, 80, s.port())
+	assert.Equal(t, time.Minute, s.feedPollInterval())
+	assert.Equal(t, 2*time.Second, s.requestTimeout())
+	assert.Equal(t, 3*time.Second, s.clientTimeout())
+}
+
+func TestConfig_Profile(t *testing.T) {
+	usercfg := userConfig{
+		Name:        "test",
+		SourceURL:   "https://blog.example/index.xml",
+		DisplayName: `The "Test"`,
+		Summary:     "Writes about *things*.\n\nSee https://blog.example",
+		Icon:        imageConfig{URL: "https://blog.example/me.png", Width: 400, Height: 400},
+		Published:   "2020-02-03",
+		Fields: []profileField{
+			{Name: "Blog", Value: "https://blog.example/"},
+			{Name: "Pronouns", Value: "they/them"},
+		},
+		FeaturedTags: []featuredTag{{Name: "#go", URL: "https://blog.example/tags/go"}},
+	}
+	cfg := Config{URL: "https://blog.example", Users: []userConfig{usercfg}}
+	assert.Empty(t, cfg.Validate())
+
+	var umeta page.UserMetaData
+	usercfg.profile(&umeta)
+	assert.Equal(t, `<p>Writes about <em>things</em>.</p><p>See <a href="https://blog.example" rel="nofollow noopener noreferrer" target="_blank">https://blog.example</a></p>`, umeta.UserSummary)
+	assert.Equal(t, "2020-02-03T00:00:00Z", umeta.Published)
+	require.Len(t, umeta.Fields, 2)
+	assert.Equal(t, "https://blog.example/", umeta.Fields[0].Link)
+	assert.Contains(t, umeta.Fields[0].Value, `rel="me`)
+	assert.Empty(t, umeta.Fields[1].Link)
+	assert.Equal(t, []page.Hashtag{{Name: "go", URL: "https://blog.example/tags/go"}}, umeta.FeaturedTags)
+
+	cfg.Users[0].Icon.URL = "me.png"
+	cfg.Users[0].Header.Width = -1
+	cfg.Users[0].Published = "last year"
+	cfg.Users[0].Fields = []profileField{{Value: "x"}}
+	cfg.Users[0].FeaturedTags = []featuredTag{{Name: "two tags"}}
+	var problems []string
+	for _, err := range cfg.Validate() {
+		problems = append(problems, err.Error())
+	}
+	assert.Equal(t, []string{
+		"users[0].icon.url [me.png] must be an absolute http or https url",
+		"users[0].header size can't be negative",
+		"users[0].published [last year] must be a date like 2006-01-02 or 2006-01-02T15:04:05Z",
+		"users[0].fields[0].name is required",
+		"users[0].featuredTags[0].name [two tags] must be a single tag",
+		"users[0].featuredTags[0].url [] must be an absolute http or https url",
+	}, problems)
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/karlseguin/ccache/v3"
+	"github.com/tkrehbiel/activitylace/server/page"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+const (
+	dashboardCookie  = "activitylace_dashboard"
+	dashboardSession = 12 * time.Hour // how long a sign in lasts
+	dashboardPosts   = 10             // recent posts shown for each user
+	dashboardLimit   = 50             // entries shown in each queue
+)
+
+var (
+	dashboardTemplate      = template.Must(template.New("dashboard").Parse(page.DashboardTemplate))
+	dashboardLoginTemplate = template.Must(template.New("login").Parse(page.DashboardLoginTemplate))
+)
+
+// Dashboard is an html page for managing the server from a browser, for those who'd rather not use curl.
+// Signing in with an admin token starts a session, and every form must post back the session's CSRF token.
+type Dashboard struct {
+	service  *ActivityService
+	path     string
+	sessions *ccache.Cache[string] // CSRF tokens by session ID
+}
+
+func newDashboard(s *ActivityService) *Dashboard {
+	return &Dashboard{
+		service:  s,
+		path:     s.config.Server.Admin.prefix() + "/dashboard",
+		sessions: ccache.New(ccache.Configure[string]()),
+	}
+}
+
+// addHandlers adds the dashboard routes to a router.
+// They're authorized by session cookie rather than bearer token, so they can't be on the admin api subrouter.
+func (d *Dashboard) addHandlers(router *mux.Router) {
+	router.HandleFunc(d.path, d.ServeHTTP).Methods("GET")
+	router.HandleFunc(d.path+"/login", d.login).Methods("POST")
+	router.HandleFunc(d.path+"/logout", d.logout).Methods("POST")
+	router.HandleFunc(d.path+"/action", d.action).Methods("POST")
+}
+
+// randomToken returns a random hex string for session IDs and CSRF tokens
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// session returns the session ID and CSRF token of a signed in request
+func (d *Dashboard) session(r *http.Request) (string, string, bool) {
+	cookie, err := r.Cookie(dashboardCookie)
+	if err != nil || cookie.Value == "" {
+		return "", "", false
+	}
+	item := d.sessions.Get(cookie.Value)
+	if item == nil || item.Expired() {
+		return "", "", false
+	}
+	return cookie.Value, item.Value(), true
+}
+
+// validCSRF returns true if a posted form carries the session's CSRF token
+func validCSRF(r *http.Request, csrf string) bool {
+	return subtle.ConstantTimeCompare([]byte(r.PostFormValue("csrf")), []byte(csrf)) == 1
+}
+
+func (d *Dashboard) setCookie(w http.ResponseWriter, r *http.Request, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboardCookie,
+		Value:    value,
+		Path:     d.path,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.HasPrefix(d.service.currentConfig().URL, "https:"),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// redirect sends the browser back to the dashboard after a form post
+func (d *Dashboard) redirect(w http.ResponseWriter, r *http.Request, param string, message string) {
+	target := d.path
+	if message != "" {
+		target += "?" + url.Values{param: {message}}.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// render writes an html page that mustn't be cached or framed
+func render(w http.ResponseWriter, t *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		telemetry.Error(err, "rendering dashboard")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'")
+	w.Write(buf.Bytes())
+}
+
+// ServeHTTP shows the dashboard, or the sign in form if there's no session
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	telemetry.Increment("dashboard_requests", 1)
+	_, csrf, ok := d.session(r)
+	if !ok {
+		render(w, dashboardLoginTemplate, page.DashboardLoginData{Path: d.path, Error: r.URL.Query().Get("error")})
+		return
+	}
+	data := d.data()
+	data.CSRF = csrf
+	data.Message = r.URL.Query().Get("message")
+	if msg := r.URL.Query().Get("error"); msg != "" {
+		data.Error = strings.TrimSuffix(msg+"; "+data.Error, "; ")
+	}
+	render(w, dashboardTemplate, data)
+}
+
+func (d *Dashboard) login(w http.ResponseWriter, r *http.Request) {
+	if !d.service.validAdminToken(r.PostFormValue("token")) {
+		telemetry.Increment("admin_unauthorized", 1)
+		d.redirect(w, r, "error", "that token isn't valid")
+		return
+	}
+	id := randomToken()
+	d.sessions.Set(id, randomToken(), dashboardSession)
+	d.setCookie(w, r, id, int(dashboardSession.Seconds()))
+	telemetry.Log("signed in to dashboard")
+	d.redirect(w, r, "", "")
+}
+
+func (d *Dashboard) logout(w http.ResponseWriter, r *http.Request) {
+	if id, csrf, ok := d.session(r); ok && validCSRF(r, csrf) {
+		d.sessions.Delete(id)
+	}
+	d.setCookie(w, r, "", -1)
+	d.redirect(w, r, "", "")
+}
+
+// action carries out a form posted from the dashboard
+func (d *Dashboard) action(w http.ResponseWriter, r *http.Request) {
+	_, csrf, ok := d.session(r)
+	if !ok {
+		d.redirect(w, r, "error", "please sign in again")
+		return
+	}
+	if !validCSRF(r, csrf) {
+		telemetry.Increment("dashboard_csrf_failures", 1)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	telemetry.Increment("admin_requests", 1)
+
+	s := d.service
+	form := r.PostFormValue
+	var message string
+	var err error
+	switch form("action") {
+	case "remove-follower":
+		err = s.RemoveFollower(r.Context(), form("user"), form("id"))
+		message = fmt.Sprintf("removed follower %s", form("id"))
+	case "approve-follower":
+		err = s.ApproveFollower(r.Context(), form("user"), form("id"))
+		message = fmt.Sprintf("sent an accept to follower %s", form("id"))
+	case "resend-note":
+		var n int
+		n, err = s.ResendNote(form("user"), form("id"))
+		message = fmt.Sprintf("sending %s to %d followers", form("id"), n)
+	case "retry-delivery":
+		err = s.RetryDelivery(form("id"))
+		message = "retrying delivery"
+	case "check-feed":
+		err = s.CheckFeed(form("user"))
+		message = "checking feed"
+	case "reply":
+		err = s.SetReplyState(form("id"), form("state"))
+		message = fmt.Sprintf("reply is now %s", form("state"))
+	case "report":
+		err = s.ResolveReport(form("id"), form("resolution"))
+		message = fmt.Sprintf("report resolved with %s", form("resolution"))
+	case "block":
+		if form("target") == "" {
+			err = fmt.Errorf("nothing to block")
+		} else {
+			err = s.Block(r.Context(), form("target"), form("reason"))
+			message = fmt.Sprintf("blocked %s", form("target"))
+		}
+	case "unblock":
+		err = s.Unblock(r.Context(), form("target"))
+		message = fmt.Sprintf("unblocked %s", form("target"))
+	default:
+		err = fmt.Errorf("unknown action %s", form("action"))
+	}
+	if err != nil {
+		d.redirect(w, r, "error", err.Error())
+		return
+	}
+	d.redirect(w, r, "message", message)
+}
+
+// data gathers everything shown on the dashboard.
+// Problems reading any one part are shown rather than failing the whole page.
+func (d *Dashboard) data() page.DashboardData {
+	s := d.service
+	data := page.DashboardData{
+		Path: d.path,
+		ReplyActions: []page.DashboardAction{
+			{Value: storage.ReplyVisible, Label: "Approve"},
+			{Value: storage.ReplyHidden, Label: "Hide"},
+			{Value: storage.ReplyRejected, Label: "Reject"},
+		},
+		ReportActions: []page.DashboardAction{
+			{Value: ReportDismiss, Label: "Dismiss"},
+			{Value: ReportHide, Label: "Hide replies"},
+			{Value: ReportBlockActor, Label: "Block actors"},
+			{Value: ReportBlockDomain, Label: "Block servers"},
+		},
+	}
+	var problems []string
+	problem := func(err error, what string) {
+		telemetry.Error(err, "dashboard %s", what)
+		problems = append(problems, fmt.Sprintf("%s: %s", what, err))
+	}
+
+	feeds := make(map[string]FeedStatus)
+	for _, status := range s.FeedStatus() {
+		feeds[status.User] = status
+	}
+	users := s.currentUsers()
+	for i := range users {
+		user := d.user(&users[i], feeds[users[i].name], problem)
+		data.Users = append(data.Users, user)
+	}
+
+	if replies, err := s.Replies(storage.ReplyPending); err != nil {
+		problem(err, "replies")
+	} else {
+		for _, reply := range replies {
+			data.Replies = append(data.Replies, page.DashboardReply{
+				ID:        reply.ID,
+				User:      reply.User,
+				ActorID:   reply.ActorID,
+				InReplyTo: reply.InReplyTo,
+				Published: reply.Published,
+				Content:   template.HTML(sanitizeHTML(reply.Content, nil)),
+			})
+		}
+	}
+
+	if s.reports != nil {
+		if reports, err := s.Reports(storage.ReportOpen); err != nil {
+			problem(err, "reports")
+		} else {
+			for _, report := range reports {
+				data.Reports = append(data.Reports, page.DashboardReport{
+					ID:         report.ID,
+					User:       report.User,
+					ActorID:    report.ActorID,
+					Content:    report.Content,
+					Actors:     report.Actors,
+					ReceivedAt: report.ReceivedAt,
+				})
+			}
+		}
+	}
+
+	if s.deliveries != nil {
+		if deliveries, err := s.Deliveries([]string{storage.DeliveryFailed}, dashboardLimit); err != nil {
+			problem(err, "deliveries")
+		} else {
+			for _, delivery := range deliveries {
+				data.Deliveries = append(data.Deliveries, page.DashboardDelivery{
+					ID:        delivery.ID,
+					ObjectID:  delivery.ObjectID,
+					Target:    delivery.Target,
+					Attempts:  delivery.Attempts,
+					LastError: delivery.LastError,
+				})
+			}
+		}
+	}
+
+	if s.blocks != nil {
+		if blocks, err := s.Blocks(); err != nil {
+			problem(err, "blocks")
+		} else {
+			for _, block := range blocks {
+				data.Blocks = append(data.Blocks, page.DashboardBlock{ID: block.ID, Type: block.Type, Reason: block.Reason})
+			}
+		}
+	}
+
+	for name, value := range telemetry.Counters() {
+		data.Counters = append(data.Counters, page.DashboardCounter{Name: name, Value: value})
+	}
+	sort.Slice(data.Counters, func(i, j int) bool {
+		return data.Counters[i].Name < data.Counters[j].Name
+	})
+
+	data.Error = strings.Join(problems, "; ")
+	return data
+}
+
+// user gathers the dashboard section of one local user
+func (d *Dashboard) user(user *ActivityUser, feed FeedStatus, problem func(error, string)) page.DashboardUser {
+	data := page.DashboardUser{
+		Name: user.name,
+		Feed: page.DashboardFeed{
+			URL:        feed.URL,
+			Watching:   feed.Watching,
+			Checks:     feed.Checks,
+			Failures:   feed.Failures,
+			Items:      feed.Items,
+			StatusCode: feed.StatusCode,
+			LastError:  feed.LastError,
+			LastCheck:  feed.LastCheck,
+		},
+	}
+
+	if followers, err := user.inbox.followers.GetFollowers(); err != nil {
+		problem(err, "followers of "+user.name)
+	} else {
+		for _, follower := range followers {
+			if follower.RequestStatus == "accepted" {
+				data.Followers = append(data.Followers, follower.ID)
+			} else {
+				data.Pending = append(data.Pending, follower.ID)
+			}
+		}
+	}
+
+	if user.outbox.notes == nil {
+		return data
+	}
+	notes, err := user.outbox.notes.GetLatestNotes(dashboardPosts)
+	if err != nil {
+		problem(err, "notes of "+user.name)
+		return data
+	}
+	for _, note := range notes {
+		post := page.DashboardPost{ID: note.ID, URL: note.URL, Published: note.Published}
+		if post.URL == "" {
+			post.URL = note.ID
+		}
+		if d.service.deliveries != nil {
+			deliveries, err := d.service.deliveries.GetObjectDeliveries(user.name, note.ID)
+			if err != nil {
+				problem(err, "deliveries of "+note.ID)
+			}
+			for _, delivery := range deliveries {
+				post.Total++
+				if delivery.Status == storage.DeliveryDelivered {
+					post.Delivered++
+				}
+			}
+		}
+		data.Posts = append(data.Posts, post)
+	}
+	return data
+}
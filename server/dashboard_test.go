@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+// dashboardPost posts a form to the dashboard with the given cookie
+func dashboardPost(handler http.Handler, path string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// dashboardGet gets the dashboard page with the given cookie
+func dashboardGet(t *testing.T, handler http.Handler, cookie *http.Cookie) string {
+	r := httptest.NewRequest("GET", "/admin/dashboard", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	return w.Body.String()
+}
+
+var csrfField = regexp.MustCompile(`name="csrf" value="([0-9a-f]+)"`)
+
+func TestDashboard_Login(t *testing.T) {
+	_, handler := adminService(t)
+
+	assert.Contains(t, dashboardGet(t, handler, nil), `name="token"`)
+
+	w := dashboardPost(handler, "/admin/dashboard/login", url.Values{"token": {"wrong"}}, nil)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+
+	w = dashboardPost(handler, "/admin/dashboard/login", url.Values{"token": {"first"}}, nil)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	require.Len(t, w.Result().Cookies(), 1)
+	cookie := w.Result().Cookies()[0]
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.Contains(t, dashboardGet(t, handler, cookie), "Sign out")
+
+	// the dashboard isn't reachable with a bearer token, nor the api with the cookie
+	r := httptest.NewRequest("GET", "/admin/followers", nil)
+	r.AddCookie(cookie)
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+}
+
+func TestDashboard_Actions(t *testing.T) {
+	svc, handler := adminService(t)
+	outbox := &svc.users[0].outbox
+	require.NoError(t, outbox.followers.SaveFollow(storage.Follow{ID: "https://remote/users/alice", RequestStatus: "pending"}))
+	require.NoError(t, outbox.followers.SaveFollow(storage.Follow{ID: "https://remote/users/spammer", RequestStatus: "accepted"}))
+	require.NoError(t, outbox.notes.SaveNote(&storage.Note{ID: "https://blog/post", URL: "https://blog/post", Published: time.Now()}))
+	require.NoError(t, svc.deliveries.SaveDelivery(&storage.Delivery{ID: "1", User: "test", ObjectID: "https://blog/post", Target: "https://remote/users/spammer", Status: storage.DeliveryDelivered}))
+	require.NoError(t, svc.deliveries.SaveDelivery(&storage.Delivery{ID: "2", User: "test", ObjectID: "https://blog/post", Target: "https://remote/users/bob", Status: storage.DeliveryFailed, LastError: "gone"}))
+
+	w := dashboardPost(handler, "/admin/dashboard/login", url.Values{"token": {"second"}}, nil)
+	cookie := w.Result().Cookies()[0]
+	body := dashboardGet(t, handler, cookie)
+	assert.Contains(t, body, "1/2 (50%)")
+	assert.Contains(t, body, "gone")
+	match := csrfField.FindStringSubmatch(body)
+	require.Len(t, match, 2)
+	csrf := match[1]
+
+	// forms without the session's token are refused
+	w = dashboardPost(handler, "/admin/dashboard/action", url.Values{"action": {"remove-follower"}, "user": {"test"}, "id": {"https://remote/users/spammer"}}, cookie)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = dashboardPost(handler, "/admin/dashboard/action", url.Values{"action": {"remove-follower"}, "csrf": {csrf}, "user": {"test"}, "id": {"https://remote/users/spammer"}}, nil)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Contains(t, w.Header().Get("Location"), "error=")
+
+	w = dashboardPost(handler, "/admin/dashboard/action", url.Values{"action": {"remove-follower"}, "csrf": {csrf}, "user": {"test"}, "id": {"https://remote/users/spammer"}}, cookie)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Contains(t, w.Header().Get("Location"), "message=")
+	w = dashboardPost(handler, "/admin/dashboard/action", url.Values{"action": {"approve-follower"}, "csrf": {csrf}, "user": {"test"}, "id": {"https://remote/users/alice"}}, cookie)
+	assert.Contains(t, w.Header().Get("Location"), "message=")
+	w = dashboardPost(handler, "/admin/dashboard/action", url.Values{"action": {"block"}, "csrf": {csrf}, "target": {"spam.example"}}, cookie)
+	assert.Contains(t, w.Header().Get("Location"), "message=")
+
+	// accepted once the follower's server takes the Accept
+	followers, err := outbox.followers.GetFollowers()
+	require.NoError(t, err)
+	require.Len(t, followers, 1)
+	assert.Equal(t, "pending", followers[0].RequestStatus)
+	blocks, err := svc.Blocks()
+	require.NoError(t, err)
+	assert.Len(t, blocks, 1)
+
+	// signing out ends the session
+	dashboardPost(handler, "/admin/dashboard/logout", url.Values{"csrf": {csrf}}, cookie)
+	assert.Contains(t, dashboardGet(t, handler, cookie), `name="token"`)
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// Delete handles a Delete activity.
+// Deleting an actor removes them as a follower and purges everything they sent us.
+// Deleting a note tombstones the stored reply.
+func (ai *ActivityInbox) Delete(act activity.Activity) error {
+	telemetry.Increment("delete_requests", 1)
+
+	actorID := parseID(act.Actor)
+	objectID := parseID(act.Object)
+
+	var message = fmt.Sprintf("POST delete [%s] by [%s] at inbox [%s]", objectID, actorID, ai.id)
+	defer func() {
+		telemetry.Log(message)
+	}()
+
+	if objectID == "" {
+		message += " - rejected, no object"
+		return reject("delete has no object")
+	}
+
+	if objectID == actorID {
+		if err := ai.deleteActor(actorID); err != nil {
+			message += " - database write error"
+			return err
+		}
+		message += " - actor deleted"
+		return nil
+	}
+
+	reply, err := ai.replies.FindReply(objectID)
+	if err != nil {
+		message += " - failed, database read error"
+		return fmt.Errorf("finding reply: %w", err)
+	}
+	if reply == nil {
+		// Probably something we never stored
+		message += " - unknown object"
+		return nil
+	}
+	if reply.ActorID != actorID {
+		message += " - rejected, wrong author"
+		return reject("delete of [%s] by [%s] but written by [%s]", objectID, actorID, reply.ActorID)
+	}
+	if reply.IsDeleted() {
+		message += " - already deleted"
+		return nil
+	}
+
+	// Keep the tombstone so a late redelivery of the Create doesn't bring it back
+	reply.Deleted = time.Now().UTC()
+	reply.Content = ""
+	reply.Source = ""
+	if err := ai.replies.SaveReply(reply); err != nil {
+		message += " - database write error"
+		return fmt.Errorf("saving reply: %w", err)
+	}
+
+	telemetry.Increment("replies_deleted", 1)
+	ai.emit(EventReplyDeleted, map[string]string{
+		"id":        reply.ID,
+		"inReplyTo": reply.InReplyTo,
+		"actor":     reply.ActorID,
+	})
+	message += " - success"
+	return nil
+}
+
+// deleteActor forgets everything about a remote actor whose account was deleted.
+// Every local user is sent their own copy of the Delete, but the actor and its key are
+// shared, so the actor is purged from all the users at once before being forgotten.
+func (ai *ActivityInbox) deleteActor(actorID string) error {
+	if err := ai.purgeActor(actorID); err != nil {
+		return err
+	}
+	if ai.service != nil {
+		users := ai.service.currentUsers()
+		for i := range users {
+			if users[i].inbox.id == ai.id {
+				continue
+			}
+			if err := users[i].inbox.purgeActor(actorID); err != nil {
+				return fmt.Errorf("purging from %s: %w", users[i].name, err)
+			}
+		}
+		ai.service.forgetActor(actorID)
+	}
+	telemetry.Increment("actors_deleted", 1)
+	return nil
+}
+
+// purgeActor removes a remote actor as a follower along with their replies and reactions
+func (ai *ActivityInbox) purgeActor(actorID string) error {
+	if err := ai.followers.DeleteFollow(actorID); err != nil {
+		return fmt.Errorf("deleting follower: %w", err)
+	}
+	if ai.replies != nil {
+		n, err := ai.replies.DeleteActorReplies(actorID)
+		if err != nil {
+			return fmt.Errorf("deleting replies: %w", err)
+		}
+		telemetry.Trace("deleted %d replies by [%s]", n, actorID)
+	}
+	if ai.reactions != nil {
+		n, err := ai.reactions.DeleteActorReactions(actorID)
+		if err != nil {
+			return fmt.Errorf("deleting reactions: %w", err)
+		}
+		telemetry.Trace("deleted %d reactions by [%s]", n, actorID)
+	}
+	return nil
+}
+
+// Update handles an Update activity.
+// Actors we know about are fetched again from their server,
+// and replies we stored are refreshed from the embedded object.
+func (ai *ActivityInbox) Update(ctx context.Context, act activity.Activity, body []byte) error {
+	telemetry.Increment("update_requests", 1)
+
+	actorID := parseID(act.Actor)
+	objectID := parseID(act.Object)
+
+	var message = fmt.Sprintf("POST update [%s] by [%s] at inbox [%s]", objectID, actorID, ai.id)
+	defer func() {
+		telemetry.Log(message)
+	}()
+
+	// Keep the original object json exactly as it was sent
+	var update struct {
+		Object json.RawMessage `json:"object"`
+	}
+	if err := json.Unmarshal(body, &update); err != nil {
+		message += " - rejected, bad object"
+		return reject("unmarshaling Update object: %s", err)
+	}
+	var object activity.Object
+	if err := json.Unmarshal(update.Object, &object); err != nil {
+		message += " - rejected, bad object"
+		return reject("unmarshaling Update object: %s", err)
+	}
+
+	if activity.IsActorType(object.Type) {
+		if object.ID != actorID {
+			// Actors can only update themselves
+			message += " - rejected, wrong actor"
+			return reject("update of actor [%s] by [%s]", object.ID, actorID)
+		}
+		if ai.service == nil || ai.service.findStoredActor(actorID) == nil {
+			// Not somebody we care about
+			message += " - unknown actor"
+			return nil
+		}
+		// The embedded copy could carry any public key, only trust the actor's own server
+		if _, err := ai.service.fetchActor(ctx, actorID); err != nil {
+			message += " - failed, fetching actor"
+			return fmt.Errorf("fetching actor: %w", err)
+		}
+		message += " - actor updated"
+		return nil
+	}
+
+	if object.Type != activity.NoteType {
+		message += " - rejected, not a note"
+		return reject("can't update [%s] object [%s]", object.Type, object.ID)
+	}
+	var note activity.Note
+	if err := json.Unmarshal(update.Object, &note); err != nil {
+		message += " - rejected, bad note"
+		return reject("unmarshaling note: %s", err)
+	}
+	if attributedTo := parseID(note.AttributedTo); attributedTo != actorID {
+		message += " - rejected, wrong author"
+		return reject("note attributed to [%s] updated by [%s]", attributedTo, actorID)
+	}
+
+	reply, err := ai.replies.FindReply(note.ID)
+	if err != nil {
+		message += " - failed, database read error"
+		return fmt.Errorf("finding reply: %w", err)
+	}
+	if reply == nil {
+		message += " - unknown note"
+		return nil
+	}
+	if reply.ActorID != actorID {
+		message += " - rejected, wrong author"
+		return reject("update of [%s] by [%s] but written by [%s]", note.ID, actorID, reply.ActorID)
+	}
+	if reply.IsDeleted() {
+		message += " - already deleted"
+		return nil
+	}
+
+	sanitizeReply(reply, note)
+	reply.URL = note.URL
+	reply.Source = string(update.Object)
+	reply.Updated, err = time.Parse(time.RFC3339, note.Updated)
+	if err != nil {
+		reply.Updated = time.Now().UTC()
+	}
+	if err := ai.replies.SaveReply(reply); err != nil {
+		message += " - database write error"
+		return fmt.Errorf("saving reply: %w", err)
+	}
+
+	telemetry.Increment("replies_updated", 1)
+	message += " - success"
+	return nil
+}
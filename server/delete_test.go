@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+func TestInbox_Delete_Reply(t *testing.T) {
+	replies := &mockReplies{}
+	replies.On("FindReply", "https://remote/notes/1").Return(&storage.Reply{
+		ID:      "https://remote/notes/1",
+		ActorID: "https://remote/users/alice",
+		Content: "oops",
+		Source:  "{}",
+	}, nil)
+	replies.On("SaveReply", mock.MatchedBy(func(r *storage.Reply) bool {
+		return r.IsDeleted() && r.Content == "" && r.Source == ""
+	})).Return(nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		replies:        replies,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	// someone else can't delete it
+	err := inbox.Process(context.Background(), &storage.InboxItem{
+		Body: `{"type":"Delete","id":"d1","actor":"https://remote/users/mallory","object":"https://remote/notes/1"}`,
+	})
+	assert.ErrorAs(t, err, &rejectedError{})
+
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{
+		Body: `{"type":"Delete","id":"https://remote/activities/d2","actor":"https://remote/users/alice","object":{"type":"Tombstone","id":"https://remote/notes/1"}}`,
+	}))
+	replies.AssertExpectations(t)
+}
+
+func TestInbox_Delete_Actor(t *testing.T) {
+	const alice = "https://remote/users/alice"
+	followers := &mockFollowers{}
+	followers.On("DeleteFollow", alice).Return(nil).Once()
+	replies := &mockReplies{}
+	replies.On("DeleteActorReplies", alice).Return(2, nil).Once()
+	reactions := &mockReactions{}
+	reactions.On("DeleteActorReactions", alice).Return(1, nil).Once()
+	actors := &mockActors{}
+	actors.On("DeleteActor", alice).Return(nil).Once()
+
+	inbox := ActivityInbox{
+		service: &ActivityService{
+			actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+			actors:     actors,
+		},
+		id:             "test",
+		followers:      followers,
+		replies:        replies,
+		reactions:      reactions,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{
+		Body: `{"type":"Delete","id":"https://remote/activities/d","actor":"https://remote/users/alice","object":"https://remote/users/alice"}`,
+	}))
+	followers.AssertExpectations(t)
+	replies.AssertExpectations(t)
+	reactions.AssertExpectations(t)
+	actors.AssertExpectations(t)
+}
+
+func TestInbox_Delete_ActorAllUsers(t *testing.T) {
+	const carol = "https://remote/users/carol"
+	cfg := reloadConfig(reloadUser("alice", "Alice"), reloadUser("bob", "Bob"))
+	cfg.Server.ReceiveUnsigned = true
+	svc := reloadService(t, cfg)
+	svc.storeActor(activity.Actor{Type: activity.PersonType, ID: carol}, []byte("{}"))
+	for i := range svc.users {
+		followers := svc.users[i].store.(storage.Followers)
+		require.NoError(t, followers.SaveFollow(storage.Follow{ID: carol, RequestStatus: "accepted"}))
+	}
+
+	require.NoError(t, svc.users[0].inbox.Process(context.Background(), &storage.InboxItem{
+		Body: `{"type":"Delete","id":"https://remote/activities/d","actor":"https://remote/users/carol","object":"https://remote/users/carol"}`,
+	}))
+
+	// bob's copy of the Delete is ignored now the actor is forgotten, so it's already gone for him too
+	assert.False(t, svc.knowsActor(carol))
+	for i := range svc.users {
+		follow, err := svc.users[i].store.(storage.Followers).FindFollow(carol)
+		require.NoError(t, err)
+		assert.Nil(t, follow, svc.users[i].name)
+	}
+}
+
+func TestInbox_PostHTTP_UnknownActorDeleted(t *testing.T) {
+	actors := &mockActors{}
+	actors.On("FindActor", "https://remote/users/bob").Return(nil, nil).Once()
+
+	inbox := ActivityInbox{
+		service: &ActivityService{
+			actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+			actors:     actors,
+		},
+		id:             "test",
+		items:          &mockInboxItems{},
+		queue:          NewInboxQueue(),
+		acceptUnsigned: true,
+	}
+
+	body := `{"type":"Delete","id":"https://remote/activities/d","actor":"https://remote/users/bob","object":"https://remote/users/bob"}`
+	r := httptest.NewRequest("POST", "/activity/test/inbox", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	inbox.PostHTTP(recorder, r)
+
+	// acknowledged but neither stored nor queued
+	assert.Equal(t, http.StatusAccepted, recorder.Result().StatusCode)
+	assert.Len(t, inbox.queue.jobs, 0)
+	actors.AssertExpectations(t)
+}
+
+func TestInbox_Update_Reply(t *testing.T) {
+	replies := &mockReplies{}
+	replies.On("FindReply", "https://remote/notes/1").Return(&storage.Reply{
+		ID:      "https://remote/notes/1",
+		ActorID: "https://remote/users/alice",
+		Content: "tpyo",
+	}, nil).Once()
+	replies.On("SaveReply", mock.MatchedBy(func(r *storage.Reply) bool {
+		return r.Content == "typo" && r.Updated.Year() == 2023 && r.Source != ""
+	})).Return(nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		replies:        replies,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{
+		Body: `{"type":"Update","id":"https://remote/activities/u","actor":"https://remote/users/alice","object":{"type":"Note",
+			"id":"https://remote/notes/1","attributedTo":"https://remote/users/alice","content":"typo","updated":"2023-02-03T04:05:06Z"}}`,
+	}))
+
+	// can't update someone else's profile
+	err := inbox.Process(context.Background(), &storage.InboxItem{
+		Body: `{"type":"Update","id":"https://remote/activities/u2","actor":"https://remote/users/alice","object":{"type":"Person","id":"https://remote/users/bob"}}`,
+	})
+	assert.ErrorAs(t, err, &rejectedError{})
+	replies.AssertExpectations(t)
+}
+
+func TestInbox_Update_Actor(t *testing.T) {
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := activity.Actor{Type: activity.PersonType, ID: remote.URL + r.URL.Path, Name: "Alice"}
+		actor.PublicKey.ID = actor.ID + "#main-key"
+		actor.PublicKey.Key = "origin key"
+		w.Header().Set("Content-Type", activity.ContentType)
+		w.Write(jsonBytes(&actor))
+	}))
+	defer remote.Close()
+	actorID := remote.URL + "/users/alice"
+
+	svc := moderatedService(t)
+	svc.fetcher = testFetcher()
+	svc.storeActor(activity.Actor{ID: actorID, Name: "Old Alice"}, []byte("{}"))
+	inbox := &svc.users[0].inbox
+
+	// the embedded key is ignored in favor of the one on the actor's server
+	update := fmt.Sprintf(`{"type":"Update","id":%q,"actor":%q,"object":{"type":"Person","id":%q,
+		"name":"Mallory","publicKey":{"id":"%s#main-key","publicKeyPem":"forged key"}}}`,
+		remote.URL+"/updates/1", actorID, actorID, actorID)
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{Body: update}))
+
+	stored := svc.findStoredActor(actorID)
+	require.NotNil(t, stored)
+	assert.Equal(t, "origin key", stored.PublicKey)
+	assert.Equal(t, "Alice", stored.DisplayName)
+}
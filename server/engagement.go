@@ -0,0 +1,288 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/page"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// React handles Like and Announce activities of our notes
+func (ai *ActivityInbox) React(act activity.Activity) error {
+	telemetry.Increment("reaction_requests", 1)
+
+	actorID := parseID(act.Actor)
+	objectID := parseID(act.Object)
+
+	var message = fmt.Sprintf("POST %s [%s] by [%s] at inbox [%s]", act.Type, objectID, actorID, ai.id)
+	defer func() {
+		telemetry.Log(message)
+	}()
+
+	if act.ID == "" {
+		// Without an ID there's no way to match an Undo later
+		message += " - rejected, no id"
+		return reject("%s has no id", act.Type)
+	}
+
+	note, err := ai.notes.FindNote(objectID)
+	if err != nil {
+		message += " - failed, database read error"
+		return fmt.Errorf("finding note: %w", err)
+	}
+	if note == nil {
+		message += " - rejected, not our note"
+		return reject("%s of unknown note [%s]", act.Type, objectID)
+	}
+
+	reaction := storage.Reaction{
+		ID:       act.ID,
+		Type:     act.Type,
+		ObjectID: note.ID,
+		ActorID:  actorID,
+	}
+	reaction.Published, err = time.Parse(time.RFC3339, act.Published)
+	if err != nil {
+		reaction.Published = time.Now().UTC()
+	}
+	if err := ai.reactions.SaveReaction(&reaction); err != nil {
+		message += " - database write error"
+		return fmt.Errorf("saving reaction: %w", err)
+	}
+
+	if act.Type == activity.LikeType {
+		telemetry.Increment("likes_received", 1)
+	} else {
+		telemetry.Increment("shares_received", 1)
+	}
+	kind, event := storage.NotifyLike, EventLike
+	if act.Type == activity.AnnounceType {
+		kind, event = storage.NotifyBoost, EventBoost
+	}
+	ai.notify(storage.Notification{
+		ID:       act.ID,
+		Type:     kind,
+		ActorID:  actorID,
+		ObjectID: note.ID,
+	})
+	ai.emit(event, map[string]string{"id": act.ID, "actor": actorID, "object": note.ID})
+	message += " - success"
+	return nil
+}
+
+// Unreact handles an Undo of a Like or Announce
+func (ai *ActivityInbox) Unreact(undo activity.Activity, reaction activity.Activity) error {
+	telemetry.Increment("undo_requests", 1)
+
+	actorID := parseID(undo.Actor)
+
+	var message = fmt.Sprintf("POST undo %s [%s] by [%s] at inbox [%s]", reaction.Type, reaction.ID, actorID, ai.id)
+	defer func() {
+		telemetry.Log(message)
+	}()
+
+	if reaction.ID == "" {
+		message += " - rejected, no id"
+		return reject("undo %s has no id", reaction.Type)
+	}
+
+	existing, err := ai.reactions.FindReaction(reaction.ID)
+	if err != nil {
+		message += " - failed, database read error"
+		return fmt.Errorf("finding reaction: %w", err)
+	}
+	if existing == nil {
+		// Never saw it, or already undone
+		message += " - not found"
+		return nil
+	}
+	if existing.ActorID != actorID {
+		// Only the actor who reacted can undo it
+		message += " - rejected, wrong actor"
+		return reject("undo of [%s] by [%s] but reacted by [%s]", reaction.ID, actorID, existing.ActorID)
+	}
+
+	if err := ai.reactions.DeleteReaction(existing.ID); err != nil {
+		message += " - database write error"
+		return fmt.Errorf("deleting reaction: %w", err)
+	}
+
+	message += " - success"
+	return nil
+}
+
+// Engagement is what the blog displays under a post
+type Engagement struct {
+	ID      string          `json:"id"`
+	Likes   int             `json:"likes"`
+	Shares  int             `json:"shares"`
+	Replies []storage.Reply `json:"replies"`
+}
+
+// collectionID returns the id of the likes or shares collection of a note
+func (ao *ActivityOutbox) collectionID(name string, noteID string) string {
+	return fmt.Sprintf("%s/%s?id=%s", ao.actorID, name, url.QueryEscape(noteID))
+}
+
+// collection returns the likes or shares collection of a note
+func (ao *ActivityOutbox) collection(name string, noteID string) activity.Collection {
+	c := activity.Collection{
+		Type: activity.CollectionType,
+		ID:   ao.collectionID(name, noteID),
+	}
+	if ao.reactions == nil {
+		return c
+	}
+	reactionType := activity.LikeType
+	if name == "shares" {
+		reactionType = activity.AnnounceType
+	}
+	n, err := ao.reactions.CountReactions(noteID, reactionType)
+	if err != nil {
+		telemetry.Error(err, "database error")
+	}
+	c.TotalItems = n
+	return c
+}
+
+// findNote looks up the note named by the id query parameter, writing an error response if there isn't one
+func (ao *ActivityOutbox) findNote(w http.ResponseWriter, r *http.Request) *storage.Note {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	note, err := ao.notes.FindNote(id)
+	if err != nil {
+		telemetry.Error(err, "database error")
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil
+	}
+	if note == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	return note
+}
+
+// CollectionHTTP serves the likes or shares collection of a note
+func (ao *ActivityOutbox) CollectionHTTP(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		telemetry.Increment("get_requests", 1)
+		note := ao.findNote(w, r)
+		if note == nil {
+			return
+		}
+		c := ao.collection(name, note.ID)
+		c.Context = activity.Context
+		jsonBytes, err := json.Marshal(&c)
+		if err != nil {
+			telemetry.Error(err, "marshaling collection")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", activity.ContentTypeLD)
+		w.Write(jsonBytes)
+	}
+}
+
+// engagement collects the reaction counts and displayable replies of a note
+func (ao *ActivityOutbox) engagement(note *storage.Note) (Engagement, error) {
+	engagement := Engagement{
+		ID:      note.ID,
+		Likes:   ao.collection("likes", note.ID).TotalItems,
+		Shares:  ao.collection("shares", note.ID).TotalItems,
+		Replies: make([]storage.Reply, 0),
+	}
+	if ao.replies == nil {
+		return engagement, nil
+	}
+	// Hidden replies are included so they can be revealed on request
+	replies, err := ao.replies.GetReplies(note.ID, []string{storage.ReplyVisible, storage.ReplyHidden})
+	if err != nil {
+		return engagement, err
+	}
+	for _, reply := range replies {
+		reply.State = reply.ModerationState()
+		engagement.Replies = append(engagement.Replies, reply)
+	}
+	return engagement, nil
+}
+
+// EngagementHTTP serves replies and reaction counts of a note as plain json for the blog
+func (ao *ActivityOutbox) EngagementHTTP(w http.ResponseWriter, r *http.Request) {
+	telemetry.Increment("get_requests", 1)
+	note := ao.findNote(w, r)
+	if note == nil {
+		return
+	}
+
+	engagement, err := ao.engagement(note)
+	if err != nil {
+		telemetry.Error(err, "database error")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(&engagement)
+	if err != nil {
+		telemetry.Error(err, "marshaling engagement")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// Fetched by scripts on the blog, which is usually on another domain
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonBytes)
+}
+
+var repliesTemplate = template.Must(template.New("replies").Parse(page.RepliesTemplate))
+
+// RepliesHTTP serves the replies to a note as an html page the blog can embed
+func (ao *ActivityOutbox) RepliesHTTP(w http.ResponseWriter, r *http.Request) {
+	telemetry.Increment("get_requests", 1)
+	note := ao.findNote(w, r)
+	if note == nil {
+		return
+	}
+
+	engagement, err := ao.engagement(note)
+	if err != nil {
+		telemetry.Error(err, "database error")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data := page.RepliesData{
+		ID:     engagement.ID,
+		Likes:  engagement.Likes,
+		Shares: engagement.Shares,
+	}
+	for _, reply := range engagement.Replies {
+		data.Replies = append(data.Replies, page.ReplyData{
+			ActorID:   reply.ActorID,
+			URL:       reply.URL,
+			Published: reply.Published,
+			Summary:   template.HTML(sanitizeHTML(reply.Summary, nil)),
+			Content:   template.HTML(sanitizeHTML(reply.Content, nil)), // again, in case it was stored before sanitizing
+			Hidden:    reply.State == storage.ReplyHidden,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := repliesTemplate.Execute(&buf, &data); err != nil {
+		telemetry.Error(err, "rendering replies")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+func TestInbox_LikeAndUndo(t *testing.T) {
+	notes := &mockNotes{}
+	notes.On("FindNote", "https://blog/post").Return(&storage.Note{ID: "https://blog/post"}, nil).Once()
+	reactions := &mockReactions{}
+	reactions.On("SaveReaction", mock.MatchedBy(func(r *storage.Reaction) bool {
+		return r.ID == "https://remote/activities/like" && r.Type == activity.LikeType && r.ObjectID == "https://blog/post" &&
+			r.ActorID == "https://remote/users/alice"
+	})).Return(nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		notes:          notes,
+		reactions:      reactions,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	like := `{"type":"Like","id":"https://remote/activities/like","actor":"https://remote/users/alice","object":"https://blog/post"}`
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{Body: like}))
+
+	// only the actor who liked can undo it
+	reactions.On("FindReaction", "https://remote/activities/like").Return(&storage.Reaction{ID: "https://remote/activities/like", ActorID: "https://remote/users/alice"}, nil).Twice()
+	undo := `{"type":"Undo","id":"https://remote/activities/undo","actor":"https://remote/users/%s","object":%s}`
+	err := inbox.Process(context.Background(), &storage.InboxItem{Body: fmt.Sprintf(undo, "mallory", like)})
+	assert.ErrorAs(t, err, &rejectedError{})
+
+	reactions.On("DeleteReaction", "https://remote/activities/like").Return(nil).Once()
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{Body: fmt.Sprintf(undo, "alice", like)}))
+
+	notes.AssertExpectations(t)
+	reactions.AssertExpectations(t)
+}
+
+func TestInbox_Announce_UnknownNote(t *testing.T) {
+	notes := &mockNotes{}
+	notes.On("FindNote", "https://elsewhere/post").Return(nil, nil).Once()
+	inbox := ActivityInbox{
+		id:             "test",
+		notes:          notes,
+		reactions:      &mockReactions{},
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	announce := `{"type":"Announce","id":"https://remote/activities/boost","actor":"https://remote/users/alice","object":{"type":"Note","id":"https://elsewhere/post"}}`
+	err := inbox.Process(context.Background(), &storage.InboxItem{Body: announce})
+	assert.ErrorAs(t, err, &rejectedError{})
+	notes.AssertExpectations(t)
+}
+
+func TestOutbox_EngagementHTTP(t *testing.T) {
+	notes := &mockNotes{}
+	notes.On("FindNote", "https://blog/post").Return(&storage.Note{ID: "https://blog/post"}, nil)
+	notes.On("FindNote", "https://blog/missing").Return(nil, nil)
+	reactions := &mockReactions{}
+	reactions.On("CountReactions", "https://blog/post", activity.LikeType).Return(3, nil)
+	reactions.On("CountReactions", "https://blog/post", activity.AnnounceType).Return(1, nil)
+	replies := &mockReplies{}
+	replies.On("GetReplies", "https://blog/post", []string{storage.ReplyVisible, storage.ReplyHidden}).Return([]storage.Reply{{ID: "reply", Content: "hi"}}, nil)
+
+	outbox := ActivityOutbox{
+		actorID:   "https://local/activity/test",
+		notes:     notes,
+		replies:   replies,
+		reactions: reactions,
+	}
+
+	r := httptest.NewRequest("GET", "/activity/test/replies?id=https%3A%2F%2Fblog%2Fpost", nil)
+	recorder := httptest.NewRecorder()
+	outbox.EngagementHTTP(recorder, r)
+	require.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+	var engagement Engagement
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &engagement))
+	assert.Equal(t, 3, engagement.Likes)
+	assert.Equal(t, 1, engagement.Shares)
+	assert.Len(t, engagement.Replies, 1)
+
+	r = httptest.NewRequest("GET", "/activity/test/likes?id=https%3A%2F%2Fblog%2Fpost", nil)
+	recorder = httptest.NewRecorder()
+	outbox.CollectionHTTP("likes")(recorder, r)
+	var likes activity.Collection
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &likes))
+	assert.Equal(t, activity.CollectionType, likes.Type)
+	assert.Equal(t, "https://local/activity/test/likes?id=https%3A%2F%2Fblog%2Fpost", likes.ID)
+	assert.Equal(t, 3, likes.TotalItems)
+
+	r = httptest.NewRequest("GET", "/activity/test/replies?id=https%3A%2F%2Fblog%2Fmissing", nil)
+	recorder = httptest.NewRecorder()
+	outbox.EngagementHTTP(recorder, r)
+	assert.Equal(t, http.StatusNotFound, recorder.Result().StatusCode)
+}
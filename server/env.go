@@ -0,0 +1,113 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// envPrefix starts the names of environment variables that override config settings
+const envPrefix = "ACTIVITYLACE_"
+
+// envName turns a json field name into its part of an environment variable name,
+// e.g. outboxSource becomes OUTBOX_SOURCE and key_dir becomes KEY_DIR
+func envName(field string) string {
+	var name strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) && i > 0 {
+			name.WriteRune('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
+
+// envSettings finds every setting that can be overridden, by environment variable name.
+// Settings of list entries are named by index, e.g. ACTIVITYLACE_USERS_0_NAME.
+func envSettings(v reflect.Value, prefix string, settings map[string]reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			tag := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || tag == "" || tag == "-" {
+				continue
+			}
+			envSettings(v.Field(i), prefix+"_"+envName(tag), settings)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			for i := 0; i < v.Len(); i++ {
+				envSettings(v.Index(i), fmt.Sprintf("%s_%d", prefix, i), settings)
+			}
+			return
+		}
+		settings[prefix] = v
+	default:
+		settings[prefix] = v
+	}
+}
+
+// applyEnv overrides config settings with ACTIVITYLACE_* environment variables,
+// given as KEY=value strings. Lists are comma-separated.
+// Variables that don't name a setting are reported, since they're probably typos.
+func (c *Config) applyEnv(environ []string) error {
+	settings := make(map[string]reflect.Value)
+	envSettings(reflect.ValueOf(c).Elem(), strings.TrimSuffix(envPrefix, "_"), settings)
+
+	var problems []string
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, envPrefix) {
+			continue
+		}
+		name, value, _ := strings.Cut(kv, "=")
+		setting, ok := settings[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s isn't a config setting", name))
+			continue
+		}
+		if err := setEnvValue(setting, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func setEnvValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("[%s] isn't a number", value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("[%s] isn't true or false", value)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("can't be set from the environment")
+		}
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("can't be set from the environment")
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/rss"
+)
+
+// FeedStatus describes the health of a user's feed watcher
+type FeedStatus struct {
+	User       string    `json:"user"`
+	URL        string    `json:"url"`
+	Watching   bool      `json:"watching"`   // whether the watcher is running
+	Checks     int       `json:"checks"`     // fetches that got a response
+	Failures   int       `json:"failures"`   // fetches that failed
+	Items      int       `json:"items"`      // new items found
+	StatusCode int       `json:"statusCode"` // last http status
+	LastError  string    `json:"lastError"`  // why the last fetch failed, if it did
+	LastCheck  time.Time `json:"lastCheck"`
+	LastItem   time.Time `json:"lastItem"`
+}
+
+// feedState tracks a feed watcher while the service runs
+type feedState struct {
+	sync.Mutex
+	status  FeedStatus
+	watcher *rss.FeedWatcher
+	cancel  context.CancelFunc // stops the watcher goroutine
+	done    chan struct{}      // closed when the watcher goroutine ends
+}
+
+func (f *feedState) update(change func(status *FeedStatus)) {
+	if f == nil {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	change(&f.status)
+}
+
+func (f *feedState) items() int {
+	if f == nil {
+		return 0
+	}
+	f.Lock()
+	defer f.Unlock()
+	return f.status.Items
+}
+
+func (f *feedState) setWatcher(watcher *rss.FeedWatcher) {
+	if f == nil {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.watcher = watcher
+	f.status.Watching = watcher != nil
+}
+
+// stop stops the watcher goroutine, if there is one, and waits for it to end
+func (f *feedState) stop() {
+	if f == nil {
+		return
+	}
+	f.Lock()
+	cancel, done := f.cancel, f.done
+	f.cancel, f.done = nil, nil
+	f.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// watchFeed starts watching a user's feed, replacing any watcher it had before.
+// Does nothing unless the service is running.
+func (s *ActivityService) watchFeed(user *ActivityUser) {
+	if s.runCtx == nil || user.outbox.feed == nil {
+		return
+	}
+	feed := user.outbox.feed
+	feed.stop()
+	ctx, cancel := context.WithCancel(s.runCtx)
+	done := make(chan struct{})
+	feed.Lock()
+	feed.cancel, feed.done = cancel, done
+	feed.Unlock()
+	go func() {
+		defer close(done)
+		user.outbox.WatchRSS(ctx)
+	}()
+}
+
+// FeedStatus returns the health of every user's feed
+func (s *ActivityService) FeedStatus() []FeedStatus {
+	users := s.currentUsers()
+	list := make([]FeedStatus, 0, len(users))
+	for i := range users {
+		outbox := &users[i].outbox
+		status := FeedStatus{User: users[i].name, URL: outbox.rssURL}
+		if outbox.feed != nil {
+			outbox.feed.Lock()
+			status = outbox.feed.status
+			outbox.feed.Unlock()
+		}
+		list = append(list, status)
+	}
+	return list
+}
+
+// PollFeed checks a user's feed once and returns how many new items were found.
+// New items are saved and sent to followers just as the watcher does.
+// For commands that are run without starting the service.
+func (s *ActivityService) PollFeed(ctx context.Context, name string) (int, error) {
+	user := s.findUser(name)
+	if user == nil {
+		return 0, fmt.Errorf("no user [%s]", name)
+	}
+	watcher := user.outbox.newWatcher()
+	before := user.outbox.feed.items()
+	var err error
+	s.SendNow(ctx, func() {
+		err = watcher.Check(ctx)
+	})
+	return user.outbox.feed.items() - before, err
+}
+
+// CheckFeed asks a user's running feed watcher to check for new items now
+func (s *ActivityService) CheckFeed(name string) error {
+	user := s.findUser(name)
+	if user == nil {
+		return fmt.Errorf("no user [%s]", name)
+	}
+	feed := user.outbox.feed
+	if feed == nil {
+		return fmt.Errorf("feed for [%s] isn't being watched", name)
+	}
+	feed.Lock()
+	defer feed.Unlock()
+	if feed.watcher == nil {
+		return fmt.Errorf("feed for [%s] isn't being watched", name)
+	}
+	feed.watcher.CheckNow()
+	return nil
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+const (
+	fetchTimeout      = 15 * time.Second
+	fetchMaxBody      = 1 << 20 // 1MB is plenty for any actor or webfinger document
+	fetchMaxRedirects = 5
+)
+
+// Content types accepted when fetching remote documents
+var (
+	activityContentTypes  = []string{"application/activity+json", "application/ld+json", "application/json"}
+	webfingerContentTypes = []string{"application/jrd+json", "application/json"}
+	hostmetaContentTypes  = []string{"application/xrd+xml", "application/xml", "text/xml"}
+)
+
+var errUnsafeAddress = errors.New("refusing to connect to a non-public address")
+
+// Fetcher retrieves remote documents, such as actors, without trusting the URLs it's given.
+// Remote servers decide which URLs we fetch (e.g. a signature keyId), so the Fetcher
+// refuses to connect to private, loopback and link-local addresses unless allowlisted,
+// limits response sizes, and checks content types.
+// Concurrent requests for the same URL share a single fetch.
+type Fetcher struct {
+	client     http.Client
+	allowHosts map[string]bool // hostnames allowed even if they resolve to private addresses
+	allowNets  []*net.IPNet    // private networks allowed anyway
+	maxBody    int64
+
+	lock     sync.Mutex
+	inflight map[string]*fetchCall
+}
+
+type fetchCall struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// FetchError is returned when a remote server responds with an unsuccessful status code
+type FetchError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e FetchError) Error() string {
+	return fmt.Sprintf("response code %d from [%s]", e.StatusCode, e.URL)
+}
+
+// NewFetcher creates a Fetcher. The allow list may contain hostnames, IP addresses
+// or CIDR ranges that can be fetched even though they aren't public.
+func NewFetcher(allow []string) *Fetcher {
+	f := &Fetcher{
+		allowHosts: make(map[string]bool),
+		maxBody:    fetchMaxBody,
+		inflight:   make(map[string]*fetchCall),
+	}
+	for _, a := range allow {
+		if _, ipnet, err := net.ParseCIDR(a); err == nil {
+			f.allowNets = append(f.allowNets, ipnet)
+		} else if ip := net.ParseIP(a); ip != nil {
+			f.allowNets = append(f.allowNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else {
+			f.allowHosts[strings.ToLower(a)] = true
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would hide the real destination address from us
+	transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if f.allowHosts[strings.ToLower(host)] {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		// Resolve the name ourselves so the address we check is the address we connect to
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if f.allowedIP(ip.IP) {
+				return dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+			}
+		}
+		telemetry.Increment("fetch_refused", 1)
+		return nil, fmt.Errorf("%w: %s", errUnsafeAddress, host)
+	}
+
+	f.client = http.Client{
+		Timeout:   fetchTimeout,
+		Transport: transport,
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			if len(via) >= fetchMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			// The dialer checks the destination address as well
+			return checkFetchURL(r.URL)
+		},
+	}
+	return f
+}
+
+// Client returns an http client that refuses the same addresses as the Fetcher,
+// for other requests to remote servers, like deliveries to their inboxes
+func (f *Fetcher) Client(timeout time.Duration) http.Client {
+	client := f.client
+	client.Timeout = timeout
+	return client
+}
+
+// reservedNets aren't public even though the net package doesn't say so
+var reservedNets = func() (nets []*net.IPNet) {
+	for _, cidr := range []string{
+		"0.0.0.0/8",     // this network
+		"100.64.0.0/10", // carrier-grade NAT
+		"192.0.0.0/24",  // IETF protocol assignments
+		"198.18.0.0/15", // benchmarking
+		"240.0.0.0/4",   // reserved
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// allowedIP returns true for public addresses and allowlisted private ones
+func (f *Fetcher) allowedIP(ip net.IP) bool {
+	for _, n := range f.allowNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+func checkFetchURL(u *url.URL) error {
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("unsupported url scheme [%s]", u.Scheme)
+	}
+	if u.User != nil {
+		return fmt.Errorf("refusing url with credentials")
+	}
+	return nil
+}
+
+// Get fetches a remote document, returning its body if the response is
+// successful and has one of the given content types.
+// The shared fetch doesn't depend on any one caller, so a caller that gives up
+// doesn't fail the others waiting for the same document.
+func (f *Fetcher) Get(ctx context.Context, target string, contentTypes []string) ([]byte, error) {
+	key := strings.Join(contentTypes, ",") + " " + target
+
+	f.lock.Lock()
+	call, ok := f.inflight[key]
+	if ok {
+		telemetry.Increment("fetch_shared", 1)
+	} else {
+		call = &fetchCall{done: make(chan struct{})}
+		f.inflight[key] = call
+		go f.fetch(key, call, target, contentTypes)
+	}
+	f.lock.Unlock()
+
+	select {
+	case <-call.done:
+		return call.body, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch makes a shared fetch with its own time limit
+func (f *Fetcher) fetch(key string, call *fetchCall, target string, contentTypes []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	call.body, call.err = f.get(ctx, target, contentTypes)
+
+	f.lock.Lock()
+	delete(f.inflight, key)
+	f.lock.Unlock()
+	close(call.done)
+}
+
+func (f *Fetcher) get(ctx context.Context, target string, contentTypes []string) ([]byte, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFetchURL(u); err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("User-Agent", userAgent)
+	r.Header.Set("Accept", strings.Join(contentTypes, ", "))
+
+	telemetry.Increment("fetches", 1)
+	resp, err := f.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, FetchError{URL: target, StatusCode: resp.StatusCode}
+	}
+	if !matchContentType(resp.Header.Get("Content-Type"), contentTypes) {
+		return nil, fmt.Errorf("unexpected content type [%s] from [%s]", resp.Header.Get("Content-Type"), target)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > f.maxBody {
+		return nil, fmt.Errorf("response from [%s] is larger than %d bytes", target, f.maxBody)
+	}
+	return body, nil
+}
+
+func matchContentType(header string, contentTypes []string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	for _, ct := range contentTypes {
+		if mediaType == ct {
+			return true
+		}
+	}
+	return false
+}
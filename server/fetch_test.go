@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+)
+
+func TestFetcher_RefusesPrivateAddresses(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("should not have connected")
+	}))
+	defer remote.Close()
+
+	fetcher := NewFetcher(nil)
+	_, err := fetcher.Get(context.Background(), remote.URL, activityContentTypes)
+	assert.ErrorIs(t, err, errUnsafeAddress)
+
+	// cloud metadata address
+	_, err = fetcher.Get(context.Background(), "http://169.254.169.254/latest/meta-data/", activityContentTypes)
+	assert.ErrorIs(t, err, errUnsafeAddress)
+
+	// carrier-grade NAT
+	_, err = fetcher.Get(context.Background(), "http://100.64.0.1/", activityContentTypes)
+	assert.ErrorIs(t, err, errUnsafeAddress)
+
+	_, err = fetcher.Get(context.Background(), "file:///etc/passwd", activityContentTypes)
+	assert.Error(t, err)
+}
+
+func TestFetcher_RefusesRedirectToPrivateAddress(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://10.0.0.1/secret", http.StatusFound)
+	}))
+	defer remote.Close()
+
+	fetcher := NewFetcher([]string{"127.0.0.1"})
+	_, err := fetcher.Get(context.Background(), remote.URL, activityContentTypes)
+	assert.ErrorIs(t, err, errUnsafeAddress)
+}
+
+func TestFetcher_ChecksResponse(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/big":
+			w.Header().Set("Content-Type", activity.ContentType)
+			w.Write([]byte(strings.Repeat(" ", fetchMaxBody+1)))
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.Header().Set("Content-Type", activity.ContentTypeLD)
+			w.Write([]byte("{}"))
+		}
+	}))
+	defer remote.Close()
+
+	fetcher := testFetcher()
+
+	body, err := fetcher.Get(context.Background(), remote.URL+"/ok", activityContentTypes)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("{}"), body)
+
+	_, err = fetcher.Get(context.Background(), remote.URL+"/html", activityContentTypes)
+	assert.Error(t, err)
+
+	_, err = fetcher.Get(context.Background(), remote.URL+"/big", activityContentTypes)
+	assert.Error(t, err)
+
+	_, err = fetcher.Get(context.Background(), remote.URL+"/gone", activityContentTypes)
+	var fetchErr FetchError
+	require.True(t, errors.As(err, &fetchErr))
+	assert.Equal(t, http.StatusGone, fetchErr.StatusCode)
+}
+
+func TestFetcher_SharesConcurrentRequests(t *testing.T) {
+	var lock sync.Mutex
+	requests := 0
+	release := make(chan struct{})
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests++
+		lock.Unlock()
+		<-release
+		w.Header().Set("Content-Type", activity.ContentType)
+		w.Write([]byte("{}"))
+	}))
+	defer remote.Close()
+
+	fetcher := testFetcher()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := fetcher.Get(context.Background(), remote.URL, activityContentTypes)
+			assert.NoError(t, err)
+		}()
+	}
+	time.Sleep(100 * time.Millisecond) // let the goroutines pile up
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 1, requests)
+}
+
+func TestFetcher_CallerCancels(t *testing.T) {
+	release := make(chan struct{})
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", activity.ContentType)
+		w.Write([]byte("{}"))
+	}))
+	defer remote.Close()
+
+	fetcher := testFetcher()
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := fetcher.Get(ctx, remote.URL, activityContentTypes)
+		first <- err
+	}()
+	time.Sleep(50 * time.Millisecond) // let the first caller start the fetch
+	second := make(chan error)
+	go func() {
+		_, err := fetcher.Get(context.Background(), remote.URL, activityContentTypes)
+		second <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// the first caller giving up doesn't fail the shared fetch
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	close(release)
+	assert.NoError(t, <-second)
+}
+
+// testRequest is a pipeline request that records whether it failed
+type testRequest struct {
+	url string
+	err error
+}
+
+func (r *testRequest) String() string {
+	return "test request to " + r.url
+}
+
+func (r *testRequest) Prepare(ctx context.Context, pipeline *OutputPipeline) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodPost, r.url, nil)
+}
+
+func (r *testRequest) Receive(resp *http.Response) {
+	resp.Body.Close()
+}
+
+func (r *testRequest) Fail(err error) {
+	r.err = err
+}
+
+// trustedRequest is a pipeline request to an operator-configured destination
+type trustedRequest struct {
+	testRequest
+}
+
+func (r *trustedRequest) Trusted() bool {
+	return true
+}
+
+func TestFetcher_PipelineClient(t *testing.T) {
+	requests := 0
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer remote.Close()
+
+	pipeline := NewPipeline()
+	pipeline.client = NewFetcher(nil).Client(time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pipeline.Run(ctx)
+
+	// an inbox on a private address, as a remote actor might claim
+	inbox := &testRequest{url: remote.URL}
+	pipeline.Queue(inbox)
+	webhook := &trustedRequest{testRequest{url: remote.URL}}
+	pipeline.Queue(webhook)
+	pipeline.Flush()
+
+	assert.ErrorIs(t, inbox.err, errUnsafeAddress)
+	assert.NoError(t, webhook.err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestPipeline_QueueLater(t *testing.T) {
+	var requests atomic.Int32
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer remote.Close()
+
+	// nothing takes from the pipeline yet, but queueing doesn't start a goroutine per handler
+	pipeline := NewPipeline()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 1000; i++ {
+		pipeline.QueueLater(&testRequest{url: remote.URL})
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before+1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pipeline.Run(ctx)
+	pipeline.Flush()
+	assert.Equal(t, int32(1000), requests.Load())
+}
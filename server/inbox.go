@@ -2,12 +2,15 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tkrehbiel/activitylace/server/activity"
@@ -17,11 +20,22 @@ import (
 
 // TODO: This file is way too big
 
+const maxInboxBody = 64 * 1024
+
 type ActivityInbox struct {
 	service        *ActivityService
 	id             string
 	ownerID        string // id of the owner of the inbox
 	followers      storage.Followers
+	notes          storage.Notes
+	replies        storage.Replies
+	reactions      storage.Reactions
+	reports        storage.Reports
+	notifier       *Notifier                   // tells the owner about new activity
+	policy         replyPolicy                 // moderation of new replies
+	items          storage.InboxItems          // received activities waiting to be processed
+	processed      storage.ProcessedActivities // IDs of activities already handled
+	queue          *InboxQueue                 // processes received activities
 	pipeline       *OutputPipeline
 	privKey        crypto.PrivateKey
 	pubKeyID       string
@@ -51,30 +65,32 @@ func (ai *ActivityInbox) GetHTTP(w http.ResponseWriter, r *http.Request) {
 // PostHTTP handles POST requests to the inbox.
 // This is where the bulk of handling communications from remote federated servers happens.
 // e.g. Follow requests will come in through here.
+// Only cheap checks are done during the request. The activity is stored and
+// processed asynchronously by the InboxQueue, so we can respond right away.
 func (ai *ActivityInbox) PostHTTP(w http.ResponseWriter, r *http.Request) {
-	if ai.pipeline == nil {
-		panic("ActivityInbox pipeline missing")
+	if ai.queue == nil {
+		panic("ActivityInbox queue missing")
 	}
 
 	telemetry.Increment("post_requests", 1)
 
-	if !ai.acceptUnsigned {
-		if err := verify(ai.service, r); err != nil {
-			telemetry.Error(err, "signature unverified for %s %s", r.Method, r.URL.Path)
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		} else {
-			telemetry.Trace("signature verified for %s %s", r.Method, r.URL.Path)
-		}
-	}
-
-	jsonBytes, err := io.ReadAll(io.LimitReader(r.Body, 4000)) // limiter to minimize DoS
+	jsonBytes, err := io.ReadAll(io.LimitReader(r.Body, maxInboxBody)) // limiter to minimize DoS
 	if err != nil {
 		telemetry.Error(err, "reading body bytes")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	if !ai.acceptUnsigned {
+		// The signature itself is verified when the activity is processed,
+		// since that may require fetching the sender's public key.
+		if err := checkSignature(r, jsonBytes); err != nil {
+			telemetry.Error(err, "signature invalid for %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
 	var act activity.Activity
 	if err := json.Unmarshal(jsonBytes, &act); err != nil {
 		telemetry.Error(err, "unmarshaling activity [%s]", string(jsonBytes))
@@ -82,35 +98,296 @@ func (ai *ActivityInbox) PostHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !ai.handles(act) {
+		// unrecognized Activity Type
+		telemetry.Trace("unrecognized activity type [%s] %s", act.Type, string(jsonBytes))
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if actorID := parseID(act.Actor); !absoluteURL(actorID) {
+		// Actor IDs end up in links, log lines and mail headers
+		telemetry.Log("WARNING: activity actor %q isn't a url", actorID)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if ai.service != nil && ai.service.isBlocked(parseID(act.Actor)) {
+		// Pretend everything is fine so they don't keep retrying
+		telemetry.Increment("inbox_blocked", 1)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if act.Type == activity.DeleteType && ai.service != nil {
+		// Servers announce deleted accounts to everyone they've ever talked to.
+		// The actor can't be fetched anymore to verify the signature, and we
+		// have nothing to delete if we never knew them, so don't bother.
+		if actorID := parseID(act.Actor); actorID == parseID(act.Object) && !ai.service.knowsActor(actorID) {
+			telemetry.Increment("inbox_unknown_deletes", 1)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+	}
+
+	// Keep a copy of exactly what was received
+	headers, _ := json.Marshal(r.Header)
+	now := time.Now().UTC()
+	item := storage.InboxItem{
+		ID:          uuid.NewString(),
+		ActivityID:  act.ID,
+		Type:        act.Type,
+		Method:      r.Method,
+		Host:        r.Host,
+		Sender:      senderHost(r, act),
+		Path:        r.URL.Path,
+		Headers:     string(headers),
+		Body:        string(jsonBytes),
+		Status:      storage.InboxQueued,
+		NextAttempt: now,
+		ReceivedAt:  now,
+	}
+
+	duplicate := ai.isProcessed(act.ID)
+	if duplicate {
+		// Remote servers retry deliveries. Acknowledge without doing anything.
+		telemetry.Increment("inbox_duplicates", 1)
+		telemetry.Trace("activity [%s] already processed", act.ID)
+		item.Status = storage.InboxDuplicate
+		item.ProcessedAt = now
+	}
+
+	if !duplicate {
+		// Nothing has been verified yet, so don't let any one server fill the queue
+		waiting, err := ai.items.CountUnverified(item.Sender)
+		if err != nil {
+			telemetry.Error(err, "database error")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if waiting >= inboxMaxUnchecked {
+			telemetry.Increment("inbox_throttled", 1)
+			telemetry.Log("WARNING: too many unverified activities from [%s]", item.Sender)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	if err := ai.items.SaveInboxItem(&item); err != nil {
+		telemetry.Error(err, "database error")
+		if !duplicate {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	if !duplicate {
+		ai.queue.Queue(ai, item)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handles returns true if the inbox knows what to do with an activity
+func (ai *ActivityInbox) handles(act activity.Activity) bool {
 	switch act.Type {
-	case "Follow":
-		ai.Follow(w, act, jsonBytes)
-	case "Undo":
+	case activity.FollowType:
+		return true
+	case activity.CreateType:
+		if objectMap, ok := act.Object.(map[string]interface{}); ok {
+			return objectMap[activity.TypeProperty] == activity.NoteType
+		}
+	case activity.LikeType, activity.AnnounceType, activity.DeleteType, activity.MoveType, activity.FlagType:
+		return true
+	case activity.UpdateType:
+		if objectMap, ok := act.Object.(map[string]interface{}); ok {
+			objectType, _ := objectMap[activity.TypeProperty].(string)
+			return objectType == activity.NoteType || activity.IsActorType(objectType)
+		}
+	case activity.UndoType:
 		if objectMap, ok := act.Object.(map[string]interface{}); ok {
 			switch objectMap[activity.TypeProperty] {
-			case activity.FollowType:
-				// Unmarshal the object to its own struct
-				var unfollow struct {
-					Object activity.Activity `json:"object"`
-				}
-				err := json.Unmarshal(jsonBytes, &unfollow)
-				if err != nil {
-					telemetry.Error(err, "unmarshalling Undo activity's Object [%s]", string(jsonBytes))
-				} else {
-					ai.Unfollow(w, act, unfollow.Object)
-				}
-			default:
-				w.WriteHeader(http.StatusMethodNotAllowed)
+			case activity.FollowType, activity.LikeType, activity.AnnounceType:
+				return true
 			}
 		}
+	}
+	return false
+}
+
+// Process handles a stored activity. Called by the InboxQueue.
+// Records the signature verification result in the item.
+// Returns a rejectedError if the activity can never be processed,
+// or any other error if it might succeed when retried.
+func (ai *ActivityInbox) Process(ctx context.Context, item *storage.InboxItem) error {
+	if ai.pipeline == nil {
+		panic("ActivityInbox pipeline missing")
+	}
+
+	jsonBytes := []byte(item.Body)
+
+	signer := ""
+	if ai.acceptUnsigned {
+		if item.Verified != storage.SignatureVerified {
+			item.Verified = storage.SignatureSkipped
+		}
+	} else {
+		r, err := storedRequest(ctx, *item)
+		if err != nil {
+			item.Verified = storage.SignatureFailed
+			return reject("recreating request: %s", err)
+		}
+		// Items that were verified before don't need to be verified again when reprocessed
+		if item.Verified != storage.SignatureVerified {
+			if err := verify(ai.service, r); err != nil {
+				// Retrying would only fetch the key again for a request that may be forged
+				item.Verified = storage.SignatureFailed
+				return reject("signature unverified: %s", err)
+			}
+			item.Verified = storage.SignatureVerified
+			telemetry.Trace("signature verified for %s %s", item.Method, item.Path)
+		}
+		signer = keyOwner(r)
+	}
+
+	var act activity.Activity
+	if err := json.Unmarshal(jsonBytes, &act); err != nil {
+		return reject("unmarshaling activity: %s", err)
+	}
+
+	if !ai.acceptUnsigned && signer != parseID(act.Actor) {
+		// A valid signature only vouches for activities by the key's owner
+		return reject("activity by [%s] signed by [%s]", parseID(act.Actor), signer)
+	}
+
+	if act.ID != "" && !sameHost(act.ID, parseID(act.Actor)) {
+		// Otherwise anyone could claim the ID of someone else's activity
+		return reject("activity [%s] isn't from the host of actor [%s]", act.ID, parseID(act.Actor))
+	}
+
+	if ai.service != nil && ai.service.isBlocked(parseID(act.Actor)) {
+		// Blocked after the activity was received
+		return reject("actor [%s] is blocked", parseID(act.Actor))
+	}
+
+	// Claimed before dispatch so two deliveries processed at once can't both be handled
+	if !ai.claim(act.ID) {
+		telemetry.Increment("inbox_duplicates", 1)
+		telemetry.Trace("activity [%s] already processed", act.ID)
+		return nil
+	}
+
+	if err := ai.dispatch(ctx, act, jsonBytes); err != nil {
+		ai.release(act.ID)
+		return err
+	}
+	return nil
+}
+
+// dispatch an activity to the function that handles its type
+func (ai *ActivityInbox) dispatch(ctx context.Context, act activity.Activity, jsonBytes []byte) error {
+	switch act.Type {
+	case activity.FollowType:
+		return ai.Follow(act, jsonBytes)
+	case activity.CreateType:
+		return ai.Create(act, jsonBytes)
+	case activity.LikeType, activity.AnnounceType:
+		return ai.React(act)
+	case activity.DeleteType:
+		return ai.Delete(act)
+	case activity.UpdateType:
+		return ai.Update(ctx, act, jsonBytes)
+	case activity.MoveType:
+		return ai.Move(ctx, act)
+	case activity.FlagType:
+		return ai.Flag(act, jsonBytes)
+	case activity.UndoType:
+		// Unmarshal the object to its own struct
+		var undo struct {
+			Object activity.Activity `json:"object"`
+		}
+		if err := json.Unmarshal(jsonBytes, &undo); err != nil {
+			return reject("unmarshalling Undo activity's Object: %s", err)
+		}
+		switch undo.Object.Type {
+		case activity.FollowType:
+			return ai.Unfollow(act, undo.Object)
+		case activity.LikeType, activity.AnnounceType:
+			return ai.Unreact(act, undo.Object)
+		default:
+			return reject("can't undo [%s]", undo.Object.Type)
+		}
 	default:
-		// unrecognized Activity Type
-		telemetry.Trace("unrecognized activity type [%s] %s", act.Type, string(jsonBytes))
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		return reject("unrecognized activity type [%s]", act.Type)
+	}
+}
+
+// isProcessed returns true if an activity with the given ID was already handled
+func (ai *ActivityInbox) isProcessed(id string) bool {
+	if ai.processed == nil || id == "" {
+		return false
+	}
+	done, err := ai.processed.IsProcessed(id)
+	if err != nil {
+		// Better to risk handling an activity twice than not at all
+		telemetry.Error(err, "database error")
+		return false
 	}
+	return done
 }
 
-func (ai *ActivityInbox) Follow(w http.ResponseWriter, act activity.Activity, body []byte) {
+// claim records an activity as processed, returning false if it already was
+func (ai *ActivityInbox) claim(id string) bool {
+	if ai.processed == nil || id == "" {
+		return true
+	}
+	claimed, err := ai.processed.ClaimProcessed(id)
+	if err != nil {
+		// Better to risk handling an activity twice than not at all
+		telemetry.Error(err, "database error")
+		return true
+	}
+	return claimed
+}
+
+// release forgets a claimed activity so that it can be processed when retried
+func (ai *ActivityInbox) release(id string) {
+	if ai.processed == nil || id == "" {
+		return
+	}
+	if err := ai.processed.ReleaseProcessed(id); err != nil {
+		// Not fatal, but a retry would be skipped as a duplicate
+		telemetry.Error(err, "database error")
+	}
+}
+
+// sameHost returns true if two IDs are URLs on the same host
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host)
+}
+
+// storedRequest recreates the original http request for a stored inbox item
+// so the signature can be verified
+func storedRequest(ctx context.Context, item storage.InboxItem) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(ctx, item.Method, item.Path, strings.NewReader(item.Body))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(item.Headers), &r.Header); err != nil {
+		return nil, err
+	}
+	r.Host = item.Host
+	return r, nil
+}
+
+func (ai *ActivityInbox) Follow(act activity.Activity, body []byte) error {
 	// Yeesh this is more complex than I thought it would be
 
 	telemetry.Increment("follow_requests", 1)
@@ -130,8 +407,7 @@ func (ai *ActivityInbox) Follow(w http.ResponseWriter, act activity.Activity, bo
 		// Trying to follow someone other than the owner of this inbox, doesn't make sense.
 		// #ActivityPub There is no information about what to do in this situation in the spec.
 		message += " - rejected, wrong inbox"
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return reject("follow for [%s] at inbox [%s]", objectID, ai.id)
 	}
 
 	if act.ID == "" {
@@ -140,16 +416,13 @@ func (ai *ActivityInbox) Follow(w http.ResponseWriter, act activity.Activity, bo
 		// so the remote server knows what we're accepting.
 		act.ID = strings.Join([]string{objectID, actorID}, "-")
 		message += " - rejected, no follow id"
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return reject("follow has no id")
 	}
 
 	existing, err := ai.followers.FindFollow(actorID)
 	if err != nil {
-		message += " - rejected, database read error"
-		telemetry.Error(err, "database error")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		return
+		message += " - failed, database read error"
+		return fmt.Errorf("finding follower: %w", err)
 	}
 	if existing != nil {
 		// Already following, no need to do anything.
@@ -165,12 +438,10 @@ func (ai *ActivityInbox) Follow(w http.ResponseWriter, act activity.Activity, bo
 	var follow storage.Follow
 	followers, err := ai.followers.GetFollowers()
 	if err != nil {
-		message += " - rejected, database read error"
-		telemetry.Error(err, "database error")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		return
+		message += " - failed, database read error"
+		return fmt.Errorf("getting followers: %w", err)
 	}
-	if ai.service.config.Server.MaxFollowers == 0 || len(followers) < ai.service.config.Server.MaxFollowers {
+	if limit := ai.service.currentConfig().Server.MaxFollowers; limit == 0 || len(followers) < limit {
 		// Save the new follower. We mark it as "pending" until we successfully
 		// send an Accept request back to the remote server.
 		// We only accept it if it doesn't exceed the maximum followers.
@@ -184,6 +455,15 @@ func (ai *ActivityInbox) Follow(w http.ResponseWriter, act activity.Activity, bo
 			telemetry.Error(err, "database error")
 		}
 		responseType = activity.AcceptType
+		if existing == nil {
+			ai.notify(storage.Notification{
+				ID:       act.ID,
+				Type:     storage.NotifyFollow,
+				ActorID:  actorID,
+				ObjectID: objectID,
+			})
+			ai.emit(EventFollow, map[string]string{"actor": actorID})
+		}
 	}
 
 	// Queue a response.
@@ -200,7 +480,7 @@ func (ai *ActivityInbox) Follow(w http.ResponseWriter, act activity.Activity, bo
 	})
 
 	message += " - success"
-	w.WriteHeader(http.StatusOK)
+	return nil
 }
 
 type FollowResponse struct {
@@ -217,10 +497,10 @@ func (f *FollowResponse) String() string {
 	return fmt.Sprintf("Follow %s to %s", f.responseType, f.remoteID)
 }
 
-func (f *FollowResponse) Prepare(pipeline *OutputPipeline) (*http.Request, error) {
+func (f *FollowResponse) Prepare(ctx context.Context, pipeline *OutputPipeline) (*http.Request, error) {
 	// Lookup the follower's inbox
 	telemetry.Increment("actor_fetches", 1)
-	remote, err := f.inbox.service.GetActor(f.remoteID)
+	remote, err := f.inbox.service.GetActor(ctx, f.remoteID)
 	if err != nil {
 		return nil, fmt.Errorf("looking up remote actor: %w", err)
 	}
@@ -269,7 +549,8 @@ func (f *FollowResponse) Prepare(pipeline *OutputPipeline) (*http.Request, error
 
 func (f *FollowResponse) Receive(resp *http.Response) {
 	telemetry.Trace("received response from accept %d", resp.StatusCode)
-	if resp.StatusCode == http.StatusOK {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// Mastodon answers with 202 Accepted
 		telemetry.Increment("accept_responses", 1)
 		if f.responseType == activity.AcceptType {
 			// mark transaction was completed successfully
@@ -284,7 +565,7 @@ func (f *FollowResponse) Receive(resp *http.Response) {
 	}
 }
 
-func (ai *ActivityInbox) Unfollow(w http.ResponseWriter, undo activity.Activity, follow activity.Activity) {
+func (ai *ActivityInbox) Unfollow(undo activity.Activity, follow activity.Activity) error {
 	if ai.pipeline == nil {
 		panic("ActivityInbox pipeline missing")
 	}
@@ -305,22 +586,19 @@ func (ai *ActivityInbox) Unfollow(w http.ResponseWriter, undo activity.Activity,
 	if undo.ID == "" {
 		// We really should have an ID, but #ActivityPub doesn't tell us what to do if we don't.
 		message += " - rejected, no undo ID"
-		w.WriteHeader(http.StatusBadRequest)
+		return reject("undo has no id")
 	}
 
 	if objectID != ai.ownerID {
 		// Trying to follow someone other than the owner of this inbox, not allowed.
 		// #ActivityPub There is no information about what to do in this situation in the spec.
 		message += " - rejected, wrong inbox"
-		w.WriteHeader(http.StatusNotAcceptable)
-		return
+		return reject("unfollow for [%s] at inbox [%s]", objectID, ai.id)
 	}
 
 	if err := ai.followers.DeleteFollow(actorID); err != nil {
 		message += " - database delete error"
-		telemetry.Error(err, "database error")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		return
+		return fmt.Errorf("deleting follower: %w", err)
 	}
 
 	// Queue an Accept response.
@@ -334,8 +612,9 @@ func (ai *ActivityInbox) Unfollow(w http.ResponseWriter, undo activity.Activity,
 		responseType: activity.AcceptType,
 	})
 
+	ai.emit(EventUnfollow, map[string]string{"actor": actorID})
 	message += " - success"
-	w.WriteHeader(http.StatusOK)
+	return nil
 }
 
 type UnfollowResponse struct {
@@ -350,10 +629,10 @@ func (f *UnfollowResponse) String() string {
 	return fmt.Sprintf("Unfollow %s to %s", f.responseType, f.remoteID)
 }
 
-func (f *UnfollowResponse) Prepare(pipeline *OutputPipeline) (*http.Request, error) {
+func (f *UnfollowResponse) Prepare(ctx context.Context, pipeline *OutputPipeline) (*http.Request, error) {
 	// Lookup the follower's inbox
 	telemetry.Increment("actor_fetches", 1)
-	remote, err := f.inbox.service.GetActor(f.remoteID)
+	remote, err := f.inbox.service.GetActor(ctx, f.remoteID)
 	if err != nil {
 		return nil, fmt.Errorf("looking up remote actor: %w", err)
 	}
@@ -445,6 +724,22 @@ func parseID(v interface{}) (val string) {
 	return val
 }
 
+// parseIDs returns the ids of a property that may be a single id or object, or a list of them
+func parseIDs(v interface{}) (ids []string) {
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if id := parseID(item); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	if id := parseID(v); id != "" {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func readerUnmarshal(r io.Reader, v any) {
 	decoder := json.NewDecoder(r)
 	decoder.Decode(&v)
@@ -50,6 +50,7 @@ func TestInbox_Follow(t *testing.T) {
 
 	svc := ActivityService{
 		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		fetcher:    testFetcher(),
 	}
 
 	inbox := ActivityInbox{
@@ -80,6 +81,7 @@ func TestInbox_Follow(t *testing.T) {
 			ID:      remoteID,
 			Inbox:   remoteInbox.URL,
 		}
+		w.Header().Set("Content-Type", activity.ContentType)
 		w.WriteHeader(http.StatusOK)
 		w.Write(jsonBytes(&actor))
 	}))
@@ -105,8 +107,6 @@ func TestInbox_Follow(t *testing.T) {
 	}).Return(nil).Once()
 	inbox.followers = database
 
-	recorder := httptest.NewRecorder()
-
 	var follow activity.Activity
 	require.NoError(t, json.Unmarshal(body, &follow))
 
@@ -114,7 +114,7 @@ func TestInbox_Follow(t *testing.T) {
 	timeout := time.After(3 * time.Second)
 	done := make(chan bool)
 	go func() {
-		inbox.Follow(recorder, follow, body)
+		assert.NoError(t, inbox.Follow(follow, body))
 		done <- true
 	}()
 	select {
@@ -129,6 +129,44 @@ func TestInbox_Follow(t *testing.T) {
 	database.AssertExpectations(t)
 }
 
+func TestService_ApproveFollower(t *testing.T) {
+	svc := moderatedService(t)
+	svc.fetcher = testFetcher()
+	inbox := &svc.users[0].inbox
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go svc.pipeline.Run(ctx)
+
+	var accepted activity.Activity
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&accepted))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		actor := activity.Actor{Type: activity.PersonType, ID: remote.URL + r.URL.Path, Inbox: remote.URL + "/inbox"}
+		w.Header().Set("Content-Type", activity.ContentType)
+		w.Write(jsonBytes(&actor))
+	}))
+	defer remote.Close()
+	followerID := remote.URL + "/users/alice"
+	require.NoError(t, inbox.followers.SaveFollow(storage.Follow{ID: followerID, RequestID: remote.URL + "/follows/1", RequestStatus: "pending"}))
+
+	// the Accept is sent again, and the follow is accepted once it has been taken
+	require.NoError(t, svc.ApproveFollower(ctx, "test", followerID))
+	svc.pipeline.Flush()
+
+	assert.Equal(t, activity.AcceptType, accepted.Type)
+	assert.Equal(t, remote.URL+"/follows/1", parseID(accepted.Object))
+	follow, err := inbox.followers.FindFollow(followerID)
+	require.NoError(t, err)
+	require.NotNil(t, follow)
+	assert.Equal(t, "accepted", follow.RequestStatus)
+
+	assert.Error(t, svc.ApproveFollower(ctx, "test", remote.URL+"/users/bob"))
+}
+
 func TestInbox_Follow_MaxFollowers(t *testing.T) {
 	// Test that exceeding MaxFollowers sends a Reject activity
 	pipeline := NewPipeline()
@@ -146,6 +184,7 @@ func TestInbox_Follow_MaxFollowers(t *testing.T) {
 			},
 		},
 		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		fetcher:    testFetcher(),
 	}
 
 	inbox := ActivityInbox{
@@ -176,6 +215,7 @@ func TestInbox_Follow_MaxFollowers(t *testing.T) {
 			ID:      remoteID,
 			Inbox:   remoteInbox.URL,
 		}
+		w.Header().Set("Content-Type", activity.ContentType)
 		w.WriteHeader(http.StatusOK)
 		w.Write(jsonBytes(&actor))
 	}))
@@ -203,8 +243,6 @@ func TestInbox_Follow_MaxFollowers(t *testing.T) {
 	database.On("FindFollow", remoteID).Return(nil, nil).Once()
 	inbox.followers = database
 
-	recorder := httptest.NewRecorder()
-
 	var follow activity.Activity
 	require.NoError(t, json.Unmarshal(body, &follow))
 
@@ -212,7 +250,7 @@ func TestInbox_Follow_MaxFollowers(t *testing.T) {
 	timeout := time.After(3 * time.Second)
 	done := make(chan bool)
 	go func() {
-		inbox.Follow(recorder, follow, body)
+		assert.NoError(t, inbox.Follow(follow, body))
 		done <- true
 	}()
 	select {
@@ -239,6 +277,7 @@ func TestInbox_UnFollow(t *testing.T) {
 
 	svc := ActivityService{
 		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		fetcher:    testFetcher(),
 	}
 
 	inbox := ActivityInbox{
@@ -267,6 +306,7 @@ func TestInbox_UnFollow(t *testing.T) {
 			ID:      remoteID,
 			Inbox:   remoteInbox.URL,
 		}
+		w.Header().Set("Content-Type", activity.ContentType)
 		w.WriteHeader(http.StatusOK)
 		w.Write(jsonBytes(&actor))
 	}))
@@ -278,7 +318,6 @@ func TestInbox_UnFollow(t *testing.T) {
 	database.On("DeleteFollow", remoteID).Return(nil, nil).Once()
 	inbox.followers = database
 
-	recorder := httptest.NewRecorder()
 	follow := activity.Activity{
 		Type:   activity.FollowType,
 		Actor:  remoteID,
@@ -296,7 +335,7 @@ func TestInbox_UnFollow(t *testing.T) {
 	timeout := time.After(3 * time.Second)
 	done := make(chan bool)
 	go func() {
-		inbox.Unfollow(recorder, undo, follow)
+		assert.NoError(t, inbox.Unfollow(undo, follow))
 		done <- true
 	}()
 	select {
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+const (
+	inboxWorkers      = 4
+	inboxMaxAttempts  = 5
+	inboxRetryDelay   = 30 * time.Second // doubled after each failed attempt
+	inboxSweepPeriod  = 30 * time.Second // how often to look for items due for a retry
+	inboxProcessLimit = 60 * time.Second // time limit for processing one activity
+	inboxPurgePeriod  = time.Hour        // how often to forget old processed activity IDs
+	inboxMaxUnchecked = 100              // items from one sender waiting for their signature to be checked
+
+	defaultProcessedRetention = 7 * 24 * time.Hour
+	defaultInboxRetention     = 30 * 24 * time.Hour
+)
+
+// InboxQueue processes received activities in the background with a pool of workers.
+// Activities are persisted by the inbox before being queued, so anything not yet
+// processed is picked up again after a restart. Transient failures are retried with
+// an increasing delay.
+type InboxQueue struct {
+	retention      time.Duration // how long to remember processed activity IDs
+	inboxRetention time.Duration // how long to keep received activities
+	jobs           chan inboxJob
+	inboxes        []*ActivityInbox
+	lock           sync.Mutex
+	pending        map[string]bool // item IDs already queued or being processed
+	active         map[string]int  // workers using each inbox, by inbox id
+	idle           *sync.Cond      // signalled when a worker stops using an inbox
+	waitGroup      sync.WaitGroup
+}
+
+type inboxJob struct {
+	inbox *ActivityInbox
+	item  storage.InboxItem
+}
+
+// rejectedError marks an activity that can never be processed successfully, so it isn't retried
+type rejectedError struct {
+	reason string
+}
+
+func (e rejectedError) Error() string {
+	return e.reason
+}
+
+func reject(format string, args ...any) error {
+	return rejectedError{reason: fmt.Sprintf(format, args...)}
+}
+
+func NewInboxQueue() *InboxQueue {
+	q := &InboxQueue{
+		retention:      defaultProcessedRetention,
+		inboxRetention: defaultInboxRetention,
+		jobs:           make(chan inboxJob, 100),
+		pending:        make(map[string]bool),
+		active:         make(map[string]int),
+	}
+	q.idle = sync.NewCond(&q.lock)
+	return q
+}
+
+// Add registers an inbox whose stored items should be processed
+func (q *InboxQueue) Add(inbox *ActivityInbox) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.inboxes = append(q.inboxes, inbox)
+}
+
+// Set replaces the inboxes whose stored items should be processed
+func (q *InboxQueue) Set(inboxes []*ActivityInbox) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.inboxes = inboxes
+}
+
+// Drain blocks until no worker is using the inbox with the given id.
+// Once an inbox has been removed with Set, nothing starts using it again.
+func (q *InboxQueue) Drain(id string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.active[id] > 0 {
+		q.idle.Wait()
+	}
+}
+
+// enter returns the registered inbox with the same id, marked as in use until leave is called.
+// Returns nil if the inbox has been removed.
+func (q *InboxQueue) enter(inbox *ActivityInbox) *ActivityInbox {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, registered := range q.inboxes {
+		if registered.id == inbox.id {
+			q.active[inbox.id]++
+			return registered
+		}
+	}
+	return nil
+}
+
+func (q *InboxQueue) leave(inbox *ActivityInbox) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.active[inbox.id]--
+	if q.active[inbox.id] <= 0 {
+		delete(q.active, inbox.id)
+		q.idle.Broadcast()
+	}
+}
+
+// Queue an item for processing. Doesn't block; if the workers are too busy
+// the item stays in storage and is picked up by the next sweep.
+func (q *InboxQueue) Queue(inbox *ActivityInbox, item storage.InboxItem) {
+	q.lock.Lock()
+	if q.pending[item.ID] {
+		q.lock.Unlock()
+		return
+	}
+	q.pending[item.ID] = true
+	q.lock.Unlock()
+
+	q.waitGroup.Add(1)
+	select {
+	case q.jobs <- inboxJob{inbox: inbox, item: item}:
+	default:
+		telemetry.Increment("inbox_queue_full", 1)
+		q.done(item.ID)
+	}
+}
+
+// Flush blocks until all queued items have been processed once
+func (q *InboxQueue) Flush() {
+	q.waitGroup.Wait()
+}
+
+func (q *InboxQueue) done(id string) {
+	q.lock.Lock()
+	delete(q.pending, id)
+	q.lock.Unlock()
+	q.waitGroup.Done()
+}
+
+// Run starts the workers and periodically queues stored items that are due.
+// Expected to be run in a goroutine.
+func (q *InboxQueue) Run(ctx context.Context) {
+	telemetry.Trace("running inbox queue")
+	for i := 0; i < inboxWorkers; i++ {
+		go q.work(ctx)
+	}
+	ticker := time.NewTicker(inboxSweepPeriod)
+	defer ticker.Stop()
+	purgeTicker := time.NewTicker(inboxPurgePeriod)
+	defer purgeTicker.Stop()
+	q.sweep()
+	q.purge()
+	for {
+		select {
+		case <-ctx.Done():
+			telemetry.Log("inbox queue cancelled: %s", ctx.Err())
+			return
+		case <-ticker.C:
+			q.sweep()
+		case <-purgeTicker.C:
+			q.purge()
+		}
+	}
+}
+
+// sweep queues any stored items that are ready to be processed
+func (q *InboxQueue) sweep() {
+	q.lock.Lock()
+	inboxes := q.inboxes
+	q.lock.Unlock()
+	for _, inbox := range inboxes {
+		if inbox = q.enter(inbox); inbox == nil {
+			continue
+		}
+		items, err := inbox.items.GetDueInboxItems(time.Now().UTC())
+		q.leave(inbox)
+		if err != nil {
+			telemetry.Error(err, "database error")
+			continue
+		}
+		for _, item := range items {
+			q.Queue(inbox, item)
+		}
+	}
+}
+
+// purge forgets processed activity IDs and received activities older than their retention periods
+func (q *InboxQueue) purge() {
+	q.lock.Lock()
+	inboxes := q.inboxes
+	q.lock.Unlock()
+	now := time.Now().UTC()
+	for _, inbox := range inboxes {
+		if inbox = q.enter(inbox); inbox == nil {
+			continue
+		}
+		n, err := inbox.processed.PurgeProcessed(now.Add(-q.retention))
+		if err != nil {
+			telemetry.Error(err, "database error")
+		} else if n > 0 {
+			telemetry.Trace("forgot %d processed activities for inbox [%s]", n, inbox.id)
+		}
+		n, err = inbox.items.PurgeInboxItems(now.Add(-q.inboxRetention))
+		if err != nil {
+			telemetry.Error(err, "database error")
+		} else if n > 0 {
+			telemetry.Trace("purged %d received activities for inbox [%s]", n, inbox.id)
+		}
+		q.leave(inbox)
+	}
+}
+
+func (q *InboxQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-q.jobs:
+			// the inbox may have been replaced or removed by a reload since the item was queued
+			if inbox := q.enter(job.inbox); inbox != nil {
+				job.inbox = inbox
+				q.process(ctx, job)
+				q.leave(inbox)
+			}
+			q.done(job.item.ID)
+		}
+	}
+}
+
+// process an item and record the outcome, returning the processing error if any
+func (q *InboxQueue) process(ctx context.Context, job inboxJob) error {
+	ctx, cancel := context.WithTimeout(ctx, inboxProcessLimit)
+	defer cancel()
+
+	item := job.item
+	item.Attempts++
+	err := job.inbox.Process(ctx, &item)
+	if err == nil {
+		telemetry.Increment("inbox_processed", 1)
+		item.Status = storage.InboxDone
+		item.Error = ""
+		item.ProcessedAt = time.Now().UTC()
+		if err := job.inbox.items.SaveInboxItem(&item); err != nil {
+			telemetry.Error(err, "database error")
+		}
+		return nil
+	}
+
+	item.Error = err.Error()
+	item.ProcessedAt = time.Now().UTC()
+	var rejected rejectedError
+	if errors.As(err, &rejected) {
+		telemetry.Increment("inbox_rejected", 1)
+		telemetry.Log("inbox item %s %s rejected: %s", item.Type, item.ActivityID, err)
+		item.Status = storage.InboxRejected
+	} else if item.Attempts >= inboxMaxAttempts {
+		telemetry.Increment("inbox_failed", 1)
+		telemetry.Error(err, "inbox item %s %s failed after %d attempts", item.Type, item.ActivityID, item.Attempts)
+		item.Status = storage.InboxFailed
+	} else {
+		telemetry.Increment("inbox_retries", 1)
+		telemetry.Error(err, "inbox item %s %s failed, will retry", item.Type, item.ActivityID)
+		item.NextAttempt = time.Now().UTC().Add(inboxRetryDelay << (item.Attempts - 1))
+	}
+	if err := job.inbox.items.SaveInboxItem(&item); err != nil {
+		telemetry.Error(err, "database error")
+	}
+	return err
+}
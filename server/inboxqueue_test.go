@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+func TestInbox_PostHTTP_Queued(t *testing.T) {
+	queue := NewInboxQueue()
+	items := &mockInboxItems{}
+	items.On("SaveInboxItem", mock.MatchedBy(func(item *storage.InboxItem) bool {
+		return item.ActivityID == "follow_id" && item.Type == activity.FollowType &&
+			item.Status == storage.InboxQueued && item.Body != "" && item.Headers != ""
+	})).Return(nil).Once()
+	items.On("CountUnverified", "remote.example").Return(0, nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		items:          items,
+		queue:          queue,
+		acceptUnsigned: true,
+	}
+
+	body := fmt.Sprintf(`{"type":%q,"id":"follow_id","actor":"https://remote.example/users/a","object":"local"}`, activity.FollowType)
+	r := httptest.NewRequest("POST", "/activity/test/inbox", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	inbox.PostHTTP(recorder, r)
+
+	assert.Equal(t, http.StatusAccepted, recorder.Result().StatusCode)
+	require.Len(t, queue.jobs, 1)
+	job := <-queue.jobs
+	assert.Equal(t, body, job.item.Body)
+	items.AssertExpectations(t)
+}
+
+func TestInbox_PostHTTP_Throttled(t *testing.T) {
+	items := &mockInboxItems{}
+	items.On("CountUnverified", "remote.example").Return(inboxMaxUnchecked, nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		items:          items,
+		queue:          NewInboxQueue(),
+		acceptUnsigned: true,
+	}
+
+	body := fmt.Sprintf(`{"type":%q,"id":"follow_id","actor":"https://Remote.example/users/a","object":"local"}`, activity.FollowType)
+	r := httptest.NewRequest("POST", "/activity/test/inbox", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	inbox.PostHTTP(recorder, r)
+
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Result().StatusCode)
+	assert.Len(t, inbox.queue.jobs, 0)
+	items.AssertExpectations(t)
+	items.AssertNotCalled(t, "SaveInboxItem", mock.Anything)
+}
+
+func TestInbox_PostHTTP_Rejected(t *testing.T) {
+	inbox := ActivityInbox{
+		id:    "test",
+		items: &mockInboxItems{},
+		queue: NewInboxQueue(),
+	}
+
+	// unsigned
+	body := fmt.Sprintf(`{"type":%q,"id":"follow_id","actor":"remote","object":"local"}`, activity.FollowType)
+	r := httptest.NewRequest("POST", "/activity/test/inbox", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	inbox.PostHTTP(recorder, r)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Result().StatusCode)
+
+	// unknown activity type
+	inbox.acceptUnsigned = true
+	r = httptest.NewRequest("POST", "/activity/test/inbox", bytes.NewBufferString(`{"type":"Dance"}`))
+	recorder = httptest.NewRecorder()
+	inbox.PostHTTP(recorder, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Result().StatusCode)
+
+	// actor isn't a url
+	body = fmt.Sprintf(`{"type":%q,"id":"follow_id","actor":"remote\r\nBcc: x@y","object":"local"}`, activity.FollowType)
+	r = httptest.NewRequest("POST", "/activity/test/inbox", bytes.NewBufferString(body))
+	recorder = httptest.NewRecorder()
+	inbox.PostHTTP(recorder, r)
+	assert.Equal(t, http.StatusBadRequest, recorder.Result().StatusCode)
+	assert.Len(t, inbox.queue.jobs, 0)
+}
+
+func TestInboxQueue_Retry(t *testing.T) {
+	followers := &mockFollowers{}
+	followers.On("FindFollow", "remote").Return(nil, errors.New("database is locked")).Once()
+	items := &mockInboxItems{}
+	items.On("SaveInboxItem", mock.MatchedBy(func(item *storage.InboxItem) bool {
+		return item.Status == storage.InboxQueued && item.Attempts == 1 &&
+			item.NextAttempt.After(time.Now()) && item.Error != ""
+	})).Return(nil).Once()
+	// the claim is given up so the retry isn't skipped as a duplicate
+	processed := &mockProcessed{}
+	processed.On("ClaimProcessed", "follow_id").Return(true, nil).Once()
+	processed.On("ReleaseProcessed", "follow_id").Return(nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		ownerID:        "local",
+		followers:      followers,
+		items:          items,
+		processed:      processed,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	queue := NewInboxQueue()
+	queue.process(context.Background(), inboxJob{
+		inbox: &inbox,
+		item: storage.InboxItem{
+			ID:     "item",
+			Status: storage.InboxQueued,
+			Body:   fmt.Sprintf(`{"type":%q,"id":"follow_id","actor":"remote","object":"local"}`, activity.FollowType),
+		},
+	})
+
+	followers.AssertExpectations(t)
+	items.AssertExpectations(t)
+	processed.AssertExpectations(t)
+}
+
+func TestInbox_Process_Claim(t *testing.T) {
+	svc := moderatedService(t)
+	inbox := &svc.users[0].inbox
+	inbox.processed = svc.users[0].store.(storage.ProcessedActivities)
+
+	// only the first of two deliveries gets to handle it
+	assert.True(t, inbox.claim("https://remote/activities/1"))
+	assert.False(t, inbox.claim("https://remote/activities/1"))
+	inbox.release("https://remote/activities/1")
+	assert.True(t, inbox.claim("https://remote/activities/1"))
+
+	// an activity can't reuse an ID from another server
+	forged := `{"type":"Like","id":"https://blog/activities/1","actor":"https://remote/users/alice","object":"https://blog/post"}`
+	err := inbox.Process(context.Background(), &storage.InboxItem{Body: forged})
+	assert.ErrorAs(t, err, &rejectedError{})
+	done, err := inbox.processed.IsProcessed("https://blog/activities/1")
+	require.NoError(t, err)
+	assert.False(t, done)
+}
+
+func TestInboxQueue_Reject(t *testing.T) {
+	items := &mockInboxItems{}
+	items.On("SaveInboxItem", mock.MatchedBy(func(item *storage.InboxItem) bool {
+		return item.Status == storage.InboxRejected && item.Attempts == 1
+	})).Return(nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		ownerID:        "local",
+		items:          items,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	// following someone else will never work
+	queue := NewInboxQueue()
+	queue.process(context.Background(), inboxJob{
+		inbox: &inbox,
+		item: storage.InboxItem{
+			ID:     "item",
+			Status: storage.InboxQueued,
+			Body:   fmt.Sprintf(`{"type":%q,"id":"follow_id","actor":"remote","object":"someone_else"}`, activity.FollowType),
+		},
+	})
+
+	items.AssertExpectations(t)
+}
+
+func TestInbox_PostHTTP_Duplicate(t *testing.T) {
+	processed := &mockProcessed{}
+	processed.On("IsProcessed", "follow_id").Return(true, nil).Once()
+
+	items := &mockInboxItems{}
+	items.On("SaveInboxItem", mock.MatchedBy(func(item *storage.InboxItem) bool {
+		return item.Status == storage.InboxDuplicate && item.Body != ""
+	})).Return(nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		items:          items,
+		processed:      processed,
+		queue:          NewInboxQueue(),
+		acceptUnsigned: true,
+	}
+
+	body := fmt.Sprintf(`{"type":%q,"id":"follow_id","actor":"https://remote/users/a","object":"local"}`, activity.FollowType)
+	r := httptest.NewRequest("POST", "/activity/test/inbox", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	inbox.PostHTTP(recorder, r)
+
+	// acknowledged and recorded, but not queued
+	assert.Equal(t, http.StatusAccepted, recorder.Result().StatusCode)
+	assert.Len(t, inbox.queue.jobs, 0)
+	processed.AssertExpectations(t)
+	items.AssertExpectations(t)
+}
+
+func TestService_Reprocess(t *testing.T) {
+	processed := &mockProcessed{}
+	processed.On("ClaimProcessed", "follow_id").Return(false, nil).Once()
+	items := &mockInboxItems{}
+	items.On("FindInboxItems", []string{storage.InboxRejected, storage.InboxFailed}, time.Time{}).Return([]storage.InboxItem{{
+		ID:     "item",
+		Status: storage.InboxFailed,
+		Body:   fmt.Sprintf(`{"type":%q,"id":"follow_id","actor":"remote","object":"local"}`, activity.FollowType),
+	}}, nil).Once()
+	items.On("SaveInboxItem", mock.MatchedBy(func(item *storage.InboxItem) bool {
+		return item.ID == "item" && item.Status == storage.InboxDone && item.Error == ""
+	})).Return(nil).Once()
+
+	svc := ActivityService{
+		pipeline:   NewPipeline(),
+		inboxQueue: NewInboxQueue(),
+		users: []ActivityUser{{
+			name: "test",
+			inbox: ActivityInbox{
+				id:             "test",
+				ownerID:        "local",
+				items:          items,
+				processed:      processed,
+				pipeline:       NewPipeline(),
+				acceptUnsigned: true,
+			},
+		}},
+	}
+
+	succeeded, failed, err := svc.Reprocess(context.Background(), ReprocessFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, 0, failed)
+
+	_, _, err = svc.Reprocess(context.Background(), ReprocessFilter{User: "nobody"})
+	assert.Error(t, err)
+
+	items.AssertExpectations(t)
+	processed.AssertExpectations(t)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// KeyStore manages the signing keypairs of local users as PEM files in a directory.
+// Private keys are written readable only by the owner of the process.
+type KeyStore struct {
+	dir string
+}
+
+// keyBits is the RSA key size. 2048 is what Mastodon generates, so it's the safest for interop.
+const keyBits = 2048
+
+const defaultKeyDir = "keys"
+
+func NewKeyStore(dir string) KeyStore {
+	if dir == "" {
+		dir = defaultKeyDir
+	}
+	return KeyStore{dir: dir}
+}
+
+// Dir returns the directory where keys are stored
+func (k KeyStore) Dir() string {
+	return k.dir
+}
+
+func (k KeyStore) privatePath(name string) string {
+	return filepath.Join(k.dir, fmt.Sprintf("%s.pem", name))
+}
+
+func (k KeyStore) publicPath(name string) string {
+	return filepath.Join(k.dir, fmt.Sprintf("%s.pub.pem", name))
+}
+
+// Load reads an existing keypair for the named user.
+// Returns an error wrapping os.ErrNotExist if the user has no keys yet.
+func (k KeyStore) Load(name string) (crypto.PrivateKey, string, error) {
+	privPath := k.privatePath(name)
+	info, err := os.Stat(privPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		telemetry.Log("WARNING: private key [%s] is readable by other users, consider chmod 600", privPath)
+	}
+	b, err := os.ReadFile(privPath)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := decodePrivateKey(b)
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding private key [%s]: %w", privPath, err)
+	}
+
+	pub, err := os.ReadFile(k.publicPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		// The public key can always be recreated from the private key
+		s, err := encodePublicKey(key)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := k.write(k.publicPath(name), []byte(s), 0644); err != nil {
+			return nil, "", err
+		}
+		return key, s, nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	return key, string(pub), nil
+}
+
+// LoadOrGenerate reads the keypair for the named user, creating one if none exists yet
+func (k KeyStore) LoadOrGenerate(name string) (crypto.PrivateKey, string, error) {
+	key, pub, err := k.Load(name)
+	if errors.Is(err, os.ErrNotExist) {
+		telemetry.Log("generating new keypair for user %s in [%s]", name, k.dir)
+		return k.Generate(name)
+	}
+	return key, pub, err
+}
+
+// Generate creates and saves a new keypair for the named user, replacing any existing one
+func (k KeyStore) Generate(name string) (crypto.PrivateKey, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, "", fmt.Errorf("generating rsa key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshaling private key: %w", err)
+	}
+	return k.save(name, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// Import saves a PEM-encoded private key for the named user, replacing any existing one.
+// The public key is derived from the private key.
+func (k KeyStore) Import(name string, privPem []byte) (crypto.PrivateKey, string, error) {
+	if _, err := decodePrivateKey(privPem); err != nil {
+		return nil, "", err
+	}
+	return k.save(name, privPem)
+}
+
+// Rotate replaces the keypair of the named user with a new one.
+// The previous keys are kept alongside with an .old suffix.
+func (k KeyStore) Rotate(name string) (crypto.PrivateKey, string, error) {
+	for _, p := range []string{k.privatePath(name), k.publicPath(name)} {
+		if err := os.Rename(p, p+".old"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("backing up [%s]: %w", p, err)
+		}
+	}
+	return k.Generate(name)
+}
+
+// PrivateKeyPEM returns the raw PEM file contents of a user's private key
+func (k KeyStore) PrivateKeyPEM(name string) ([]byte, error) {
+	return os.ReadFile(k.privatePath(name))
+}
+
+func (k KeyStore) save(name string, privPem []byte) (crypto.PrivateKey, string, error) {
+	key, err := decodePrivateKey(privPem)
+	if err != nil {
+		return nil, "", err
+	}
+	pub, err := encodePublicKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := k.write(k.privatePath(name), privPem, 0600); err != nil {
+		return nil, "", err
+	}
+	if err := k.write(k.publicPath(name), []byte(pub), 0644); err != nil {
+		return nil, "", err
+	}
+	return key, pub, nil
+}
+
+func (k KeyStore) write(filename string, b []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(k.dir, 0700); err != nil {
+		return fmt.Errorf("creating key directory [%s]: %w", k.dir, err)
+	}
+	if err := os.WriteFile(filename, b, perm); err != nil {
+		return fmt.Errorf("writing key file [%s]: %w", filename, err)
+	}
+	// WriteFile doesn't change the permissions of an existing file
+	return os.Chmod(filename, perm)
+}
+
+// encodePublicKey returns the PEM-encoded public half of a private key
+func encodePublicKey(key crypto.PrivateKey) (string, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return "", fmt.Errorf("unsupported private key type %T", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return "", fmt.Errorf("marshaling public key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// loadUserKeys finds the keypair for a configured user.
+// Explicitly configured key files take precedence, otherwise keys are
+// loaded from (or generated into) the key store.
+func loadUserKeys(keys KeyStore, usercfg userConfig) (crypto.PrivateKey, string, error) {
+	if usercfg.PrivKeyFile == "" {
+		return keys.LoadOrGenerate(usercfg.Name)
+	}
+
+	der, err := os.ReadFile(usercfg.PrivKeyFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading private key file [%s]: %w", usercfg.PrivKeyFile, err)
+	}
+	key, err := decodePrivateKey(der)
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding private key [%s]: %w", usercfg.PrivKeyFile, err)
+	}
+
+	if usercfg.PubKeyFile == "" {
+		pub, err := encodePublicKey(key)
+		return key, pub, err
+	}
+	b, err := os.ReadFile(usercfg.PubKeyFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading public key file [%s]: %w", usercfg.PubKeyFile, err)
+	}
+	return key, string(b), nil
+}
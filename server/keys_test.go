@@ -0,0 +1,61 @@
+package server
+
+import (
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyStore_LoadOrGenerate(t *testing.T) {
+	keys := NewKeyStore(filepath.Join(t.TempDir(), "keys"))
+
+	key1, pub1, err := keys.LoadOrGenerate("test")
+	require.NoError(t, err)
+	assert.IsType(t, &rsa.PrivateKey{}, key1)
+	assert.Contains(t, pub1, "BEGIN PUBLIC KEY")
+
+	// private key should only be readable by the owner
+	info, err := os.Stat(keys.privatePath("test"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// second call should load the same keys
+	key2, pub2, err := keys.LoadOrGenerate("test")
+	require.NoError(t, err)
+	assert.True(t, key1.(*rsa.PrivateKey).Equal(key2))
+	assert.Equal(t, pub1, pub2)
+}
+
+func TestKeyStore_Rotate(t *testing.T) {
+	keys := NewKeyStore(t.TempDir())
+
+	_, pub1, err := keys.Generate("test")
+	require.NoError(t, err)
+	_, pub2, err := keys.Rotate("test")
+	require.NoError(t, err)
+	assert.NotEqual(t, pub1, pub2)
+
+	old, err := os.ReadFile(keys.publicPath("test") + ".old")
+	require.NoError(t, err)
+	assert.Equal(t, pub1, string(old))
+}
+
+func TestKeyStore_Import(t *testing.T) {
+	source := NewKeyStore(t.TempDir())
+	_, pub, err := source.Generate("test")
+	require.NoError(t, err)
+	privPem, err := source.PrivateKeyPEM("test")
+	require.NoError(t, err)
+
+	keys := NewKeyStore(t.TempDir())
+	_, imported, err := keys.Import("other", privPem)
+	require.NoError(t, err)
+	assert.Equal(t, pub, imported)
+
+	_, _, err = keys.Import("other", []byte("not a key"))
+	assert.Error(t, err)
+}
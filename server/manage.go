@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// Followers returns a user's followers
+func (s *ActivityService) Followers(name string) ([]storage.Follow, error) {
+	user := s.findUser(name)
+	if user == nil {
+		return nil, fmt.Errorf("no user [%s]", name)
+	}
+	return user.inbox.followers.GetFollowers()
+}
+
+// RemoveFollower stops sending a user's notes to a follower, given as an actor ID or handle.
+// The follower isn't told, so block them too if they shouldn't come back.
+func (s *ActivityService) RemoveFollower(ctx context.Context, name string, target string) error {
+	user := s.findUser(name)
+	if user == nil {
+		return fmt.Errorf("no user [%s]", name)
+	}
+	id, err := s.resolveActor(ctx, target)
+	if err != nil {
+		return err
+	}
+	follow, err := user.inbox.followers.FindFollow(id)
+	if err != nil {
+		return err
+	}
+	if follow == nil {
+		return fmt.Errorf("[%s] doesn't follow [%s]", id, name)
+	}
+	if err := user.inbox.followers.DeleteFollow(id); err != nil {
+		return err
+	}
+	telemetry.Log("removed follower [%s] of user %s", id, name)
+	return nil
+}
+
+// ApproveFollower sends an Accept for a pending follow again.
+// Follows stay pending until the remote server acknowledges our Accept,
+// and are marked accepted when it does.
+func (s *ActivityService) ApproveFollower(ctx context.Context, name string, target string) error {
+	user := s.findUser(name)
+	if user == nil {
+		return fmt.Errorf("no user [%s]", name)
+	}
+	id, err := s.resolveActor(ctx, target)
+	if err != nil {
+		return err
+	}
+	follow, err := user.inbox.followers.FindFollow(id)
+	if err != nil {
+		return err
+	}
+	if follow == nil {
+		return fmt.Errorf("[%s] doesn't follow [%s]", id, name)
+	}
+	// The Follow itself isn't kept, but its id is what the Accept refers to
+	object, err := json.Marshal(map[string]string{
+		"type":   activity.FollowType,
+		"id":     follow.RequestID,
+		"actor":  id,
+		"object": user.inbox.ownerID,
+	})
+	if err != nil {
+		return err
+	}
+	user.inbox.pipeline.QueueLater(&FollowResponse{
+		inbox:        &user.inbox,
+		object:       object,
+		follow:       *follow,
+		followID:     follow.RequestID,
+		remoteID:     id,
+		localID:      user.inbox.ownerID,
+		responseType: activity.AcceptType,
+	})
+	telemetry.Log("approving follower [%s] of user %s", id, name)
+	return nil
+}
+
+// Notes returns a user's latest notes
+func (s *ActivityService) Notes(name string, limit int) ([]storage.Note, error) {
+	user := s.findUser(name)
+	if user == nil {
+		return nil, fmt.Errorf("no user [%s]", name)
+	}
+	return user.outbox.notes.GetLatestNotes(limit)
+}
+
+// ResendNote queues a note to be sent to all of a user's followers again
+// and returns how many followers it was queued for
+func (s *ActivityService) ResendNote(name string, id string) (int, error) {
+	user := s.findUser(name)
+	if user == nil {
+		return 0, fmt.Errorf("no user [%s]", name)
+	}
+	note, err := user.outbox.notes.FindNote(id)
+	if err != nil {
+		return 0, err
+	}
+	if note == nil {
+		return 0, fmt.Errorf("no note [%s]", id)
+	}
+	followers, err := user.outbox.followers.GetFollowers()
+	if err != nil {
+		return 0, err
+	}
+	for _, follower := range followers {
+		user.outbox.pipeline.QueueLater(user.outbox.noteActivity(*note, user.outbox.newDelivery(*note, follower.ID)))
+	}
+	return len(followers), nil
+}
+
+// Deliveries returns the note delivery log, newest first
+func (s *ActivityService) Deliveries(statuses []string, limit int) ([]storage.Delivery, error) {
+	if s.deliveries == nil {
+		return nil, errors.New("no database")
+	}
+	return s.deliveries.GetDeliveries(statuses, limit)
+}
+
+// RetryDelivery queues a logged note delivery to be sent again
+func (s *ActivityService) RetryDelivery(id string) error {
+	if s.deliveries == nil {
+		return errors.New("no database")
+	}
+	delivery, err := s.deliveries.FindDelivery(id)
+	if err != nil {
+		return err
+	}
+	if delivery == nil {
+		return fmt.Errorf("no delivery [%s]", id)
+	}
+	user := s.findUser(delivery.User)
+	if user == nil {
+		return fmt.Errorf("no user [%s]", delivery.User)
+	}
+	note, err := user.outbox.notes.FindNote(delivery.ObjectID)
+	if err != nil {
+		return err
+	}
+	if note == nil {
+		return fmt.Errorf("no note [%s]", delivery.ObjectID)
+	}
+	delivery.Status = storage.DeliveryPending
+	user.outbox.saveDelivery(delivery)
+	user.outbox.pipeline.QueueLater(user.outbox.noteActivity(*note, *delivery))
+	return nil
+}
+
+// CachedActor is a remote actor in the memory cache
+type CachedActor struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Inbox   string    `json:"inbox"`
+	Expires time.Time `json:"expires"`
+}
+
+// CachedActors returns the remote actors in the memory cache
+func (s *ActivityService) CachedActors() []CachedActor {
+	list := make([]CachedActor, 0, s.actorCache.ItemCount())
+	s.actorCache.ForEachFunc(func(key string, item *ccache.Item[activity.Actor]) bool {
+		actor := item.Value()
+		list = append(list, CachedActor{ID: key, Name: actor.Name, Inbox: actor.Inbox, Expires: item.Expires()})
+		return true
+	})
+	return list
+}
+
+// StoredActors returns how many remote actors are kept in the database
+func (s *ActivityService) StoredActors() (int64, error) {
+	if s.actors == nil {
+		return 0, errors.New("no database")
+	}
+	return s.actors.CountActors()
+}
+
+// EvictActor removes a remote actor from the cache and database, so it is fetched fresh when next needed
+func (s *ActivityService) EvictActor(id string) {
+	s.forgetActor(id)
+	telemetry.Log("evicted actor [%s]", id)
+}
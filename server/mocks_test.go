@@ -1,10 +1,17 @@
 package server
 
 import (
+	"time"
+
 	"github.com/stretchr/testify/mock"
 	"github.com/tkrehbiel/activitylace/server/storage"
 )
 
+// testFetcher allows fetching from httptest servers
+func testFetcher() *Fetcher {
+	return NewFetcher([]string{"127.0.0.1", "::1"})
+}
+
 type mockFollowers struct {
 	mock.Mock
 }
@@ -39,6 +46,11 @@ type mockNotes struct {
 	mock.Mock
 }
 
+func (m *mockNotes) CountNotes() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *mockNotes) GetLatestNotes(n int) (notes []storage.Note, err error) {
 	args := m.Called(n)
 	if l, ok := args.Get(0).([]storage.Note); ok {
@@ -59,3 +71,187 @@ func (m *mockNotes) SaveNote(n *storage.Note) error {
 	args := m.Called(n)
 	return args.Error(0)
 }
+
+type mockActors struct {
+	mock.Mock
+}
+
+func (m *mockActors) CountActors() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockActors) FindActor(id string) (*storage.Actor, error) {
+	args := m.Called(id)
+	if a, ok := args.Get(0).(*storage.Actor); ok {
+		return a, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockActors) SaveActor(a *storage.Actor) error {
+	args := m.Called(a)
+	return args.Error(0)
+}
+
+type mockInboxItems struct {
+	mock.Mock
+}
+
+func (m *mockInboxItems) SaveInboxItem(item *storage.InboxItem) error {
+	args := m.Called(item)
+	return args.Error(0)
+}
+
+func (m *mockInboxItems) DeleteInboxItem(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockInboxItems) GetDueInboxItems(now time.Time) ([]storage.InboxItem, error) {
+	args := m.Called(now)
+	if l, ok := args.Get(0).([]storage.InboxItem); ok {
+		return l, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type mockProcessed struct {
+	mock.Mock
+}
+
+func (m *mockProcessed) IsProcessed(id string) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockProcessed) ClaimProcessed(id string) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockProcessed) ReleaseProcessed(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockProcessed) PurgeProcessed(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+type mockReplies struct {
+	mock.Mock
+}
+
+func (m *mockReplies) GetReplies(inReplyTo string, states []string) ([]storage.Reply, error) {
+	args := m.Called(inReplyTo, states)
+	if l, ok := args.Get(0).([]storage.Reply); ok {
+		return l, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReplies) FindReply(id string) (*storage.Reply, error) {
+	args := m.Called(id)
+	if r, ok := args.Get(0).(*storage.Reply); ok {
+		return r, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReplies) SaveReply(r *storage.Reply) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *mockInboxItems) FindInboxItem(id string) (*storage.InboxItem, error) {
+	args := m.Called(id)
+	if item, ok := args.Get(0).(*storage.InboxItem); ok {
+		return item, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockInboxItems) FindInboxItems(statuses []string, since time.Time) ([]storage.InboxItem, error) {
+	args := m.Called(statuses, since)
+	if l, ok := args.Get(0).([]storage.InboxItem); ok {
+		return l, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockInboxItems) PurgeInboxItems(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+type mockReactions struct {
+	mock.Mock
+}
+
+func (m *mockReactions) FindReaction(id string) (*storage.Reaction, error) {
+	args := m.Called(id)
+	if r, ok := args.Get(0).(*storage.Reaction); ok {
+		return r, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReactions) SaveReaction(r *storage.Reaction) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *mockReactions) DeleteReaction(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockReactions) CountReactions(objectID string, reactionType string) (int, error) {
+	args := m.Called(objectID, reactionType)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockActors) DeleteActor(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockReplies) DeleteActorReplies(actorID string) (int64, error) {
+	args := m.Called(actorID)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+func (m *mockReactions) DeleteActorReactions(actorID string) (int64, error) {
+	args := m.Called(actorID)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+func (m *mockReplies) SetActorRepliesState(actorID string, state string) (int64, error) {
+	args := m.Called(actorID, state)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+func (m *mockReplies) SetDomainRepliesState(domain string, state string) (int64, error) {
+	args := m.Called(domain, state)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+func (m *mockReplies) FindReplies(states []string) ([]storage.Reply, error) {
+	args := m.Called(states)
+	if l, ok := args.Get(0).([]storage.Reply); ok {
+		return l, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReplies) CountActorReplies(actorID string, states []string) (int, error) {
+	args := m.Called(actorID, states)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockInboxItems) CountUnverified(sender string) (int64, error) {
+	args := m.Called(sender)
+	return int64(args.Int(0)), args.Error(1)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+// matchesActor returns true if the actor ID or its domain is in the list
+func matchesActor(list []string, actorID string) bool {
+	var domain string
+	if u, err := url.Parse(actorID); err == nil {
+		domain = u.Hostname()
+	}
+	for _, entry := range list {
+		if entry == actorID || (domain != "" && strings.EqualFold(entry, domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// replyState decides the moderation state of a new reply from an actor
+func (ai *ActivityInbox) replyState(actorID string) (string, error) {
+	if matchesActor(ai.policy.Trusted, actorID) {
+		return storage.ReplyVisible, nil
+	}
+	if matchesActor(ai.policy.Hidden, actorID) {
+		return storage.ReplyHidden, nil
+	}
+	if ai.policy.ApproveFirst {
+		// Once a moderator approves one reply, the actor is trusted
+		n, err := ai.replies.CountActorReplies(actorID, []string{storage.ReplyVisible})
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return storage.ReplyPending, nil
+		}
+	}
+	return storage.ReplyVisible, nil
+}
+
+// UserReply is a stored reply and the local user it replied to
+type UserReply struct {
+	storage.Reply
+	User string `json:"user"`
+}
+
+// Replies returns the replies to all users with any of the given moderation states, newest first
+func (s *ActivityService) Replies(states ...string) ([]UserReply, error) {
+	var list []UserReply
+	users := s.currentUsers()
+	for i := range users {
+		replies, err := users[i].inbox.replies.FindReplies(states)
+		if err != nil {
+			return nil, err
+		}
+		for _, reply := range replies {
+			reply.State = reply.ModerationState()
+			list = append(list, UserReply{Reply: reply, User: users[i].name})
+		}
+	}
+	return list, nil
+}
+
+// SetReplyState changes the moderation state of a reply
+func (s *ActivityService) SetReplyState(id string, state string) error {
+	switch state {
+	case storage.ReplyVisible, storage.ReplyPending, storage.ReplyHidden, storage.ReplyRejected:
+	default:
+		return fmt.Errorf("unknown reply state %s", state)
+	}
+	reply, err := s.findReply(id)
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return fmt.Errorf("no reply %s", id)
+	}
+	return s.setReplyState(id, state)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+func TestInbox_ReplyPolicy(t *testing.T) {
+	svc := moderatedService(t)
+	inbox := &svc.users[0].inbox
+	inbox.policy = replyPolicy{
+		ApproveFirst: true,
+		Trusted:      []string{"friends.example"},
+		Hidden:       []string{"https://remote/users/troll"},
+	}
+	require.NoError(t, inbox.notes.SaveNote(&storage.Note{ID: "https://blog/post"}))
+
+	reply := func(id string, actor string) string {
+		require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{Body: fmt.Sprintf(`{
+			"type":"Create","id":"%s/activity","actor":%q,"to":["https://www.w3.org/ns/activitystreams#Public"],
+			"object":{"type":"Note","id":%q,"attributedTo":%q,"inReplyTo":"https://blog/post","content":"<p>hi</p>"}}`,
+			id, actor, id, actor)}))
+		stored, err := inbox.replies.FindReply(id)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		return stored.ModerationState()
+	}
+
+	assert.Equal(t, storage.ReplyVisible, reply("https://friends.example/notes/1", "https://friends.example/users/a"))
+	assert.Equal(t, storage.ReplyHidden, reply("https://remote/notes/troll", "https://remote/users/troll"))
+
+	// first-time repliers wait for approval, until a reply is approved
+	assert.Equal(t, storage.ReplyPending, reply("https://remote/notes/1", "https://remote/users/new"))
+	assert.Equal(t, storage.ReplyPending, reply("https://remote/notes/2", "https://remote/users/new"))
+	require.NoError(t, svc.SetReplyState("https://remote/notes/1", storage.ReplyVisible))
+	assert.Equal(t, storage.ReplyVisible, reply("https://remote/notes/3", "https://remote/users/new"))
+
+	pending, err := svc.Replies(storage.ReplyPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "https://remote/notes/2", pending[0].ID)
+	assert.Equal(t, "test", pending[0].User)
+
+	assert.Error(t, svc.SetReplyState("https://remote/notes/2", "maybe"))
+	assert.Error(t, svc.SetReplyState("https://remote/notes/none", storage.ReplyRejected))
+}
+
+func TestOutbox_RepliesHTTP(t *testing.T) {
+	svc := moderatedService(t)
+	inbox := &svc.users[0].inbox
+	outbox := ActivityOutbox{notes: inbox.notes, replies: inbox.replies}
+	require.NoError(t, inbox.notes.SaveNote(&storage.Note{ID: "https://blog/post"}))
+	for id, state := range map[string]string{
+		"visible":  "",
+		"hidden":   storage.ReplyHidden,
+		"pending":  storage.ReplyPending,
+		"rejected": storage.ReplyRejected,
+	} {
+		require.NoError(t, inbox.replies.SaveReply(&storage.Reply{
+			ID:        id,
+			InReplyTo: "https://blog/post",
+			ActorID:   "https://remote/users/a",
+			Content:   "<p>reply <b>" + id + "</b> <script>alert(1)</script></p>",
+			Published: time.Now(),
+			State:     state,
+		}))
+	}
+
+	r := httptest.NewRequest("GET", "/profile/test/replies?id=https%3A%2F%2Fblog%2Fpost", nil)
+	recorder := httptest.NewRecorder()
+	outbox.RepliesHTTP(recorder, r)
+	require.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "<p>reply <b>visible</b> </p>")
+	assert.Contains(t, body, "<details><summary>Hidden reply, click to show</summary>")
+	assert.Contains(t, body, "reply <b>hidden</b>")
+	assert.NotContains(t, body, "pending")
+	assert.NotContains(t, body, "rejected")
+	assert.NotContains(t, body, "<script>")
+
+	// custom emoji stored in a reply are shown
+	require.NoError(t, inbox.replies.SaveReply(&storage.Reply{
+		ID:        "emoji",
+		InReplyTo: "https://blog/post",
+		ActorID:   "https://remote/users/a",
+		Content:   sanitizeHTML("<p>nice :blobcat:</p>", map[string]string{":blobcat:": "https://remote/emoji/blobcat.png"}),
+		Published: time.Now(),
+	}))
+	recorder = httptest.NewRecorder()
+	outbox.RepliesHTTP(recorder, r)
+	require.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+	assert.Contains(t, recorder.Body.String(), `<p>nice <img src="https://remote/emoji/blobcat.png" alt=":blobcat:" title=":blobcat:" class="emoji" width="20" height="20"></p>`)
+}
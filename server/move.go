@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// setting name for the account a local user moved to
+const movedToSetting = "movedTo"
+
+// Move handles a Move activity from a follower who moved to another account.
+// The follow is migrated if the new account confirms it used to be the old one.
+func (ai *ActivityInbox) Move(ctx context.Context, act activity.Activity) error {
+	telemetry.Increment("move_requests", 1)
+
+	actorID := parseID(act.Actor)
+	objectID := parseID(act.Object)
+	targetID := parseID(act.Target)
+
+	var message = fmt.Sprintf("POST move [%s] to [%s] by [%s] at inbox [%s]", objectID, targetID, actorID, ai.id)
+	defer func() {
+		telemetry.Log(message)
+	}()
+
+	if objectID != actorID {
+		// Actors can only move themselves
+		message += " - rejected, wrong actor"
+		return reject("move of [%s] by [%s]", objectID, actorID)
+	}
+	if targetID == "" || targetID == actorID {
+		message += " - rejected, no target"
+		return reject("move has no target")
+	}
+
+	follow, err := ai.followers.FindFollow(actorID)
+	if err != nil {
+		message += " - failed, database read error"
+		return fmt.Errorf("finding follower: %w", err)
+	}
+	if follow == nil {
+		message += " - not a follower"
+		return nil
+	}
+
+	// Always check the target's current state, not a cached copy
+	target, err := ai.service.fetchActor(ctx, targetID)
+	if err != nil {
+		message += " - failed, can't fetch target"
+		return fmt.Errorf("fetching move target: %w", err)
+	}
+	if target.ID != targetID {
+		message += " - rejected, target id mismatch"
+		return reject("move target [%s] has id [%s]", targetID, target.ID)
+	}
+	if !target.IsAlsoKnownAs(actorID) {
+		// Anybody could claim to be moving to some other account
+		message += " - rejected, not alsoKnownAs"
+		return reject("move target [%s] isn't also known as [%s]", targetID, actorID)
+	}
+
+	// Keep the old follow's status so an approved follower isn't asked again
+	if err := ai.followers.SaveFollow(storage.Follow{
+		ID:            targetID,
+		RequestID:     act.ID,
+		RequestStatus: follow.RequestStatus,
+	}); err != nil {
+		message += " - database write error"
+		return fmt.Errorf("saving follower: %w", err)
+	}
+	if err := ai.followers.DeleteFollow(actorID); err != nil {
+		message += " - database delete error"
+		return fmt.Errorf("deleting follower: %w", err)
+	}
+
+	telemetry.Increment("followers_moved", 1)
+	message += " - success"
+	return nil
+}
+
+// Move sends a Move activity to all of a local user's followers, telling them
+// the user has moved to the target account, and marks the actor as moved.
+// The target must already list the user in its alsoKnownAs.
+// Returns the number of followers notified.
+func (s *ActivityService) Move(ctx context.Context, name string, target string) (int, error) {
+	user := s.findUser(name)
+	if user == nil {
+		return 0, fmt.Errorf("no user named %s", name)
+	}
+
+	if IsHandle(target) {
+		id, err := s.finger.Resolve(ctx, target)
+		if err != nil {
+			return 0, err
+		}
+		target = id
+	}
+	actor, err := s.fetchActor(ctx, target)
+	if err != nil {
+		return 0, fmt.Errorf("fetching move target: %w", err)
+	}
+	if !actor.IsAlsoKnownAs(user.meta.UserID) {
+		return 0, fmt.Errorf("%s must list %s in alsoKnownAs before moving", actor.ID, user.meta.UserID)
+	}
+
+	if err := user.store.(storage.Settings).SaveSetting(movedToSetting, actor.ID); err != nil {
+		return 0, fmt.Errorf("saving movedTo: %w", err)
+	}
+	user.meta.MovedTo = actor.ID
+
+	followers, err := user.outbox.followers.GetFollowers()
+	if err != nil {
+		return 0, fmt.Errorf("getting followers: %w", err)
+	}
+
+	s.SendNow(ctx, func() {
+		for _, follower := range followers {
+			s.pipeline.Queue(&MoveActivity{
+				outbox:   &user.outbox,
+				localID:  user.meta.UserID,
+				remoteID: follower.ID,
+				targetID: actor.ID,
+			})
+		}
+	})
+	return len(followers), nil
+}
+
+// MoveActivity tells a follower that a local user moved to another account
+type MoveActivity struct {
+	outbox   *ActivityOutbox
+	localID  string
+	remoteID string
+	targetID string
+}
+
+func (m *MoveActivity) String() string {
+	return fmt.Sprintf("Move to %s", m.remoteID)
+}
+
+func (m *MoveActivity) Prepare(ctx context.Context, pipeline *OutputPipeline) (*http.Request, error) {
+	remote, err := m.outbox.service.GetActor(ctx, m.remoteID)
+	if err != nil {
+		return nil, fmt.Errorf("looking up remote actor: %w", err)
+	}
+
+	moveObject := struct {
+		Context string   `json:"@context"`
+		Type    string   `json:"type"`
+		ID      string   `json:"id"`
+		Actor   string   `json:"actor"`
+		Object  string   `json:"object"`
+		Target  string   `json:"target"`
+		To      []string `json:"to"`
+		CC      []string `json:"cc"`
+	}{
+		Context: activity.Context,
+		Type:    activity.MoveType,
+		ID:      uuid.NewString(),
+		Actor:   m.localID,
+		Object:  m.localID,
+		Target:  m.targetID,
+		To:      []string{m.remoteID}, // Pleroma seems to require a to array
+		CC:      make([]string, 0),    // Pleroma seems to require a cc array
+	}
+
+	r, err := m.outbox.service.ActivityRequest(http.MethodPost, remote.Inbox, &moveObject)
+	if err != nil {
+		return nil, fmt.Errorf("creating move request: %w", err)
+	}
+
+	if m.outbox.privKey != nil && !m.outbox.sendUnsigned {
+		sign(m.outbox.privKey, m.outbox.pubKeyID, r)
+	}
+
+	telemetry.Increment("moves_sent", 1)
+	return r, nil
+}
+
+func (m *MoveActivity) Receive(resp *http.Response) {
+	telemetry.Trace("received response from move %d", resp.StatusCode)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		telemetry.Increment("moves_succeeded", 1)
+	} else {
+		telemetry.Increment("moves_failed", 1)
+		m.outbox.deliveryFailed(activity.MoveType, m.targetID, m.remoteID, fmt.Sprintf("status %d", resp.StatusCode))
+	}
+}
+
+func (m *MoveActivity) Fail(err error) {
+	telemetry.Increment("moves_failed", 1)
+	m.outbox.deliveryFailed(activity.MoveType, m.targetID, m.remoteID, err.Error())
+}
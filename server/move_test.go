@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/page"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+// movingServer serves a remote actor that is also known as another account
+func movingServer(alsoKnownAs string) *httptest.Server {
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := activity.Actor{
+			Type:        activity.PersonType,
+			ID:          remote.URL + r.URL.Path,
+			Inbox:       remote.URL + r.URL.Path + "/inbox",
+			AlsoKnownAs: []string{alsoKnownAs},
+		}
+		w.Header().Set("Content-Type", activity.ContentType)
+		w.Write(jsonBytes(&actor))
+	}))
+	return remote
+}
+
+func TestInbox_Move(t *testing.T) {
+	const oldID = "https://old/users/alice"
+	remote := movingServer(oldID)
+	defer remote.Close()
+	newID := remote.URL + "/users/alice"
+
+	followers := &mockFollowers{}
+	followers.On("FindFollow", oldID).Return(&storage.Follow{ID: oldID, RequestStatus: "accepted"}, nil)
+	followers.On("SaveFollow", storage.Follow{ID: newID, RequestID: "https://old/activities/move", RequestStatus: "accepted"}).Return(nil).Once()
+	followers.On("DeleteFollow", oldID).Return(nil).Once()
+
+	inbox := ActivityInbox{
+		service: &ActivityService{
+			actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+			fetcher:    testFetcher(),
+		},
+		id:             "test",
+		followers:      followers,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	move := `{"type":"Move","id":"https://old/activities/move","actor":%q,"object":%q,"target":%q}`
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{
+		Body: fmt.Sprintf(move, oldID, oldID, newID),
+	}))
+
+	// the target doesn't claim to be bob
+	followers.On("FindFollow", "https://old/users/bob").Return(&storage.Follow{ID: "https://old/users/bob"}, nil)
+	err := inbox.Process(context.Background(), &storage.InboxItem{
+		Body: fmt.Sprintf(move, "https://old/users/bob", "https://old/users/bob", newID),
+	})
+	assert.ErrorAs(t, err, &rejectedError{})
+
+	followers.AssertExpectations(t)
+}
+
+func TestService_Move(t *testing.T) {
+	meta := page.MetaData{URL: "https://local"}
+	umeta := meta.NewUserMetaData("test")
+
+	var lock sync.Mutex
+	var moves []activity.Activity
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var act activity.Activity
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&act))
+			lock.Lock()
+			moves = append(moves, act)
+			lock.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		actor := activity.Actor{
+			Type:        activity.PersonType,
+			ID:          remote.URL + r.URL.Path,
+			Inbox:       remote.URL + r.URL.Path + "/inbox",
+			AlsoKnownAs: umeta.UserID,
+		}
+		w.Header().Set("Content-Type", activity.ContentType)
+		w.Write(jsonBytes(&actor))
+	}))
+	defer remote.Close()
+
+	store := storage.NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, store.Open())
+	defer store.Close()
+	followers := store.(storage.Followers)
+	require.NoError(t, followers.SaveFollow(storage.Follow{ID: remote.URL + "/users/follower", RequestStatus: "accepted"}))
+
+	svc := &ActivityService{
+		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		fetcher:    testFetcher(),
+		pipeline:   NewPipeline(),
+	}
+	svc.users = []ActivityUser{{
+		name:  "test",
+		meta:  umeta,
+		store: store,
+		outbox: ActivityOutbox{
+			service:      svc,
+			followers:    followers,
+			sendUnsigned: true,
+		},
+	}}
+
+	n, err := svc.Move(context.Background(), "test", remote.URL+"/users/new")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.Len(t, moves, 1)
+	assert.Equal(t, activity.MoveType, moves[0].Type)
+	assert.Equal(t, remote.URL+"/users/new", moves[0].Target)
+
+	movedTo, err := store.(storage.Settings).GetSetting(movedToSetting)
+	require.NoError(t, err)
+	assert.Equal(t, remote.URL+"/users/new", movedTo)
+	assert.Equal(t, movedTo, svc.users[0].meta.MovedTo)
+}
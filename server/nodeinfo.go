@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/page"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+const nodeInfoCacheTTL = 10 * time.Minute // how long NodeInfo is served before counting again
+
+// softwareVersion is the version of activitylace from build info,
+// the module version if it was installed with go install, otherwise the vcs revision
+func softwareVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return strings.TrimPrefix(v, "v")
+	}
+	version, dirty := "dev", false
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && len(setting.Value) >= 7:
+			version += "+" + setting.Value[:7]
+		case setting.Key == "vcs.modified":
+			dirty = setting.Value == "true"
+		}
+	}
+	if dirty {
+		version += ".dirty"
+	}
+	return version
+}
+
+// nodeInfo gathers NodeInfo usage statistics and metadata
+func (s *ActivityService) nodeInfo(version string) (page.NodeInfoData, error) {
+	cfg := s.currentConfig().NodeInfo
+	users := s.currentUsers()
+	info := page.NodeInfoData{
+		MetaData:        s.meta,
+		Version:         version,
+		SoftwareVersion: softwareVersion(),
+		Users:           len(users),
+		Metadata:        make(map[string]any),
+	}
+	for k, v := range cfg.Metadata {
+		info.Metadata[k] = v
+	}
+	if cfg.NodeName != "" {
+		info.Metadata["nodeName"] = cfg.NodeName
+	}
+	if cfg.Description != "" {
+		info.Metadata["nodeDescription"] = cfg.Description
+	}
+
+	now := time.Now()
+	for i := range users {
+		notes := users[i].outbox.notes
+		n, err := notes.CountNotes()
+		if err != nil {
+			return info, err
+		}
+		info.LocalPosts += n
+		latest, err := notes.GetLatestNotes(1)
+		if err != nil {
+			return info, err
+		}
+		if len(latest) == 0 {
+			continue
+		}
+		if latest[0].Published.After(now.AddDate(0, -1, 0)) {
+			info.ActiveMonth++
+		}
+		if latest[0].Published.After(now.AddDate(0, -6, 0)) {
+			info.ActiveHalfyear++
+		}
+	}
+	return info, nil
+}
+
+// nodeInfoHandler serves a version of NodeInfo.
+// Statistics are counted at most once every ten minutes.
+func (s *ActivityService) nodeInfoHandler(version string) http.HandlerFunc {
+	nodeInfo := s.override(page.NodeInfo) // replaced along with the router on reload
+	return func(w http.ResponseWriter, r *http.Request) {
+		telemetry.Increment("nodeinfo_requests", 1)
+		item, err := s.pages.Fetch("nodeinfo/"+version, nodeInfoCacheTTL, func() ([]byte, error) {
+			info, err := s.nodeInfo(version)
+			if err != nil {
+				return nil, err
+			}
+			return nodeInfo.Render(info)
+		})
+		if err != nil {
+			telemetry.Error(err, "rendering nodeinfo %s", version)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", page.NodeInfoContentType(version))
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Write(item.Value())
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+func TestNodeInfo(t *testing.T) {
+	cfg := reloadConfig(reloadUser("alice", "Alice"), reloadUser("bob", "Bob"), reloadUser("carol", "Carol"))
+	cfg.NodeInfo = nodeInfoConfig{NodeName: "Blog", Description: "A blog", Metadata: map[string]any{"maintainer": map[string]any{"name": "Alice"}}}
+	svc := reloadService(t, cfg)
+	require.NoError(t, svc.users[0].outbox.notes.SaveNote(&storage.Note{ID: "https://blog/1", Published: time.Now().Add(-time.Hour)}))
+	require.NoError(t, svc.users[0].outbox.notes.SaveNote(&storage.Note{ID: "https://blog/2", Published: time.Now().AddDate(-1, 0, 0)}))
+	require.NoError(t, svc.users[1].outbox.notes.SaveNote(&storage.Note{ID: "https://blog/3", Published: time.Now().AddDate(0, -3, 0)}))
+
+	get := func(target string, v any) http.Header {
+		w := httptest.NewRecorder()
+		svc.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		require.Equal(t, http.StatusOK, w.Code, target)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+		return w.Header()
+	}
+
+	var discovery struct {
+		Links []struct {
+			Rel  string `json:"rel"`
+			Href string `json:"href"`
+		} `json:"links"`
+	}
+	get("/.well-known/nodeinfo", &discovery)
+	require.Len(t, discovery.Links, 2)
+	for _, link := range discovery.Links {
+		version := strings.TrimPrefix(link.Href, "https://local/nodeinfo/")
+		assert.Equal(t, "http://nodeinfo.diaspora.software/ns/schema/"+version, link.Rel)
+
+		var info map[string]any
+		header := get(strings.TrimPrefix(link.Href, "https://local"), &info)
+		assert.Equal(t, `application/json; profile="http://nodeinfo.diaspora.software/ns/schema/`+version+`#"`, header.Get("Content-Type"))
+		assert.Equal(t, version, info["version"])
+		assert.Equal(t, false, info["openRegistrations"])
+		assert.Equal(t, map[string]any{
+			"users":      map[string]any{"total": 3.0, "activeMonth": 1.0, "activeHalfyear": 2.0},
+			"localPosts": 3.0,
+		}, info["usage"])
+		assert.Equal(t, map[string]any{
+			"nodeName":        "Blog",
+			"nodeDescription": "A blog",
+			"maintainer":      map[string]any{"name": "Alice"},
+		}, info["metadata"])
+		software := info["software"].(map[string]any)
+		assert.Equal(t, "activitylace", software["name"])
+		assert.NotEmpty(t, software["version"])
+	}
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+	"golang.org/x/net/html"
+)
+
+// notifyConfig decides how the blog owner hears about new notifications.
+// Notifications are always stored, and any combination of deliveries may be configured.
+type notifyConfig struct {
+	Types       []string `json:"types,omitempty"`       // kinds of notification delivered, all if empty
+	Webhook     string   `json:"webhook,omitempty"`     // url each notification is posted to, as a webhook for notification events
+	SMTPAddr    string   `json:"smtpAddr,omitempty"`    // host:port of a local mail relay
+	MailFrom    string   `json:"mailFrom,omitempty"`    // sender address for notification mail
+	MailTo      []string `json:"mailTo,omitempty"`      // recipients of notification mail
+	DigestFile  string   `json:"digestFile,omitempty"`  // file to which digests are appended
+	DigestHours int      `json:"digestHours,omitempty"` // how often a digest is written, daily if zero
+}
+
+// Notifier stores notifications and delivers them to the blog owner
+type Notifier struct {
+	config   notifyConfig
+	store    storage.Notifications
+	webhooks *Webhooks                 // posts notification events
+	queue    chan storage.Notification // waiting to be mailed
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewNotifier(cfg notifyConfig, store storage.Notifications, webhooks *Webhooks) *Notifier {
+	return &Notifier{
+		config:   cfg,
+		store:    store,
+		webhooks: webhooks,
+		queue:    make(chan storage.Notification, 100),
+		sendMail: smtp.SendMail,
+	}
+}
+
+// Notify stores a notification and queues it for delivery
+func (n *Notifier) Notify(note *storage.Notification) error {
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = time.Now().UTC()
+	}
+	if err := n.store.SaveNotification(note); err != nil {
+		return fmt.Errorf("saving notification: %w", err)
+	}
+	telemetry.Increment("notifications", 1)
+	if !n.wants(note.Type) {
+		return nil
+	}
+	n.webhooks.Emit(EventNotification, note.User, note)
+	if n.config.SMTPAddr == "" {
+		return nil
+	}
+	select {
+	case n.queue <- *note:
+	default:
+		telemetry.Log("notification queue full, not delivering [%s]", note.ID)
+	}
+	return nil
+}
+
+// notify tells the inbox owner about something, if anyone is listening
+func (ai *ActivityInbox) notify(note storage.Notification) {
+	if ai.notifier == nil {
+		return
+	}
+	note.User = ai.ownerID
+	if err := ai.notifier.Notify(&note); err != nil {
+		telemetry.Error(err, "notifying [%s]", note.ID)
+	}
+}
+
+// Run delivers queued notifications and writes digests until the context is done
+func (n *Notifier) Run(ctx context.Context) {
+	var digest <-chan time.Time
+	if n.config.DigestFile != "" {
+		interval := 24 * time.Hour
+		if n.config.DigestHours > 0 {
+			interval = time.Duration(n.config.DigestHours) * time.Hour
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		digest = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case note := <-n.queue:
+			n.deliver(note)
+		case <-digest:
+			if err := n.WriteDigest(); err != nil {
+				telemetry.Error(err, "writing notification digest")
+			}
+		}
+	}
+}
+
+// wants returns true if notifications of the given type should be delivered
+func (n *Notifier) wants(kind string) bool {
+	if len(n.config.Types) == 0 {
+		return true
+	}
+	for _, t := range n.config.Types {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver mails a notification through the relay
+func (n *Notifier) deliver(note storage.Notification) {
+	if n.config.SMTPAddr != "" && len(n.config.MailTo) > 0 {
+		if err := n.mail(note); err != nil {
+			telemetry.Error(err, "mailing notification [%s]", note.ID)
+		} else {
+			telemetry.Increment("notifications_mailed", 1)
+		}
+	}
+}
+
+func (n *Notifier) mail(note storage.Notification) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.config.MailFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.config.MailTo, ", "))
+	// Encoded so that line breaks in a remote actor ID can't add headers
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[activitylace] "+describeNotification(note)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", note.CreatedAt.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(notificationText(note))
+	return n.sendMail(n.config.SMTPAddr, nil, n.config.MailFrom, n.config.MailTo, []byte(msg.String()))
+}
+
+// WriteDigest appends notifications not yet in a digest to the digest file
+func (n *Notifier) WriteDigest() error {
+	notes, err := n.store.GetUndigestedNotifications()
+	if err != nil {
+		return fmt.Errorf("getting notifications: %w", err)
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+
+	var digest strings.Builder
+	fmt.Fprintf(&digest, "Notifications at %s\n\n", time.Now().UTC().Format(time.RFC1123))
+	ids := make([]string, 0, len(notes))
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+		if !n.wants(note.Type) {
+			continue
+		}
+		fmt.Fprintf(&digest, "* %s\n", describeNotification(note))
+		for _, line := range strings.Split(strings.TrimSpace(notificationText(note)), "\n") {
+			fmt.Fprintf(&digest, "  %s\n", line)
+		}
+	}
+	digest.WriteString("\n")
+
+	f, err := os.OpenFile(n.config.DigestFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("opening digest file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(digest.String()); err != nil {
+		return fmt.Errorf("writing digest file: %w", err)
+	}
+	return n.store.MarkNotificationsDigested(ids)
+}
+
+// describeNotification returns a one-line summary of a notification
+func describeNotification(note storage.Notification) string {
+	switch note.Type {
+	case storage.NotifyMention:
+		return fmt.Sprintf("%s mentioned you", note.ActorID)
+	case storage.NotifyDirect:
+		return fmt.Sprintf("%s sent you a direct message", note.ActorID)
+	case storage.NotifyFollow:
+		return fmt.Sprintf("%s followed you", note.ActorID)
+	case storage.NotifyLike:
+		return fmt.Sprintf("%s liked %s", note.ActorID, note.ObjectID)
+	case storage.NotifyBoost:
+		return fmt.Sprintf("%s boosted %s", note.ActorID, note.ObjectID)
+	case storage.NotifyReport:
+		return fmt.Sprintf("%s reported %s", note.ActorID, note.ObjectID)
+	default:
+		return fmt.Sprintf("%s from %s", note.Type, note.ActorID)
+	}
+}
+
+// notificationText returns the plain text body of a notification
+func notificationText(note storage.Notification) string {
+	var text strings.Builder
+	text.WriteString(describeNotification(note))
+	text.WriteString("\n")
+	if content := plainText(note.Content); content != "" {
+		text.WriteString("\n")
+		text.WriteString(content)
+		text.WriteString("\n")
+	}
+	if note.URL != "" {
+		text.WriteString("\n")
+		text.WriteString(note.URL)
+		text.WriteString("\n")
+	}
+	return text.String()
+}
+
+// plainText strips markup from sanitized html, keeping paragraphs and line breaks
+func plainText(content string) string {
+	var text strings.Builder
+	tokens := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch tokens.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(text.String())
+		case html.TextToken:
+			text.Write(tokens.Text())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokens.TagName()
+			if string(name) == "br" {
+				text.WriteString("\n")
+			}
+		case html.EndTagToken:
+			name, _ := tokens.TagName()
+			if string(name) == "p" {
+				text.WriteString("\n\n")
+			}
+		}
+	}
+}
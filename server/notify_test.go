@@ -0,0 +1,231 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+func testNotifier(t *testing.T, cfg notifyConfig) *Notifier {
+	store := storage.NewDatabase(filepath.Join(t.TempDir(), "service.db"))
+	require.NoError(t, store.Open())
+	t.Cleanup(store.Close)
+	return NewNotifier(cfg, store.(storage.Notifications), nil)
+}
+
+func TestInbox_Create_Mention(t *testing.T) {
+	notes := &mockNotes{}
+	notes.On("FindNote", "https://remote/notes/0").Return(nil, nil)
+	notifier := testNotifier(t, notifyConfig{})
+	inbox := ActivityInbox{
+		id:       "test",
+		ownerID:  "https://blog/activity/test",
+		notes:    notes,
+		notifier: notifier,
+		pipeline: NewPipeline(),
+	}
+
+	create := func(id string, to string, inReplyTo string, tag string) []byte {
+		return []byte(fmt.Sprintf(`{"type":"Create","id":%q,"actor":"https://remote/users/alice","to":[%q],
+			"object":{"type":"Note","id":"https://remote/notes/%s","attributedTo":"https://remote/users/alice",
+			"inReplyTo":%q,"content":"<p>hi <script>x</script>@test</p>","tag":[%s]}}`, id, to, id, inReplyTo, tag))
+	}
+	mention := `{"type":"Mention","href":"https://blog/activity/test","name":"@test@blog"}`
+
+	var act activity.Activity
+	for _, body := range [][]byte{
+		create("1", activity.PublicAddress, "", mention),                       // public mention
+		create("2", activity.PublicAddress, "https://remote/notes/0", mention), // reply to someone else
+		create("3", "https://blog/activity/test", "", ""),                      // direct message
+	} {
+		require.NoError(t, json.Unmarshal(body, &act))
+		require.NoError(t, inbox.Create(act, body), string(body))
+	}
+	for _, body := range [][]byte{
+		create("4", activity.PublicAddress, "", ""),                                   // no mention
+		create("5", "https://remote/users/alice/followers", "", ""),                   // private, not to us
+		create("6", "https://remote/users/alice/followers", "", `{"type":"Hashtag"}`), // private, not a mention
+	} {
+		require.NoError(t, json.Unmarshal(body, &act))
+		assert.ErrorAs(t, inbox.Create(act, body), &rejectedError{}, string(body))
+	}
+
+	list, err := notifier.store.GetNotifications(time.Time{}, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	kinds := map[string]string{}
+	for _, n := range list {
+		kinds[n.ID] = n.Type
+		assert.Equal(t, "https://blog/activity/test", n.User)
+		assert.Equal(t, "https://remote/users/alice", n.ActorID)
+		assert.Equal(t, "<p>hi @test</p>", n.Content)
+	}
+	assert.Equal(t, map[string]string{"1": storage.NotifyMention, "2": storage.NotifyMention, "3": storage.NotifyDirect}, kinds)
+
+	// without a notifier, mentions are rejected as before
+	inbox.notifier = nil
+	body := create("7", activity.PublicAddress, "", mention)
+	require.NoError(t, json.Unmarshal(body, &act))
+	assert.ErrorAs(t, inbox.Create(act, body), &rejectedError{})
+}
+
+func TestNotifier_Deliver(t *testing.T) {
+	cfg := Config{Notify: notifyConfig{
+		Types:    []string{storage.NotifyFollow},
+		Webhook:  "https://hooks.example/notify",
+		SMTPAddr: "localhost:25",
+		MailFrom: "activitylace@blog",
+		MailTo:   []string{"owner@blog"},
+	}}
+	notifier := testNotifier(t, cfg.Notify)
+	notifier.webhooks = testWebhooks(t, cfg.webhooks())
+	var mailed string
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "localhost:25", addr)
+		assert.Equal(t, []string{"owner@blog"}, to)
+		mailed = string(msg)
+		return nil
+	}
+
+	require.NoError(t, notifier.Notify(&storage.Notification{ID: "like", Type: storage.NotifyLike, ActorID: "https://remote/users/alice"}))
+	require.NoError(t, notifier.Notify(&storage.Notification{ID: "follow", Type: storage.NotifyFollow, ActorID: "https://remote/users/alice"}))
+
+	// only the wanted type is posted to the webhook and queued for mail
+	deliveries, err := notifier.webhooks.Deliveries(nil, 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, EventNotification, deliveries[0].Event)
+	assert.Equal(t, "https://hooks.example/notify", deliveries[0].Webhook)
+	var payload struct {
+		Data storage.Notification `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(deliveries[0].Payload), &payload))
+	assert.Equal(t, "follow", payload.Data.ID)
+
+	require.Len(t, notifier.queue, 1)
+	notifier.deliver(<-notifier.queue)
+	assert.Contains(t, mailed, "Subject: [activitylace] https://remote/users/alice followed you\r\n")
+
+	// both are stored
+	list, err := notifier.store.GetNotifications(time.Time{}, nil, 0)
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+}
+
+func TestNotifier_MailSubject(t *testing.T) {
+	notifier := testNotifier(t, notifyConfig{SMTPAddr: "localhost:25", MailFrom: "activitylace@blog", MailTo: []string{"owner@blog"}})
+	var mailed string
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mailed = string(msg)
+		return nil
+	}
+
+	// a line break in the actor can't start another header
+	require.NoError(t, notifier.mail(storage.Notification{Type: storage.NotifyFollow, ActorID: "https://remote/x\r\nBcc: victim@example.com"}))
+	headers, _, _ := strings.Cut(mailed, "\r\n\r\n")
+	for _, line := range strings.Split(headers, "\r\n") {
+		assert.False(t, strings.HasPrefix(line, "Bcc:"), line)
+	}
+	assert.Contains(t, headers, "Subject: =?utf-8?q?")
+}
+
+func TestNotifier_WriteDigest(t *testing.T) {
+	digestFile := filepath.Join(t.TempDir(), "digest.txt")
+	notifier := testNotifier(t, notifyConfig{DigestFile: digestFile})
+
+	require.NoError(t, notifier.Notify(&storage.Notification{
+		ID:      "m",
+		Type:    storage.NotifyMention,
+		ActorID: "https://remote/users/alice",
+		Content: "<p>first</p><p>second<br>third</p>",
+		URL:     "https://remote/@alice/1",
+	}))
+	assert.Empty(t, notifier.queue, "nothing to deliver immediately")
+	require.NoError(t, notifier.WriteDigest())
+
+	content, err := os.ReadFile(digestFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "* https://remote/users/alice mentioned you\n")
+	assert.Contains(t, string(content), "  first\n  \n  second\n  third\n")
+	assert.Contains(t, string(content), "  https://remote/@alice/1\n")
+
+	// notifications are only written once
+	require.NoError(t, notifier.WriteDigest())
+	again, err := os.ReadFile(digestFile)
+	require.NoError(t, err)
+	assert.Equal(t, content, again)
+}
+
+func TestNotifier_SeveralUsers(t *testing.T) {
+	cfg := reloadConfig(reloadUser("alice", "Alice"), reloadUser("bob", "Bob"))
+	cfg.Server.ReceiveUnsigned = true
+	svc := reloadService(t, cfg)
+
+	body := `{"type":"Create","id":"https://remote/notes/1/activity","actor":"https://remote/users/carol",
+		"to":["https://local/activity/alice","https://local/activity/bob"],
+		"object":{"type":"Note","id":"https://remote/notes/1","attributedTo":"https://remote/users/carol","content":"hi"}}`
+	for i := range svc.users {
+		require.NoError(t, svc.users[i].inbox.Process(context.Background(), &storage.InboxItem{Body: body}))
+	}
+
+	list, err := svc.notifier.store.GetNotifications(time.Time{}, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	users := []string{list[0].User, list[1].User}
+	assert.ElementsMatch(t, []string{"https://local/activity/alice", "https://local/activity/bob"}, users)
+	for _, n := range list {
+		assert.Equal(t, "https://remote/notes/1/activity", n.ID)
+	}
+}
+
+func TestService_NotificationsHTTP(t *testing.T) {
+	svc := &ActivityService{
+		config:   Config{Server: serverConfig{Admin: adminConfig{Tokens: []string{"secret"}}}},
+		notifier: testNotifier(t, notifyConfig{}),
+	}
+	require.NoError(t, svc.notifier.Notify(&storage.Notification{ID: "f", Type: storage.NotifyFollow}))
+	require.NoError(t, svc.notifier.Notify(&storage.Notification{ID: "l", Type: storage.NotifyLike}))
+	router := mux.NewRouter()
+	svc.addAdminHandlers(router)
+	handler := router.ServeHTTP
+
+	for _, auth := range []string{"", "secret", "Bearer wrong"} {
+		r := httptest.NewRequest("GET", "/admin/notifications", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, auth)
+	}
+
+	r := httptest.NewRequest("GET", "/admin/notifications?type=like", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []storage.Notification
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, "l", list[0].ID)
+
+	r = httptest.NewRequest("GET", "/admin/notifications?since=yesterday", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
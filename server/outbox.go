@@ -18,21 +18,29 @@ import (
 type ActivityOutbox struct {
 	service        *ActivityService
 	ownerID        string
+	actorID        string // id of the owner of the outbox
 	id             string
 	rssURL         string
 	notes          storage.Notes
+	replies        storage.Replies
+	reactions      storage.Reactions
 	followers      storage.Followers
 	pipeline       *OutputPipeline
 	privKey        crypto.PrivateKey
 	pubKeyID       string
 	acceptUnsigned bool
 	sendUnsigned   bool
+	feed           *feedState // health of the feed watcher
 }
 
 // NewItem is called when a new RSS item is detected by the watcher
 func (ao *ActivityOutbox) NewItem(item rss.Item) {
 	telemetry.Trace("new item [%s]", item.Title)
 	telemetry.Increment("rss_newitems", 1)
+	ao.feed.update(func(status *FeedStatus) {
+		status.Items++
+		status.LastItem = time.Now().UTC()
+	})
 	obj := storage.Note{
 		ID:        item.ID, // TODO: feel like this should generate a unique ID bound to server's domain
 		Content:   item.Title,
@@ -42,6 +50,12 @@ func (ao *ActivityOutbox) NewItem(item rss.Item) {
 	if err := ao.notes.SaveNote(&obj); err != nil {
 		telemetry.Error(err, "updating storage for [%s]", item.ID)
 	}
+	ao.emit(EventPublished, map[string]string{
+		"id":        obj.ID,
+		"url":       obj.URL,
+		"content":   obj.Content,
+		"published": obj.Published.Format(time.RFC3339),
+	})
 	ao.SendToFollowers(obj)
 }
 
@@ -60,14 +74,43 @@ func (ao *ActivityOutbox) SendToFollowers(obj storage.Note) {
 
 func (ao *ActivityOutbox) SendToFollower(obj storage.Note, follower storage.Follow) {
 	telemetry.Trace("queuing a note activity")
-	ao.pipeline.Queue(&NoteActivity{
+	ao.pipeline.Queue(ao.noteActivity(obj, ao.newDelivery(obj, follower.ID)))
+}
+
+// newDelivery starts a delivery log entry for sending a note to a follower
+func (ao *ActivityOutbox) newDelivery(obj storage.Note, target string) storage.Delivery {
+	delivery := storage.Delivery{
+		ID:        uuid.NewString(),
+		User:      ao.ownerID,
+		ObjectID:  obj.ID,
+		Target:    target,
+		Status:    storage.DeliveryPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	ao.saveDelivery(&delivery)
+	return delivery
+}
+
+func (ao *ActivityOutbox) noteActivity(obj storage.Note, delivery storage.Delivery) *NoteActivity {
+	return &NoteActivity{
 		service:  ao.service,
 		outbox:   ao,
 		note:     obj,
-		remoteID: follower.ID,
-		localID:  ao.ownerID,
-	})
+		remoteID: delivery.Target,
+		localID:  ao.actorID,
+		delivery: delivery,
+	}
+}
 
+// saveDelivery records the progress of a note delivery in the delivery log
+func (ao *ActivityOutbox) saveDelivery(delivery *storage.Delivery) {
+	if ao.service == nil || ao.service.deliveries == nil {
+		return
+	}
+	delivery.UpdatedAt = time.Now().UTC()
+	if err := ao.service.deliveries.SaveDelivery(delivery); err != nil {
+		telemetry.Error(err, "database error")
+	}
 }
 
 type NoteActivity struct {
@@ -76,15 +119,16 @@ type NoteActivity struct {
 	note     storage.Note
 	localID  string
 	remoteID string
+	delivery storage.Delivery // log entry for this delivery
 }
 
 func (f *NoteActivity) String() string {
 	return fmt.Sprintf("Note to %s", f.remoteID)
 }
 
-func (f *NoteActivity) Prepare(pipeline *OutputPipeline) (*http.Request, error) {
+func (f *NoteActivity) Prepare(ctx context.Context, pipeline *OutputPipeline) (*http.Request, error) {
 	// Lookup the follower's inbox
-	remote, err := f.service.GetActor(f.remoteID)
+	remote, err := f.service.GetActor(ctx, f.remoteID)
 	if err != nil {
 		return nil, fmt.Errorf("looking up remote actor: %w", err)
 	}
@@ -114,6 +158,8 @@ func (f *NoteActivity) Prepare(pipeline *OutputPipeline) (*http.Request, error)
 			MediaType: "text/plain",
 			Published: f.note.Published.Format(activity.TimeFormat),
 			URL:       f.note.URL,
+			Likes:     f.outbox.collectionID("likes", f.note.ID),
+			Shares:    f.outbox.collectionID("shares", f.note.ID),
 		},
 	}
 
@@ -132,17 +178,61 @@ func (f *NoteActivity) Prepare(pipeline *OutputPipeline) (*http.Request, error)
 
 func (f *NoteActivity) Receive(resp *http.Response) {
 	telemetry.Trace("received response from note %d", resp.StatusCode)
-	if resp.StatusCode == http.StatusOK {
+	f.delivery.Attempts++
+	f.delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 		telemetry.Increment("notes_succeeded", 1)
+		f.delivery.Status = storage.DeliveryDelivered
+		f.delivery.LastError = ""
+		f.outbox.saveDelivery(&f.delivery)
 	} else {
 		telemetry.Increment("notes_failed", 1)
+		f.delivery.Status = storage.DeliveryFailed
+		f.delivery.LastError = fmt.Sprintf("status %d", resp.StatusCode)
+		f.outbox.saveDelivery(&f.delivery)
+		f.outbox.deliveryFailed(activity.CreateType, f.note.ID, f.remoteID, f.delivery.LastError)
 	}
 }
 
+func (f *NoteActivity) Fail(err error) {
+	telemetry.Increment("notes_failed", 1)
+	f.delivery.Attempts++
+	f.delivery.Status = storage.DeliveryFailed
+	f.delivery.LastError = err.Error()
+	f.outbox.saveDelivery(&f.delivery)
+	f.outbox.deliveryFailed(activity.CreateType, f.note.ID, f.remoteID, err.Error())
+}
+
+// deliveryFailed tells webhooks that an activity couldn't be delivered to a remote actor
+func (ao *ActivityOutbox) deliveryFailed(activityType string, objectID string, remoteID string, reason string) {
+	ao.emit(EventDeliveryFailed, map[string]string{
+		"type":   activityType,
+		"object": objectID,
+		"to":     remoteID,
+		"error":  reason,
+	})
+}
+
 // StatusCode is called by the RSS watcher to report the latest fetch status code
 func (ao *ActivityOutbox) StatusCode(code int) {
 	telemetry.Trace("rss feed return code [%d]", code)
 	telemetry.Increment("rss_fetches", 1)
+	ao.feed.update(func(status *FeedStatus) {
+		status.Checks++
+		status.LastCheck = time.Now().UTC()
+		status.StatusCode = code
+		status.LastError = ""
+	})
+}
+
+// CheckFailed is called by the RSS watcher when a fetch fails
+func (ao *ActivityOutbox) CheckFailed(err error) {
+	telemetry.Increment("rss_failures", 1)
+	ao.feed.update(func(status *FeedStatus) {
+		status.Failures++
+		status.LastCheck = time.Now().UTC()
+		status.LastError = err.Error()
+	})
 }
 
 func (ao *ActivityOutbox) GetLatestNotes(n int) []storage.Note {
@@ -160,9 +250,16 @@ func (ao *ActivityOutbox) GetLatestNotes(n int) []storage.Note {
 
 // WatchRSS watches an RSS feed for new items and saves them as ActivityPub objects
 func (ao *ActivityOutbox) WatchRSS(ctx context.Context) {
-	watcher := rss.NewFeedWatcher(ao.rssURL, ao)
+	watcher := ao.newWatcher()
+	telemetry.Log("watching [%s]", ao.rssURL)
+	ao.feed.setWatcher(&watcher)
+	defer ao.feed.setWatcher(nil)
+	watcher.Watch(ctx, ao.service.currentConfig().Server.feedPollInterval())
+}
 
-	// Load previously-stored items
+// newWatcher creates a watcher of the RSS feed that knows about previously-stored items
+func (ao *ActivityOutbox) newWatcher() rss.FeedWatcher {
+	watcher := rss.NewFeedWatcher(ao.rssURL, ao)
 	notes, err := ao.notes.GetLatestNotes(100)
 	if err == nil {
 		for _, note := range notes {
@@ -174,9 +271,7 @@ func (ao *ActivityOutbox) WatchRSS(ctx context.Context) {
 			watcher.AddKnown(item)
 		}
 	}
-
-	telemetry.Log("watching [%s]", ao.rssURL)
-	watcher.Watch(ctx, 5*time.Minute)
+	return watcher
 }
 
 func (ao *ActivityOutbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -193,6 +288,8 @@ func (ao *ActivityOutbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			Published: note.Published.Format(activity.TimeFormat),
 			Content:   note.Content,
 			URL:       note.URL,
+			Likes:     ao.collection("likes", note.ID),
+			Shares:    ao.collection("shares", note.ID),
 		}
 	}
 
@@ -25,6 +25,7 @@ func TestOutbox_NoteActivity(t *testing.T) {
 
 	svc := ActivityService{
 		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		fetcher:    testFetcher(),
 	}
 
 	outbox := ActivityOutbox{
@@ -76,6 +77,7 @@ func TestOutbox_NoteActivity(t *testing.T) {
 			ID:      remoteID,
 			Inbox:   remoteInbox.URL,
 		}
+		w.Header().Set("Content-Type", activity.ContentType)
 		w.WriteHeader(http.StatusOK)
 		w.Write(jsonBytes(&actor))
 	}))
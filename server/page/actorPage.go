@@ -2,15 +2,27 @@ package page
 
 // ActorEndpoint is a template for an ActivityPub Actor endpoint
 var ActorEndpoint = StaticPage{
+	Name:        "actor.json",
 	Path:        "", // must be set for each actor
 	Accept:      "application/(activity|ld)+json",
 	ContentType: "application/activity+json",
 	Template: `
 {
 	"@context": [
-      "https://www.w3.org/ns/activitystreams",
-      "https://w3id.org/security/v1"
-  	],
+		"https://www.w3.org/ns/activitystreams",
+		"https://w3id.org/security/v1",
+		{
+			"toot": "http://joinmastodon.org/ns#",
+			"schema": "http://schema.org#",
+			"PropertyValue": "schema:PropertyValue",
+			"value": "schema:value",
+			"discoverable": "toot:discoverable",
+			"indexable": "toot:indexable",
+			"featuredTags": {"@id": "toot:featuredTags", "@type": "@id"},
+			"alsoKnownAs": {"@id": "as:alsoKnownAs", "@type": "@id"},
+			"movedTo": {"@id": "as:movedTo", "@type": "@id"}
+		}
+	],
 	"type": "{{ .UserType }}",
 	"id": "{{ .UserID }}",
 	"url": "{{ .UserProfileURL }}",
@@ -18,42 +30,84 @@ var ActorEndpoint = StaticPage{
 	"outbox": "{{ .OutboxURL }}",
 	"followers": "{{ .FollowersURL }}",
 	"following": "{{ .FollowingURL }}",
-	"name": "{{ .UserDisplayName }}",
+	"featuredTags": "{{ .FeaturedTagsURL }}",
+	"name": {{ json .UserDisplayName }},
 	"preferredUsername": "{{ .UserName }}",
-    "publicKey": {
-        "id": "{{ .UserPublicKeyID }}",
-        "owner": "{{ .UserID }}",
-        "publicKeyPem": "{{ .TransformedPublicKey }}"
-    },
-	"summary": "{{ .UserSummary }}"
-	{{- if .AvatarURL -}},
+	"publicKey": {
+		"id": "{{ .UserPublicKeyID }}",
+		"owner": "{{ .UserID }}",
+		"publicKeyPem": "{{ .TransformedPublicKey }}"
+	},
+	"summary": {{ json .UserSummary }},
+	"discoverable": {{ .Discoverable }},
+	"indexable": {{ .Indexable }},
+	{{- if .Published }}
+	"published": "{{ .Published }}",
+	{{- end }}
+	"attachment": [
+		{{- range $i, $field := .Fields }}{{ if $i }},{{ end }}
+		{
+			"type": "PropertyValue",
+			"name": {{ json $field.Name }},
+			"value": {{ json $field.Value }}
+		}
+		{{- end }}
+	]
+	{{- if .AvatarURL }},
 	"icon": {
 		"type": "Image",
-		"url": "{{ .AvatarURL }}",
+		{{- if .AvatarMediaType }}
+		"mediaType": "{{ .AvatarMediaType }}",
+		{{- end }}
+		{{- if .AvatarWidth }}
 		"width": {{ .AvatarWidth }},
-		"height": {{ .AvatarHeight }}
+		"height": {{ .AvatarHeight }},
+		{{- end }}
+		"url": {{ json .AvatarURL }}
+	}
+	{{- end }}
+	{{- if .HeaderURL }},
+	"image": {
+		"type": "Image",
+		{{- if .HeaderMediaType }}
+		"mediaType": "{{ .HeaderMediaType }}",
+		{{- end }}
+		{{- if .HeaderWidth }}
+		"width": {{ .HeaderWidth }},
+		"height": {{ .HeaderHeight }},
+		{{- end }}
+		"url": {{ json .HeaderURL }}
 	}
 	{{- end }}
+	{{- if .AlsoKnownAs }},
+	"alsoKnownAs": [{{ range $i, $id := .AlsoKnownAs }}{{ if $i }}, {{ end }}{{ json $id }}{{ end }}]
+	{{- end }}
+	{{- if .MovedTo }},
+	"movedTo": {{ json .MovedTo }}
+	{{- end }}
 }`,
 }
 
-var ProfilePage = StaticPage{
-	Path:        "/profile/{username}", // must be set for each actor
-	Accept:      "*/*",
-	ContentType: "text/html",
+// FeaturedTagsEndpoint is a template for the collection of an actor's featured hashtags
+var FeaturedTagsEndpoint = StaticPage{
+	Name:        "featured-tags.json",
+	Path:        "", // must be set for each actor
+	Accept:      "application/(activity|ld)+json",
+	ContentType: "application/activity+json",
 	Template: `
-<html>
-<head>
-<title>{{ .UserDisplayName }}</title>
-</head>
-<body>
-<h1>{{ .UserDisplayName }}</h1>
-<p>Latest activity from this account</p>
-<ul>
-	{{ range .LatestNotes }}
-	<li><a href="{{ .URL }}">{{ .Content }}</a></li>
-	{{ end }}
-</ul>
-</body>
-</html>`,
+{
+	"@context": "https://www.w3.org/ns/activitystreams",
+	"id": "{{ .FeaturedTagsURL }}",
+	"type": "Collection",
+	"totalItems": {{ len .FeaturedTags }},
+	"items": [
+		{{- range $i, $tag := .FeaturedTags }}{{ if $i }},{{ end }}
+		{
+			"type": "Hashtag",
+			"href": {{ json $tag.URL }},
+			"name": {{ json (print "#" $tag.Name) }}
+		}
+		{{- end }}
+	]
+}`,
 }
@@ -29,3 +29,61 @@ func TestActorPage_JSON(t *testing.T) {
 	require.NoError(t, json.Unmarshal(internalPage.rendered, &data))
 	assert.Equal(t, testName, data["preferredUsername"])
 }
+
+func TestActorPage_Moved(t *testing.T) {
+	u, err := url.Parse("http://test")
+	require.NoError(t, err)
+	umeta := NewMetaData(u).NewUserMetaData("test")
+	umeta.AlsoKnownAs = []string{"https://old/users/a", `https://older/users/"a"`}
+	umeta.MovedTo = `https://new/users/a\`
+
+	page := NewStaticPage(ActorEndpoint)
+	require.NoError(t, page.Init(umeta))
+
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal(page.(*internalStaticPage).rendered, &data))
+	assert.Equal(t, []interface{}{"https://old/users/a", `https://older/users/"a"`}, data["alsoKnownAs"])
+	assert.Equal(t, `https://new/users/a\`, data["movedTo"])
+}
+
+func TestActorPage_Profile(t *testing.T) {
+	u, err := url.Parse("http://test")
+	require.NoError(t, err)
+	umeta := NewMetaData(u).NewUserMetaData("test")
+	umeta.UserDisplayName = `The "Test"`
+	umeta.UserSummary = `<p>Hello</p>`
+	umeta.AvatarURL = "https://test/me.png"
+	umeta.HeaderURL = "https://test/banner.jpg"
+	umeta.HeaderWidth, umeta.HeaderHeight = 1500, 500
+	umeta.Discoverable = true
+	umeta.Published = "2020-02-03T00:00:00Z"
+	umeta.Fields = []ProfileField{NewProfileField("Blog", "https://blog.example/"), NewProfileField("Likes", "<cats>")}
+	umeta.FeaturedTags = []Hashtag{{Name: "go", URL: "https://blog.example/tags/go"}}
+
+	b, err := ActorEndpoint.Render(umeta)
+	require.NoError(t, err)
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &data), string(b))
+	assert.Equal(t, `The "Test"`, data["name"])
+	assert.Equal(t, `<p>Hello</p>`, data["summary"])
+	assert.Equal(t, true, data["discoverable"])
+	assert.Equal(t, false, data["indexable"])
+	assert.Equal(t, "2020-02-03T00:00:00Z", data["published"])
+	assert.Equal(t, map[string]interface{}{"type": "Image", "mediaType": "image/png", "url": "https://test/me.png"}, data["icon"])
+	assert.Equal(t, map[string]interface{}{"type": "Image", "mediaType": "image/jpeg", "width": 1500.0, "height": 500.0, "url": "https://test/banner.jpg"}, data["image"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"type": "PropertyValue", "name": "Blog", "value": `<a href="https://blog.example/" rel="me nofollow noopener noreferrer" target="_blank">blog.example</a>`},
+		map[string]interface{}{"type": "PropertyValue", "name": "Likes", "value": "&lt;cats&gt;"},
+	}, data["attachment"])
+	assert.Equal(t, "http://test/activity/test/tags", data["featuredTags"])
+
+	b, err = FeaturedTagsEndpoint.Render(umeta)
+	require.NoError(t, err)
+	data = nil
+	require.NoError(t, json.Unmarshal(b, &data), string(b))
+	assert.Equal(t, 1.0, data["totalItems"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"type": "Hashtag", "href": "https://blog.example/tags/go", "name": "#go"},
+	}, data["items"])
+
+}
@@ -0,0 +1,297 @@
+package page
+
+import (
+	"fmt"
+	"time"
+)
+
+// DashboardLoginTemplate is an html/template for signing in to the admin dashboard with an admin token
+const DashboardLoginTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Activitylace dashboard</title>
+</head>
+<body>
+<h1>Activitylace dashboard</h1>
+{{ if .Error }}<p><strong>{{ .Error }}</strong></p>{{ end }}
+<form method="post" action="{{ .Path }}/login">
+	<label>Admin token <input type="password" name="token" autocomplete="current-password" required></label>
+	<button type="submit">Sign in</button>
+</form>
+</body>
+</html>`
+
+// DashboardTemplate is an html/template for the admin dashboard.
+// Every form posts an action along with the session's CSRF token.
+const DashboardTemplate = `{{ define "csrf" }}<input type="hidden" name="csrf" value="{{ . }}">{{ end -}}
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Activitylace dashboard</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: 0.2em 0.5em; border-bottom: 1px solid #ddd; vertical-align: top; }
+form.inline { display: inline; }
+.error { color: #a00; }
+</style>
+</head>
+<body>
+<h1>Activitylace dashboard</h1>
+<form class="inline" method="post" action="{{ .Path }}/logout">{{ template "csrf" .CSRF }}<button type="submit">Sign out</button></form>
+{{ if .Message }}<p><strong>{{ .Message }}</strong></p>{{ end }}
+{{ if .Error }}<p class="error"><strong>{{ .Error }}</strong></p>{{ end }}
+
+{{ range .Users }}
+{{ $user := .Name }}
+<h2>{{ .Name }}</h2>
+
+<h3>Feed</h3>
+{{ with .Feed }}
+<p>{{ .URL }}</p>
+<p>{{ if .Watching }}Watching{{ else }}Not watching{{ end }},
+{{ .Checks }} checks, {{ .Failures }} failures, {{ .Items }} new items{{ if .StatusCode }}, last status {{ .StatusCode }}{{ end }}</p>
+{{ if not .LastCheck.IsZero }}<p>Last checked {{ .LastCheck.Format "2006-01-02 15:04" }}</p>{{ end }}
+{{ if .LastError }}<p class="error">{{ .LastError }}</p>{{ end }}
+{{ end }}
+<form method="post" action="{{ $.Path }}/action">{{ template "csrf" $.CSRF }}
+	<input type="hidden" name="action" value="check-feed"><input type="hidden" name="user" value="{{ $user }}">
+	<button type="submit">Check feed now</button>
+</form>
+
+<h3>Recent posts</h3>
+<table>
+<tr><th>Post</th><th>Published</th><th>Delivered</th><th></th></tr>
+{{ range .Posts }}
+<tr>
+	<td><a href="{{ .URL }}">{{ .URL }}</a></td>
+	<td>{{ .Published.Format "2006-01-02 15:04" }}</td>
+	<td>{{ .Ratio }}</td>
+	<td><form class="inline" method="post" action="{{ $.Path }}/action">{{ template "csrf" $.CSRF }}
+		<input type="hidden" name="action" value="resend-note"><input type="hidden" name="user" value="{{ $user }}"><input type="hidden" name="id" value="{{ .ID }}">
+		<button type="submit">Send again</button>
+	</form></td>
+</tr>
+{{ else }}
+<tr><td colspan="4">No posts yet</td></tr>
+{{ end }}
+</table>
+
+<h3>Pending follows</h3>
+<table>
+{{ range .Pending }}
+<tr>
+	<td><a href="{{ . }}">{{ . }}</a></td>
+	<td><form class="inline" method="post" action="{{ $.Path }}/action">{{ template "csrf" $.CSRF }}
+		<input type="hidden" name="action" value="approve-follower"><input type="hidden" name="user" value="{{ $user }}"><input type="hidden" name="id" value="{{ . }}">
+		<button type="submit">Approve</button>
+	</form>
+	<form class="inline" method="post" action="{{ $.Path }}/action">{{ template "csrf" $.CSRF }}
+		<input type="hidden" name="action" value="remove-follower"><input type="hidden" name="user" value="{{ $user }}"><input type="hidden" name="id" value="{{ . }}">
+		<button type="submit">Remove</button>
+	</form></td>
+</tr>
+{{ else }}
+<tr><td>No pending follows</td></tr>
+{{ end }}
+</table>
+
+<h3>Followers ({{ len .Followers }})</h3>
+<table>
+{{ range .Followers }}
+<tr>
+	<td><a href="{{ . }}">{{ . }}</a></td>
+	<td><form class="inline" method="post" action="{{ $.Path }}/action">{{ template "csrf" $.CSRF }}
+		<input type="hidden" name="action" value="remove-follower"><input type="hidden" name="user" value="{{ $user }}"><input type="hidden" name="id" value="{{ . }}">
+		<button type="submit">Remove</button>
+	</form></td>
+</tr>
+{{ end }}
+</table>
+{{ end }}
+
+<h2>Replies waiting for approval</h2>
+<table>
+{{ range .Replies }}
+<tr>
+	<td><a href="{{ .ActorID }}">{{ .ActorID }}</a> to {{ .User }} on <a href="{{ .InReplyTo }}">{{ .InReplyTo }}</a>, {{ .Published.Format "2006-01-02 15:04" }}
+		<div>{{ .Content }}</div></td>
+	<td>{{ $id := .ID }}{{ range $.ReplyActions }}<form class="inline" method="post" action="{{ $.Path }}/action">{{ template "csrf" $.CSRF }}
+		<input type="hidden" name="action" value="reply"><input type="hidden" name="id" value="{{ $id }}"><input type="hidden" name="state" value="{{ .Value }}">
+		<button type="submit">{{ .Label }}</button>
+	</form>{{ end }}</td>
+</tr>
+{{ else }}
+<tr><td>No replies waiting</td></tr>
+{{ end }}
+</table>
+
+<h2>Open reports</h2>
+<table>
+{{ range .Reports }}
+<tr>
+	<td>Reported by <a href="{{ .ActorID }}">{{ .ActorID }}</a> to {{ .User }}, {{ .ReceivedAt.Format "2006-01-02 15:04" }}
+		{{ if .Content }}<div>{{ .Content }}</div>{{ end }}
+		{{ range .Actors }}<div><a href="{{ . }}">{{ . }}</a></div>{{ end }}</td>
+	<td>{{ $id := .ID }}{{ range $.ReportActions }}<form class="inline" method="post" action="{{ $.Path }}/action">{{ template "csrf" $.CSRF }}
+		<input type="hidden" name="action" value="report"><input type="hidden" name="id" value="{{ $id }}"><input type="hidden" name="resolution" value="{{ .Value }}">
+		<button type="submit">{{ .Label }}</button>
+	</form>{{ end }}</td>
+</tr>
+{{ else }}
+<tr><td>No open reports</td></tr>
+{{ end }}
+</table>
+
+<h2>Failed deliveries</h2>
+<table>
+{{ range .Deliveries }}
+<tr>
+	<td>{{ .ObjectID }} to {{ .Target }}, {{ .Attempts }} attempts<div class="error">{{ .LastError }}</div></td>
+	<td><form class="inline" method="post" action="{{ $.Path }}/action">{{ template "csrf" $.CSRF }}
+		<input type="hidden" name="action" value="retry-delivery"><input type="hidden" name="id" value="{{ .ID }}">
+		<button type="submit">Retry</button>
+	</form></td>
+</tr>
+{{ else }}
+<tr><td>No failed deliveries</td></tr>
+{{ end }}
+</table>
+
+<h2>Blocks</h2>
+<table>
+{{ range .Blocks }}
+<tr>
+	<td>{{ .ID }}</td><td>{{ .Type }}</td><td>{{ .Reason }}</td>
+	<td><form class="inline" method="post" action="{{ $.Path }}/action">{{ template "csrf" $.CSRF }}
+		<input type="hidden" name="action" value="unblock"><input type="hidden" name="target" value="{{ .ID }}">
+		<button type="submit">Unblock</button>
+	</form></td>
+</tr>
+{{ end }}
+</table>
+<form method="post" action="{{ .Path }}/action">{{ template "csrf" .CSRF }}
+	<input type="hidden" name="action" value="block">
+	<input name="target" placeholder="actor id or domain" required>
+	<input name="reason" placeholder="reason">
+	<button type="submit">Block</button>
+</form>
+
+<h2>Counters</h2>
+<table>
+{{ range .Counters }}
+<tr><td>{{ .Name }}</td><td>{{ .Value }}</td></tr>
+{{ end }}
+</table>
+</body>
+</html>`
+
+// DashboardLoginData is the data for DashboardLoginTemplate
+type DashboardLoginData struct {
+	Path  string // path of the dashboard
+	Error string
+}
+
+// DashboardData is the data for DashboardTemplate
+type DashboardData struct {
+	Path          string // path of the dashboard, to which forms are posted
+	CSRF          string // token every form must post back
+	Message       string // outcome of the last action
+	Error         string // why the last action failed
+	Users         []DashboardUser
+	Replies       []DashboardReply
+	Reports       []DashboardReport
+	Deliveries    []DashboardDelivery // failed deliveries
+	Blocks        []DashboardBlock
+	Counters      []DashboardCounter
+	ReplyActions  []DashboardAction
+	ReportActions []DashboardAction
+}
+
+// DashboardUser is a local user in DashboardData
+type DashboardUser struct {
+	Name      string
+	Feed      DashboardFeed
+	Posts     []DashboardPost
+	Followers []string // accepted followers
+	Pending   []string // follows that haven't been accepted yet
+}
+
+// DashboardFeed is the health of a user's feed watcher
+type DashboardFeed struct {
+	URL        string
+	Watching   bool
+	Checks     int
+	Failures   int
+	Items      int
+	StatusCode int
+	LastError  string
+	LastCheck  time.Time
+}
+
+// DashboardPost is a recent note and how well it was delivered to followers
+type DashboardPost struct {
+	ID        string
+	URL       string
+	Published time.Time
+	Delivered int // deliveries that succeeded
+	Total     int // deliveries attempted
+}
+
+// Ratio describes the delivery success of a post
+func (p DashboardPost) Ratio() string {
+	if p.Total == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%d/%d (%d%%)", p.Delivered, p.Total, p.Delivered*100/p.Total)
+}
+
+// DashboardReply is a reply waiting for approval
+type DashboardReply struct {
+	ID        string
+	User      string
+	ActorID   string
+	InReplyTo string
+	Published time.Time
+	Content   any // string or sanitized html
+}
+
+// DashboardReport is an open report from a remote moderator
+type DashboardReport struct {
+	ID         string
+	User       string
+	ActorID    string
+	Content    string
+	Actors     []string
+	ReceivedAt time.Time
+}
+
+// DashboardDelivery is a note delivery that failed
+type DashboardDelivery struct {
+	ID        string
+	ObjectID  string
+	Target    string
+	Attempts  int
+	LastError string
+}
+
+// DashboardBlock is a blocked actor or domain
+type DashboardBlock struct {
+	ID     string
+	Type   string
+	Reason string
+}
+
+// DashboardCounter is a telemetry counter
+type DashboardCounter struct {
+	Name  string
+	Value int
+}
+
+// DashboardAction is a button on a moderation queue entry
+type DashboardAction struct {
+	Value string
+	Label string
+}
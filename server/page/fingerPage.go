@@ -1,8 +1,9 @@
 package page
 
 import (
+	"encoding/json"
 	"net/http"
-	"regexp"
+	"strings"
 
 	"github.com/tkrehbiel/activitylace/server/telemetry"
 )
@@ -12,7 +13,10 @@ import (
 type MultiStaticPage struct {
 	StaticPage
 	HostName string
-	Pages    map[string]StaticPageHandler
+	Domains  []string          // other domains whose handles belong to this server
+	Account  StaticPage        // page for each user, WebFingerAccount if not set
+	Pages    map[string][]byte // rendered pages by lower case user name
+	urls     map[string]string // user names by lower case actor and profile URLs
 }
 
 var WellKnownWebFinger = MultiStaticPage{
@@ -24,6 +28,7 @@ var WellKnownWebFinger = MultiStaticPage{
 }
 
 var WebFingerAccount = StaticPage{
+	Name:        "webfinger.json",
 	ContentType: "application/jrd+json",
 	Template: `
 {
@@ -42,18 +47,21 @@ var WebFingerAccount = StaticPage{
 			"rel": "http://webfinger.net/rel/profile-page",
 			"type": "text/html",
 			"href": "{{ .UserProfileURL }}"
+		},
+		{
+			"rel": "http://ostatus.org/schema/1.0/subscribe",
+			"template": "{{ .SubscribeTemplate }}"
 		}
 	]
 }`,
 }
 
-var acctRegex = regexp.MustCompile(`acct:(.+)@(.+)`)
-
 // Add a user resource to be served
 func (s *MultiStaticPage) Add(username string, meta MetaData) {
 	s.HostName = meta.HostName
 	if s.Pages == nil {
-		s.Pages = make(map[string]StaticPageHandler)
+		s.Pages = make(map[string][]byte)
+		s.urls = make(map[string]string)
 	}
 	userMeta := UserMetaData{
 		MetaData:       meta,
@@ -61,37 +69,110 @@ func (s *MultiStaticPage) Add(username string, meta MetaData) {
 		UserID:         meta.ActorURL(username),
 		UserProfileURL: meta.ProfileURL(username),
 	}
-	userPage := NewStaticPage(WebFingerAccount) // copy
-	err := userPage.Init(userMeta)
-	if err == nil {
-		s.Pages[username] = userPage
+	account := s.Account
+	if account.Template == "" {
+		account = WebFingerAccount
+	}
+	rendered, err := account.Render(userMeta)
+	if err != nil {
+		telemetry.Error(err, "rendering webfinger for %s", username)
+		return
+	}
+	name := strings.ToLower(username)
+	s.Pages[name] = rendered
+	s.urls[strings.ToLower(userMeta.UserID)] = name
+	s.urls[strings.ToLower(userMeta.UserProfileURL)] = name
+}
+
+// user finds the user a resource refers to, an acct: handle or the user's actor or profile URL.
+// Matching ignores case. Returns false if the resource is malformed.
+func (s MultiStaticPage) user(resource string) (string, bool) {
+	resource = strings.ToLower(resource)
+	if strings.HasPrefix(resource, "https://") || strings.HasPrefix(resource, "http://") {
+		return s.urls[strings.TrimSuffix(resource, "/")], true
+	}
+	// Some servers leave off acct: or write handles as @user@host
+	account := strings.TrimPrefix(strings.TrimPrefix(resource, "acct:"), "@")
+	username, hostname, found := strings.Cut(account, "@")
+	if !found || username == "" || hostname == "" {
+		return "", false
+	}
+	if hostname == strings.ToLower(s.HostName) {
+		return username, true
+	}
+	for _, domain := range s.Domains {
+		if hostname == strings.ToLower(domain) {
+			return username, true
+		}
+	}
+	return "", true
+}
+
+// links keeps only the links with the given rels in a rendered page
+func links(rendered []byte, rels []string) ([]byte, error) {
+	var jrd map[string]any
+	if err := json.Unmarshal(rendered, &jrd); err != nil {
+		return nil, err
+	}
+	all, _ := jrd["links"].([]any)
+	kept := make([]any, 0, len(all))
+	for _, link := range all {
+		if m, ok := link.(map[string]any); ok {
+			for _, rel := range rels {
+				if m["rel"] == rel {
+					kept = append(kept, link)
+					break
+				}
+			}
+		}
 	}
+	jrd["links"] = kept
+	return json.Marshal(jrd)
 }
 
 func (s MultiStaticPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// This one specifically uses the resource query parameter to lookup webfinger resources.
 	resource := r.URL.Query().Get("resource")
-	if resource != "" {
-		matches := acctRegex.FindSubmatch([]byte(resource))
-		if len(matches) > 0 {
-			hostname := string(matches[2])
-			username := string(matches[1])
-			if hostname == s.HostName && s.Pages[username] != nil {
-				s.Pages[username].ServeHTTP(w, r)
-				return
-			} else {
-				telemetry.Log("WARNING: unrecognized webfinger resource request for [%s]", resource)
-				telemetry.Increment("webfinger_unrecognized", 1)
-			}
-		} else {
-			telemetry.Log("WARNING: malformed webfinger resource request [%s]", resource)
-			telemetry.Increment("webfinger_malformed", 1)
-		}
-	} else {
+	if resource == "" {
 		telemetry.Log("WARNING: webfinger request without resource param")
 		telemetry.Increment("webfinger_missing", 1)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	username, ok := s.user(resource)
+	if !ok {
+		telemetry.Log("WARNING: malformed webfinger resource request [%s]", resource)
+		telemetry.Increment("webfinger_malformed", 1)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rendered := s.Pages[username]
+	if rendered == nil {
+		telemetry.Log("WARNING: unrecognized webfinger resource request for [%s]", resource)
+		telemetry.Increment("webfinger_unrecognized", 1)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Only the links asked for, if any were
+	if rels := r.URL.Query()["rel"]; len(rels) > 0 {
+		filtered, err := links(rendered, rels)
+		if err != nil {
+			telemetry.Error(err, "filtering webfinger links for [%s]", resource)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rendered = filtered
+	}
+
+	telemetry.Increment("get_requests", 1)
+	contentType := s.Account.ContentType
+	if contentType == "" {
+		contentType = WebFingerAccount.ContentType
 	}
-	w.WriteHeader(http.StatusNotFound)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(rendered)
 }
 
 func (s MultiStaticPage) Path() string {
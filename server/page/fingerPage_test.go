@@ -67,3 +67,79 @@ func TestWebFinger_UserPage(t *testing.T) {
 	assert.Equal(t, testUserID, links[0].(map[string]interface{})["href"])
 	assert.Equal(t, testUserProfile, links[1].(map[string]interface{})["href"])
 }
+
+// fingerResource serves a webfinger request and returns the status and decoded response
+func fingerResource(t *testing.T, page MultiStaticPage, query string) (int, map[string]any) {
+	recorder := httptest.NewRecorder()
+	page.ServeHTTP(recorder, httptest.NewRequest("GET", "/.well-known/webfinger?"+query, nil))
+	if recorder.Code != http.StatusOK {
+		return recorder.Code, nil
+	}
+	assert.Equal(t, "application/jrd+json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "*", recorder.Header().Get("Access-Control-Allow-Origin"))
+	var data map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &data))
+	return recorder.Code, data
+}
+
+func TestWebFinger_Resources(t *testing.T) {
+	meta := MetaData{URL: "https://social.example.com", HostName: "social.example.com", HandleDomain: "example.com"}
+	page := MultiStaticPage{Domains: []string{"example.com", "Blog.Example"}}
+	page.Add("Alice", meta)
+
+	for _, resource := range []string{
+		"acct:Alice@social.example.com",
+		"acct:alice@EXAMPLE.com",
+		"acct:alice@blog.example",
+		"alice@example.com",
+		"@alice@example.com",
+		"https://social.example.com/activity/Alice",
+		"https://SOCIAL.example.com/activity/alice/",
+		"https://social.example.com/profile/Alice",
+	} {
+		code, data := fingerResource(t, page, "resource="+url.QueryEscape(resource))
+		require.Equal(t, http.StatusOK, code, resource)
+		assert.Equal(t, "acct:Alice@example.com", data["subject"], resource)
+	}
+
+	for _, resource := range []string{
+		"acct:bob@example.com",
+		"acct:alice@other.example",
+		"https://social.example.com/activity/bob",
+		"https://other.example/activity/alice",
+	} {
+		code, _ := fingerResource(t, page, "resource="+url.QueryEscape(resource))
+		assert.Equal(t, http.StatusNotFound, code, resource)
+	}
+
+	for _, query := range []string{"", "resource=", "resource=acct:alice", "resource=acct:@example.com"} {
+		code, _ := fingerResource(t, page, query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestWebFinger_Rel(t *testing.T) {
+	meta := MetaData{URL: "https://local", HostName: "local"}
+	var page MultiStaticPage
+	page.Add("alice", meta)
+
+	_, data := fingerResource(t, page, "resource=acct:alice@local")
+	links := data["links"].([]any)
+	require.Len(t, links, 3)
+	subscribe := links[2].(map[string]any)
+	assert.Equal(t, "http://ostatus.org/schema/1.0/subscribe", subscribe["rel"])
+	assert.Equal(t, "https://local/authorize_interaction?uri={uri}", subscribe["template"])
+
+	_, data = fingerResource(t, page, "resource=acct:alice@local&rel=self")
+	links = data["links"].([]any)
+	require.Len(t, links, 1)
+	assert.Equal(t, "self", links[0].(map[string]any)["rel"])
+	assert.Equal(t, "acct:alice@local", data["subject"])
+	assert.Len(t, data["aliases"], 2)
+
+	_, data = fingerResource(t, page, "resource=acct:alice@local&rel=self&rel="+url.QueryEscape("http://webfinger.net/rel/profile-page"))
+	assert.Len(t, data["links"], 2)
+
+	_, data = fingerResource(t, page, "resource=acct:alice@local&rel=unknown")
+	assert.Empty(t, data["links"])
+}
@@ -1,6 +1,7 @@
 package page
 
 var WellKnownHostMeta = StaticPage{
+	Name:        "host-meta.xml",
 	Path:        "/.well-known/host-meta",
 	Accept:      "*/*",
 	ContentType: "application/xml",
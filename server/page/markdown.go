@@ -0,0 +1,81 @@
+package page
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Just enough Markdown for profile text: paragraphs, line breaks, lists,
+// links, bare urls, bold, italics and code. Everything else is escaped.
+var (
+	mdCode     = regexp.MustCompile("`([^`]+)`")
+	mdLink     = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^\s)]+)\)`)
+	mdAutoLink = regexp.MustCompile(`(^|[\s(])(https?://[^\s<]*[^\s<.,;:!?)])`)
+	mdStrong   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdEm       = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	mdListItem = regexp.MustCompile(`^\s*[-*+]\s+`)
+	mdBlank    = regexp.MustCompile(`\n\s*\n`)
+)
+
+// Markdown renders a little Markdown as HTML
+func Markdown(src string) string {
+	src = strings.ReplaceAll(strings.TrimSpace(src), "\r\n", "\n")
+	if src == "" {
+		return ""
+	}
+	var out strings.Builder
+	for _, block := range mdBlank.Split(src, -1) {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		if isList(lines) {
+			out.WriteString("<ul>")
+			for _, line := range lines {
+				out.WriteString("<li>")
+				out.WriteString(inlineMarkdown(mdListItem.ReplaceAllString(line, "")))
+				out.WriteString("</li>")
+			}
+			out.WriteString("</ul>")
+			continue
+		}
+		for i := range lines {
+			lines[i] = inlineMarkdown(strings.TrimSpace(lines[i]))
+		}
+		out.WriteString("<p>")
+		out.WriteString(strings.Join(lines, "<br>"))
+		out.WriteString("</p>")
+	}
+	return out.String()
+}
+
+// isList returns true if every line is a list item
+func isList(lines []string) bool {
+	for _, line := range lines {
+		if !mdListItem.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// inlineMarkdown renders the markup within a line, leaving code spans alone
+func inlineMarkdown(line string) string {
+	var out strings.Builder
+	last := 0
+	for _, span := range mdCode.FindAllStringSubmatchIndex(line, -1) {
+		out.WriteString(inlineText(line[last:span[0]]))
+		out.WriteString("<code>")
+		out.WriteString(html.EscapeString(line[span[2]:span[3]]))
+		out.WriteString("</code>")
+		last = span[1]
+	}
+	out.WriteString(inlineText(line[last:]))
+	return out.String()
+}
+
+func inlineText(s string) string {
+	s = html.EscapeString(s)
+	s = mdLink.ReplaceAllString(s, `<a href="$2" rel="nofollow noopener noreferrer" target="_blank">$1</a>`)
+	s = mdAutoLink.ReplaceAllString(s, `$1<a href="$2" rel="nofollow noopener noreferrer" target="_blank">$2</a>`)
+	s = mdStrong.ReplaceAllString(s, "<strong>$1</strong>")
+	return mdEm.ReplaceAllString(s, "<em>$1</em>")
+}
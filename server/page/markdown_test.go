@@ -0,0 +1,25 @@
+package page
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdown(t *testing.T) {
+	for _, test := range []struct {
+		src      string
+		expected string
+	}{
+		{"", ""},
+		{"plain <b>text</b> & more", "<p>plain &lt;b&gt;text&lt;/b&gt; &amp; more</p>"},
+		{"one\ntwo\n\nthree", "<p>one<br>two</p><p>three</p>"},
+		{"**bold** and *em* and `*code*`", "<p><strong>bold</strong> and <em>em</em> and <code>*code*</code></p>"},
+		{"- a\n- b", "<ul><li>a</li><li>b</li></ul>"},
+		{"[blog](https://blog.example/?a=1&b=2)", `<p><a href="https://blog.example/?a=1&amp;b=2" rel="nofollow noopener noreferrer" target="_blank">blog</a></p>`},
+		{"see https://blog.example.", `<p>see <a href="https://blog.example" rel="nofollow noopener noreferrer" target="_blank">https://blog.example</a>.</p>`},
+		{"[bad](javascript:alert(1))", "<p>[bad](javascript:alert(1))</p>"},
+	} {
+		assert.Equal(t, test.expected, Markdown(test.src), test.src)
+	}
+}
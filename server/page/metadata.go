@@ -2,7 +2,10 @@ package page
 
 import (
 	"fmt"
+	"html"
+	"mime"
 	"net/url"
+	"path"
 	"strings"
 
 	"github.com/tkrehbiel/activitylace/server/activity"
@@ -12,15 +15,24 @@ const SubPath = "activity"
 
 // MetaData contains server information typically used in templates
 type MetaData struct {
-	URL      string // full server URL with scheme, host, port
-	HostName string // server hostname
+	URL          string // full server URL with scheme, host, port
+	HostName     string // server hostname
+	HandleDomain string // domain in user handles, HostName if not set
+}
+
+// Domain is the domain in user handles, which may be a parent of the server hostname
+func (m MetaData) Domain() string {
+	if m.HandleDomain != "" {
+		return m.HandleDomain
+	}
+	return m.HostName
 }
 
 // These functions set the base paths for endpoints
 
 // WebFingerAccount gets a webfinger user account name
 func (m MetaData) WebFingerAccount(name string) string {
-	return fmt.Sprintf("acct:%s@%s", name, m.HostName)
+	return fmt.Sprintf("acct:%s@%s", name, m.Domain())
 }
 
 // ActorURL gets an ActivtyPub Actor ID and endpoint URL
@@ -35,6 +47,12 @@ func (m MetaData) ProfileURL(name string) string {
 	return s
 }
 
+// SubscribeTemplate gets the OStatus subscribe link template, with {uri} left to be filled in
+func (m MetaData) SubscribeTemplate() string {
+	s, _ := url.JoinPath(m.URL, "authorize_interaction")
+	return s + "?uri={uri}"
+}
+
 func (m MetaData) NewUserMetaData(name string) UserMetaData {
 	return UserMetaData{
 		MetaData:        m,
@@ -58,17 +76,62 @@ type UserMetaData struct {
 	UserName        string // Plain undecorated username
 	UserID          string // ActivityPub user ID (an URL for application/json+activity)
 	UserProfileURL  string // HTML user profile page (an URL)
+	BlogURL         string // home page of the blog the user posts from
 	UserDisplayName string
-	UserSummary     string
+	UserSummary     string // HTML
 	UserType        string // ActivityPub Actor type (Person, Organization, etc.)
 	AvatarURL       string
 	AvatarWidth     int
 	AvatarHeight    int
+	HeaderURL       string // banner image
+	HeaderWidth     int
+	HeaderHeight    int
 	UserPublicKeyID string
 	UserPublicKey   string
+	AlsoKnownAs     []string       // other accounts of this user, so they can move here
+	MovedTo         string         // account this user moved to
+	Fields          []ProfileField // profile metadata shown as a table
+	Discoverable    bool           // may be suggested to others and listed in directories
+	Indexable       bool           // posts may be found by full text search
+	Published       string         // when the account was created, RFC 3339
+	FeaturedTags    []Hashtag      // tags shown on the profile
 	LatestNotes     []activity.Note
 }
 
+// ProfileField is a name and value pair of profile metadata
+type ProfileField struct {
+	Name  string
+	Value string // HTML
+	Link  string // url the value links to, if any
+}
+
+// NewProfileField creates a profile field from plain text.
+// A value that's an http or https url becomes a rel=me link,
+// so servers can verify the linked page links back to this profile.
+func NewProfileField(name string, value string) ProfileField {
+	field := ProfileField{Name: name, Value: html.EscapeString(value)}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return field
+	}
+	field.Link = value
+	display := strings.TrimSuffix(strings.TrimPrefix(value, u.Scheme+"://"), "/")
+	field.Value = fmt.Sprintf(`<a href="%s" rel="me nofollow noopener noreferrer" target="_blank">%s</a>`,
+		html.EscapeString(value), html.EscapeString(display))
+	return field
+}
+
+// Hashtag is a tag and the page listing posts with it
+type Hashtag struct {
+	Name string // without the #
+	URL  string
+}
+
+// Handle is how the user is found from other servers, @name@host
+func (m UserMetaData) Handle() string {
+	return fmt.Sprintf("@%s@%s", m.UserName, m.Domain())
+}
+
 func (m UserMetaData) InboxURL() string {
 	s, _ := url.JoinPath(m.URL, fmt.Sprintf("%s/%s/inbox", SubPath, m.UserName))
 	return s
@@ -89,6 +152,30 @@ func (m UserMetaData) FollowersURL() string {
 	return s
 }
 
+func (m UserMetaData) FeaturedTagsURL() string {
+	s, _ := url.JoinPath(m.URL, fmt.Sprintf("%s/%s/tags", SubPath, m.UserName))
+	return s
+}
+
+// AvatarMediaType guesses the type of the avatar image from its name
+func (m UserMetaData) AvatarMediaType() string {
+	return imageType(m.AvatarURL)
+}
+
+// HeaderMediaType guesses the type of the header image from its name
+func (m UserMetaData) HeaderMediaType() string {
+	return imageType(m.HeaderURL)
+}
+
+// imageType guesses the media type of an image url from its extension
+func imageType(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return mime.TypeByExtension(strings.ToLower(path.Ext(u.Path)))
+}
+
 func (m UserMetaData) TransformedPublicKey() string {
 	// Replace both \r\n and \n to be sure
 	s := strings.ReplaceAll(m.UserPublicKey, "\r\n", `\n`)
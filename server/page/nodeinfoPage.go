@@ -3,12 +3,17 @@ package page
 // Serving /.well-known/nodeinfo
 
 var WellKnownNodeInfo = StaticPage{
+	Name:        "nodeinfo.json",
 	Path:        "/.well-known/nodeinfo",
 	Accept:      "*/*",
 	ContentType: "application/json",
 	Template: `
 {
 	"links": [
+		{
+			"rel": "http://nodeinfo.diaspora.software/ns/schema/2.0",
+			"href": "{{ .URL }}/nodeinfo/2.0"
+		},
 		{
 			"rel": "http://nodeinfo.diaspora.software/ns/schema/2.1",
 			"href": "{{ .URL }}/nodeinfo/2.1"
@@ -17,19 +22,57 @@ var WellKnownNodeInfo = StaticPage{
 }`,
 }
 
+// NodeInfoVersions are the versions of the NodeInfo schema served, at /nodeinfo/<version>
+var NodeInfoVersions = []string{"2.0", "2.1"}
+
+// NodeInfo is a template for NodeInfo documents, rendered with NodeInfoData.
+// Version 2.0 doesn't allow the software's repository and homepage.
 var NodeInfo = StaticPage{
-	Path:        "/.well-known/nodeinfo/2.1",
-	Accept:      "*/*",
-	ContentType: "application/json",
+	Name:   "nodeinfo-2.json",
+	Path:   "", // one for each version
+	Accept: "*/*",
 	Template: `
 {
-	"version": "2.1",
+	"version": "{{ .Version }}",
 	"software": {
 		"name": "activitylace",
-		"version": "0.0",
+		"version": {{ json .SoftwareVersion }}
+		{{- if ne .Version "2.0" }},
 		"repository": "https://github.com/tkrehbiel/activitylace/",
 		"homepage": "https://github.com/tkrehbiel/activitylace/"
+		{{- end }}
+	},
+	"protocols": ["activitypub"],
+	"services": {
+		"inbound": ["atom1.0", "rss2.0"],
+		"outbound": []
 	},
-	"protocols": ["activitypub"]
+	"openRegistrations": false,
+	"usage": {
+		"users": {
+			"total": {{ .Users }},
+			"activeMonth": {{ .ActiveMonth }},
+			"activeHalfyear": {{ .ActiveHalfyear }}
+		},
+		"localPosts": {{ .LocalPosts }}
+	},
+	"metadata": {{ json .Metadata }}
 }`,
 }
+
+// NodeInfoData is the data for NodeInfo
+type NodeInfoData struct {
+	MetaData
+	Version         string         // of the schema
+	SoftwareVersion string         // of activitylace
+	Users           int            // local users
+	ActiveMonth     int            // users who posted in the last month
+	ActiveHalfyear  int            // users who posted in the last six months
+	LocalPosts      int64          // notes of all users
+	Metadata        map[string]any // free-form, e.g. nodeName and nodeDescription
+}
+
+// NodeInfoContentType is the content type of a version of NodeInfo
+func NodeInfoContentType(version string) string {
+	return `application/json; profile="http://nodeinfo.diaspora.software/ns/schema/` + version + `#"`
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestNodeInfo(t *testing.T) {
+func TestWellKnown(t *testing.T) {
 	u, err := url.Parse("http://test")
 	require.NoError(t, err)
 	meta := NewMetaData(u)
@@ -21,20 +21,40 @@ func TestNodeInfo(t *testing.T) {
 	// Test that the JSON is valid
 	var data map[string][]map[string]interface{} // map to an array of maps heh
 	require.NoError(t, json.Unmarshal(page.rendered, &data))
-	assert.Equal(t, "http://test/nodeinfo/2.1", data["links"][0]["href"])
+	require.Len(t, data["links"], 2)
+	assert.Equal(t, "http://nodeinfo.diaspora.software/ns/schema/2.0", data["links"][0]["rel"])
+	assert.Equal(t, "http://test/nodeinfo/2.0", data["links"][0]["href"])
+	assert.Equal(t, "http://nodeinfo.diaspora.software/ns/schema/2.1", data["links"][1]["rel"])
+	assert.Equal(t, "http://test/nodeinfo/2.1", data["links"][1]["href"])
 }
 
-func TestWellKnown(t *testing.T) {
-	u, err := url.Parse("http://test")
+func TestNodeInfo(t *testing.T) {
+	info := SampleNodeInfoData()
+	b, err := NodeInfo.Render(info)
 	require.NoError(t, err)
-	meta := NewMetaData(u)
-
-	page := NewStaticPage(NodeInfo).(*internalStaticPage)
-	assert.NoError(t, page.Init(meta))
-	assert.NotNil(t, page.rendered)
 
 	// Test that the JSON is valid
 	var data map[string]interface{}
-	require.NoError(t, json.Unmarshal(page.rendered, &data))
+	require.NoError(t, json.Unmarshal(b, &data))
 	assert.Equal(t, "2.1", data["version"])
+	assert.Equal(t, map[string]interface{}{
+		"name":       "activitylace",
+		"version":    "1.0.0",
+		"repository": "https://github.com/tkrehbiel/activitylace/",
+		"homepage":   "https://github.com/tkrehbiel/activitylace/",
+	}, data["software"])
+	assert.Equal(t, false, data["openRegistrations"])
+	assert.Equal(t, map[string]interface{}{
+		"users":      map[string]interface{}{"total": 1.0, "activeMonth": 1.0, "activeHalfyear": 1.0},
+		"localPosts": 10.0,
+	}, data["usage"])
+	assert.Equal(t, map[string]interface{}{"nodeName": `A "sample" node`}, data["metadata"])
+
+	// 2.0 only allows a name and version for the software
+	info.Version = "2.0"
+	b, err = NodeInfo.Render(info)
+	require.NoError(t, err)
+	data = nil
+	require.NoError(t, json.Unmarshal(b, &data))
+	assert.Equal(t, map[string]interface{}{"name": "activitylace", "version": "1.0.0"}, data["software"])
 }
@@ -0,0 +1,88 @@
+package page
+
+import (
+	"html/template"
+	"time"
+)
+
+// ProfileTemplateName is the file name of a template that can override ProfileTemplate
+const ProfileTemplateName = "profile.html"
+
+// ProfileTemplate is an html/template for a user's profile page, which is where
+// fediverse users land when they click on the account.
+const ProfileTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .UserDisplayName }} ({{ .Handle }})</title>
+<link rel="alternate" type="application/activity+json" href="{{ .UserID }}">
+{{- range .Fields }}{{ if .Link }}
+<link rel="me" href="{{ .Link }}">
+{{- end }}{{ end }}
+</head>
+<body>
+{{ if .HeaderURL }}<img src="{{ .HeaderURL }}" alt="" style="width: 100%; max-height: 200px; object-fit: cover">{{ end }}
+<header>
+	{{ if .AvatarURL }}<img src="{{ .AvatarURL }}" alt="{{ .UserDisplayName }}" width="96" height="96">{{ end }}
+	<h1>{{ .UserDisplayName }}</h1>
+	<p>{{ .Handle }} &middot; {{ .Followers }} followers{{ if .BlogURL }} &middot; <a href="{{ .BlogURL }}">blog</a>{{ end }}</p>
+	<p>Follow this account by searching for {{ .Handle }} from your fediverse account.</p>
+</header>
+{{ .Summary }}
+{{ if .Fields }}
+<table>
+	{{ range .Fields }}<tr><th>{{ .Name }}</th><td>{{ .Value }}</td></tr>
+	{{ end }}
+</table>
+{{ end }}
+{{ if .FeaturedTags }}<p>{{ range .FeaturedTags }}<a href="{{ .URL }}">#{{ .Name }}</a> {{ end }}</p>{{ end }}
+<h2>Latest posts</h2>
+<ul>
+	{{ range .Notes }}
+	<li><a href="{{ .URL }}">{{ .Content }}</a> <time datetime="{{ .Published.Format "2006-01-02T15:04:05Z07:00" }}">{{ .Published.Format "2006-01-02" }}</time></li>
+	{{ else }}
+	<li>Nothing posted yet.</li>
+	{{ end }}
+</ul>
+</body>
+</html>`
+
+// ProfileData is the data for ProfileTemplate
+type ProfileData struct {
+	UserMetaData
+	Summary   template.HTML      // rendered markdown
+	Fields    []ProfileFieldData // profile metadata, with values as html
+	Followers int                // accepted followers
+	Notes     []ProfileNote      // latest first
+}
+
+// ProfileFieldData is one row of profile metadata in ProfileData
+type ProfileFieldData struct {
+	Name  string
+	Value template.HTML
+	Link  string // rel=me link, if any
+}
+
+// ProfileNote is one of the latest notes in ProfileData
+type ProfileNote struct {
+	URL       string
+	Content   string
+	Published time.Time
+}
+
+// NewProfileData fills in profile data from a user's metadata
+func NewProfileData(meta UserMetaData) ProfileData {
+	data := ProfileData{
+		UserMetaData: meta,
+		Summary:      template.HTML(meta.UserSummary),
+	}
+	for _, field := range meta.Fields {
+		data.Fields = append(data.Fields, ProfileFieldData{
+			Name:  field.Name,
+			Value: template.HTML(field.Value),
+			Link:  field.Link,
+		})
+	}
+	return data
+}
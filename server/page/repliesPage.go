@@ -0,0 +1,42 @@
+package page
+
+import "time"
+
+// RepliesTemplate is an html/template for the replies to a note, meant to be embedded in a blog post.
+// Hidden replies are collapsed until the reader chooses to reveal them.
+const RepliesTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Replies</title>
+</head>
+<body>
+<p>{{ .Likes }} likes, {{ .Shares }} boosts, {{ len .Replies }} replies</p>
+{{ range .Replies }}
+<article>
+	{{ if .Hidden }}<details><summary>Hidden reply, click to show</summary>{{ end }}
+	<p><a href="{{ .ActorID }}">{{ .ActorID }}</a> <a href="{{ .URL }}">{{ .Published.Format "2006-01-02 15:04" }}</a></p>
+	{{ if .Summary }}<details><summary>{{ .Summary }}</summary><div>{{ .Content }}</div></details>{{ else }}<div>{{ .Content }}</div>{{ end }}
+	{{ if .Hidden }}</details>{{ end }}
+</article>
+{{ end }}
+</body>
+</html>`
+
+// RepliesData is the data for RepliesTemplate
+type RepliesData struct {
+	ID      string
+	Likes   int
+	Shares  int
+	Replies []ReplyData
+}
+
+// ReplyData is one reply in RepliesData
+type ReplyData struct {
+	ActorID   string
+	URL       string
+	Published time.Time
+	Summary   any // content warning, string or sanitized html
+	Content   any // string or sanitized html
+	Hidden    bool
+}
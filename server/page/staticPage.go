@@ -2,6 +2,7 @@ package page
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -13,6 +14,7 @@ import (
 // StaticPage configures how to render a static web page response.
 // Static pages always return the same thing when requested.
 type StaticPage struct {
+	Name        string // File name of a template that can override this one
 	Path        string // Server path to the static page
 	Accept      string // Accept header required to receive this page
 	ContentType string // ContentType of this page
@@ -51,19 +53,34 @@ func (s internalStaticPage) Accept() string {
 	return s.source.Accept
 }
 
-func (s *internalStaticPage) Init(meta any) error {
-	t, err := template.New("").Parse(strings.TrimSpace(s.source.Template))
+// templateFuncs are available to every page template
+var templateFuncs = template.FuncMap{
+	"json": jsonString,
+}
+
+// jsonString quotes a value for a json template
+func jsonString(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Render processes the page template with the given metadata
+func (s StaticPage) Render(meta any) ([]byte, error) {
+	t, err := template.New("").Funcs(templateFuncs).Parse(strings.TrimSpace(s.Template))
 	if err != nil {
-		s.rendered = []byte(fmt.Sprintf("template error: %s", err))
-		return err
+		return []byte(fmt.Sprintf("template error: %s", err)), err
 	}
 	var buf bytes.Buffer
 	if err := t.Execute(&buf, meta); err != nil {
-		s.rendered = []byte(fmt.Sprintf("executing template: %s", err))
-		return err
+		return []byte(fmt.Sprintf("executing template: %s", err)), err
 	}
-	s.rendered = buf.Bytes()
-	return nil
+	return buf.Bytes(), nil
+}
+
+func (s *internalStaticPage) Init(meta any) error {
+	var err error
+	s.rendered, err = s.source.Render(meta)
+	return err
 }
 
 // ServeHTTP is an http handler to serve the rendered static page.
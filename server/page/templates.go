@@ -0,0 +1,185 @@
+package page
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/activity"
+)
+
+// overrides are the built-in templates that files in a template directory can replace,
+// by file name, with the data they're checked against
+var overrides = map[string]struct {
+	source string
+	sample func() any
+}{
+	WellKnownHostMeta.Name:    {WellKnownHostMeta.Template, func() any { return SampleMetaData() }},
+	WellKnownNodeInfo.Name:    {WellKnownNodeInfo.Template, func() any { return SampleMetaData() }},
+	NodeInfo.Name:             {NodeInfo.Template, func() any { return SampleNodeInfoData() }},
+	WebFingerAccount.Name:     {WebFingerAccount.Template, func() any { return SampleUserMetaData() }},
+	ActorEndpoint.Name:        {ActorEndpoint.Template, func() any { return SampleUserMetaData() }},
+	FeaturedTagsEndpoint.Name: {FeaturedTagsEndpoint.Template, func() any { return SampleUserMetaData() }},
+	ProfileTemplateName:       {ProfileTemplate, func() any { return SampleProfileData() }},
+}
+
+// TemplateNames lists the file names of the templates that can be overridden
+func TemplateNames() []string {
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadTemplates reads the files in a directory that override built-in templates.
+// Other files are left alone, they may be extra pages.
+func ReadTemplates(dir string) (map[string]string, error) {
+	templates := make(map[string]string)
+	if dir == "" {
+		return templates, nil
+	}
+	for _, name := range TemplateNames() {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return templates, err
+		}
+		templates[name] = string(b)
+	}
+	return templates, nil
+}
+
+// CheckTemplate makes sure a template overriding a built-in one runs with sample data
+// and, if it's json or xml, that it renders well-formed output
+func CheckTemplate(name string, source string) error {
+	override, ok := overrides[name]
+	if !ok {
+		return fmt.Errorf("%s isn't a template name, expected one of %s", name, strings.Join(TemplateNames(), ", "))
+	}
+	var out []byte
+	var err error
+	if name == ProfileTemplateName {
+		out, err = renderHTML(source, override.sample())
+	} else {
+		out, err = StaticPage{Template: source}.Render(override.sample())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return checkOutput(name, out)
+}
+
+// CheckPage makes sure an extra page's template runs with sample data
+// and, if its name ends in .json or .xml, that it renders well-formed output
+func CheckPage(name string, source string) error {
+	out, err := StaticPage{Template: source}.Render(SampleSiteData())
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return checkOutput(name, out)
+}
+
+// checkOutput makes sure json and xml output is well-formed, going by the name's extension
+func checkOutput(name string, out []byte) error {
+	switch filepath.Ext(name) {
+	case ".json":
+		var v any
+		if err := json.Unmarshal(out, &v); err != nil {
+			return fmt.Errorf("%s doesn't render valid json: %w", name, err)
+		}
+	case ".xml":
+		decoder := xml.NewDecoder(bytes.NewReader(out))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("%s doesn't render valid xml: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// renderHTML processes an html/template with the given data
+func renderHTML(source string, data any) ([]byte, error) {
+	t, err := htmltemplate.New("").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SampleMetaData is server metadata for checking templates
+func SampleMetaData() MetaData {
+	return MetaData{URL: "https://blog.example", HostName: "blog.example"}
+}
+
+// SampleUserMetaData is user metadata for checking templates.
+// Every field is set, with text that needs escaping.
+func SampleUserMetaData() UserMetaData {
+	meta := SampleMetaData().NewUserMetaData("sample")
+	meta.BlogURL = "https://blog.example/"
+	meta.UserDisplayName = `A "Sample" <User>`
+	meta.UserSummary = Markdown("A *sample* \"summary\" with <html>\n\nand https://blog.example/links")
+	meta.UserType = "Person"
+	meta.AvatarURL, meta.AvatarWidth, meta.AvatarHeight = "https://blog.example/avatar.png", 400, 400
+	meta.HeaderURL, meta.HeaderWidth, meta.HeaderHeight = "https://blog.example/header.jpg", 1500, 500
+	meta.UserPublicKey = "-----BEGIN PUBLIC KEY-----\nMIIBIjAN\n-----END PUBLIC KEY-----\n"
+	meta.AlsoKnownAs = []string{"https://old.example/users/sample"}
+	meta.MovedTo = "https://new.example/users/sample"
+	meta.Fields = []ProfileField{NewProfileField("Blog", "https://blog.example/"), NewProfileField(`"Quoted"`, "<b>text</b>")}
+	meta.Discoverable = true
+	meta.Published = "2020-02-03T00:00:00Z"
+	meta.FeaturedTags = []Hashtag{{Name: "sample", URL: "https://blog.example/tags/sample"}}
+	meta.LatestNotes = []activity.Note{{Type: activity.NoteType, ID: "https://blog.example/post", Content: `A "post"`}}
+	return meta
+}
+
+// SampleProfileData is profile page data for checking templates
+func SampleProfileData() ProfileData {
+	data := NewProfileData(SampleUserMetaData())
+	data.Followers = 2
+	data.Notes = []ProfileNote{{URL: "https://blog.example/post", Content: `A "post" <title>`, Published: time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC)}}
+	return data
+}
+
+// SampleNodeInfoData is NodeInfo data for checking templates
+func SampleNodeInfoData() NodeInfoData {
+	return NodeInfoData{
+		MetaData:        SampleMetaData(),
+		Version:         "2.1",
+		SoftwareVersion: "1.0.0",
+		Users:           1,
+		ActiveMonth:     1,
+		ActiveHalfyear:  1,
+		LocalPosts:      10,
+		Metadata:        map[string]any{"nodeName": `A "sample" node`},
+	}
+}
+
+// SiteData is the data for extra pages
+type SiteData struct {
+	MetaData
+	Users []UserMetaData
+}
+
+// SampleSiteData is extra page data for checking templates
+func SampleSiteData() SiteData {
+	return SiteData{MetaData: SampleMetaData(), Users: []UserMetaData{SampleUserMetaData()}}
+}
@@ -0,0 +1,38 @@
+package page
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTemplate_BuiltIn(t *testing.T) {
+	for _, name := range TemplateNames() {
+		assert.NoError(t, CheckTemplate(name, overrides[name].source), name)
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	assert.NoError(t, CheckTemplate("actor.json", `{"name": {{ json .UserDisplayName }}}`))
+	assert.ErrorContains(t, CheckTemplate("actor.json", `{"name": "{{ .UserDisplayName }}"}`), "valid json")
+	assert.ErrorContains(t, CheckTemplate("actor.json", `{{ .Nothing }}`), "actor.json")
+	assert.ErrorContains(t, CheckTemplate("host-meta.xml", `<XRD>`), "valid xml")
+	assert.NoError(t, CheckTemplate("profile.html", `<h1>{{ .UserDisplayName }}</h1>{{ .Summary }}`))
+	assert.ErrorContains(t, CheckTemplate("robots.txt", ``), "isn't a template name")
+
+	assert.NoError(t, CheckPage("about.html", `{{ range .Users }}{{ .UserDisplayName }}{{ end }}`))
+	assert.ErrorContains(t, CheckPage("security.json", `{{ .URL }}`), "valid json")
+}
+
+func TestReadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "actor.json"), []byte(`{}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "robots.txt"), []byte(`User-agent: *`), 0600))
+
+	templates, err := ReadTemplates(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"actor.json": `{}`}, templates)
+}
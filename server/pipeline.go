@@ -15,17 +15,42 @@ import (
 // (The rate limiting is not yet implemented.)
 type OutputPipeline struct {
 	host      string
-	client    http.Client
+	client    http.Client // for remote servers
+	trusted   http.Client // for destinations configured by the operator
 	pipeline  chan QueueHandler
 	waitGroup sync.WaitGroup
+	later     []QueueHandler // handlers from QueueLater waiting for the pipeline
+	feeding   bool           // whether a goroutine is feeding later handlers to the pipeline
+	lock      sync.Mutex     // guards later and feeding
 }
 
 type QueueHandler interface {
 	fmt.Stringer
-	Prepare(*OutputPipeline) (*http.Request, error)
+	Prepare(context.Context, *OutputPipeline) (*http.Request, error)
 	Receive(resp *http.Response)
 }
 
+// FailureHandler is implemented by handlers that want to know
+// when their request couldn't be prepared or sent at all
+type FailureHandler interface {
+	Fail(err error)
+}
+
+// TrustedHandler is implemented by handlers that send to a destination configured
+// by the operator, like a webhook, which may be on a private network that requests
+// to remote servers aren't allowed to reach
+type TrustedHandler interface {
+	Trusted() bool
+}
+
+// clientFor returns the client a handler's request is sent with
+func (p *OutputPipeline) clientFor(handler QueueHandler) *http.Client {
+	if t, ok := handler.(TrustedHandler); ok && t.Trusted() {
+		return &p.trusted
+	}
+	return &p.client
+}
+
 func (p *OutputPipeline) Queue(handler QueueHandler) {
 	if p == nil {
 		panic("no pipeline")
@@ -37,6 +62,44 @@ func (p *OutputPipeline) Queue(handler QueueHandler) {
 	p.pipeline <- handler
 }
 
+// QueueLater queues a handler without waiting for the pipeline to take it.
+// For requests made while the pipeline may be busy, like admin actions.
+// Handlers wait in order and are fed to the pipeline by a single goroutine.
+func (p *OutputPipeline) QueueLater(handler QueueHandler) {
+	if p == nil {
+		panic("no pipeline")
+	}
+	if p.pipeline == nil {
+		panic("no pipeline channel")
+	}
+	p.waitGroup.Add(1)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.later = append(p.later, handler)
+	if !p.feeding {
+		p.feeding = true
+		go p.feed()
+	}
+}
+
+// feed passes handlers waiting from QueueLater to the pipeline until there are none left
+func (p *OutputPipeline) feed() {
+	for {
+		p.lock.Lock()
+		if len(p.later) == 0 {
+			p.feeding = false
+			p.later = nil
+			p.lock.Unlock()
+			return
+		}
+		handler := p.later[0]
+		p.later[0] = nil
+		p.later = p.later[1:]
+		p.lock.Unlock()
+		p.pipeline <- handler
+	}
+}
+
 // Flush blocks until the pipeline is empty
 func (p *OutputPipeline) Flush() {
 	p.waitGroup.Wait()
@@ -66,20 +129,26 @@ func (p *OutputPipeline) Run(ctx context.Context) error {
 			return ctx.Err()
 		case handler := <-p.pipeline:
 			telemetry.Trace("pipeline queue, message received [%s]", handler.String())
-			r, err := handler.Prepare(p)
+			r, err := handler.Prepare(ctx, p)
 			if err != nil {
 				telemetry.Error(err, "pipeline queue, getting request")
+				if f, ok := handler.(FailureHandler); ok {
+					f.Fail(err)
+				}
 			} else {
 				telemetry.Request(r, "outgoing")
-				resp, err := p.client.Do(r)
+				resp, err := p.clientFor(handler).Do(r)
 				if err != nil {
 					telemetry.Error(err, "pipeline queue, getting response")
+					if f, ok := handler.(FailureHandler); ok {
+						f.Fail(err)
+					}
 				} else {
 					telemetry.Response(resp, "%s", r.URL)
 					handler.Receive(resp)
 				}
-				p.waitGroup.Done()
 			}
+			p.waitGroup.Done()
 		}
 	}
 }
@@ -93,6 +162,9 @@ func NewPipeline() *OutputPipeline {
 		client: http.Client{
 			Timeout: time.Second * 5,
 		},
+		trusted: http.Client{
+			Timeout: time.Second * 5,
+		},
 		pipeline: make(chan QueueHandler),
 	}
 }
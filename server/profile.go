@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/page"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+const (
+	profileNotes    = 10          // latest notes shown on a profile page
+	profileCacheTTL = time.Minute // how long a rendered profile page is served before rendering it again
+)
+
+// profileHandler serves a user's html profile page.
+// Pages are rendered at most once a minute.
+func (s *ActivityService) profileHandler(user *ActivityUser) http.HandlerFunc {
+	profileTemplate := s.profileTemplate // replaced along with the router on reload
+	return func(w http.ResponseWriter, r *http.Request) {
+		telemetry.Increment("get_requests", 1)
+		item, err := s.pages.Fetch("profile/"+user.name, profileCacheTTL, func() ([]byte, error) {
+			return s.renderProfile(user, profileTemplate)
+		})
+		if err != nil {
+			telemetry.Error(err, "rendering profile of %s", user.name)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(item.Value())
+	}
+}
+
+// renderProfile renders a user's profile page with their latest notes and follower count
+func (s *ActivityService) renderProfile(user *ActivityUser, profileTemplate *template.Template) ([]byte, error) {
+	telemetry.Increment("profile_renders", 1)
+	data := page.NewProfileData(user.meta)
+
+	followers, err := user.outbox.followers.GetFollowers()
+	if err != nil {
+		return nil, err
+	}
+	for _, follower := range followers {
+		if follower.RequestStatus == "accepted" {
+			data.Followers++
+		}
+	}
+
+	notes, err := user.outbox.notes.GetLatestNotes(profileNotes)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range notes {
+		profileNote := page.ProfileNote{URL: note.URL, Content: note.Content, Published: note.Published}
+		if profileNote.URL == "" {
+			profileNote.URL = note.ID
+		}
+		data.Notes = append(data.Notes, profileNote)
+	}
+
+	var buf bytes.Buffer
+	if err := profileTemplate.Execute(&buf, &data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/page"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+// profileService creates a service with a user that has a profile and a note
+func profileService(t *testing.T) *ActivityService {
+	svc := moderatedService(t)
+	svc.pages = ccache.New(ccache.Configure[[]byte]())
+	svc.loadTemplates()
+
+	user := &svc.users[0]
+	user.meta = page.MetaData{URL: "https://local", HostName: "local"}.NewUserMetaData("test")
+	usercfg := userConfig{
+		Name:        "test",
+		DisplayName: "Test <Blog>",
+		SourceURL:   "https://blog.example/index.xml",
+		Summary:     "About *this* blog",
+		Icon:        imageConfig{URL: "https://blog.example/me.png"},
+		Fields:      []profileField{{Name: "Home", Value: "https://blog.example/"}},
+	}
+	usercfg.profile(&user.meta)
+	user.outbox = ActivityOutbox{
+		service:   svc,
+		notes:     user.store.(storage.Notes),
+		followers: user.store.(storage.Followers),
+	}
+	require.NoError(t, user.outbox.followers.SaveFollow(storage.Follow{ID: "https://remote/users/a", RequestStatus: "accepted"}))
+	require.NoError(t, user.outbox.followers.SaveFollow(storage.Follow{ID: "https://remote/users/b", RequestStatus: "pending"}))
+	require.NoError(t, user.outbox.notes.SaveNote(&storage.Note{ID: "https://blog.example/first", Content: "First <post>", Published: time.Now()}))
+	return svc
+}
+
+// getProfile requests a user's profile page
+func getProfile(t *testing.T, svc *ActivityService) string {
+	w := httptest.NewRecorder()
+	svc.profileHandler(&svc.users[0])(w, httptest.NewRequest("GET", "/profile/test", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	return w.Body.String()
+}
+
+func TestProfile_Page(t *testing.T) {
+	svc := profileService(t)
+	body := getProfile(t, svc)
+	assert.Contains(t, body, "<h1>Test &lt;Blog&gt;</h1>")
+	assert.Contains(t, body, "@test@local")
+	assert.Contains(t, body, "1 followers")
+	assert.Contains(t, body, `<a href="https://blog.example/">blog</a>`)
+	assert.Contains(t, body, `<img src="https://blog.example/me.png"`)
+	assert.Contains(t, body, "<p>About <em>this</em> blog</p>")
+	assert.Contains(t, body, `<link rel="me" href="https://blog.example/">`)
+	assert.Contains(t, body, `<a href="https://blog.example/first">First &lt;post&gt;</a>`)
+
+	// new notes show up once the cached page expires
+	require.NoError(t, svc.users[0].outbox.notes.SaveNote(&storage.Note{ID: "https://blog.example/second", Content: "Second", Published: time.Now()}))
+	assert.NotContains(t, getProfile(t, svc), "Second")
+	svc.pages.Clear()
+	assert.Contains(t, getProfile(t, svc), "Second")
+}
+
+func TestProfile_Template(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "profile.html")
+	require.NoError(t, os.WriteFile(filename, []byte(`{{ .Handle }} has {{ .Followers }} followers and {{ len .Notes }} notes`), 0600))
+
+	svc := profileService(t)
+	svc.config.Server.ProfileTemplate = filename
+	svc.loadTemplates()
+	assert.Equal(t, "@test@local has 1 followers and 1 notes", getProfile(t, svc))
+
+	// templates that don't parse are caught by validation
+	require.NoError(t, os.WriteFile(filename, []byte(`{{ .Handle `), 0600))
+	cfg := Config{URL: "https://local", Users: []userConfig{{Name: "test", SourceURL: "https://blog.example/index.xml"}}}
+	cfg.Server.ProfileTemplate = filename
+	require.Len(t, cfg.Validate(), 1)
+	assert.Contains(t, cfg.Validate()[0].Error(), "server.profile_template")
+}
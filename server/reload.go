@@ -0,0 +1,278 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/page"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// ReloadResult describes what reloading the config changed
+type ReloadResult struct {
+	Added   []string `json:"added"`   // users added
+	Removed []string `json:"removed"` // users removed
+	Updated []string `json:"updated"` // users whose actor changed, their followers are sent an Update
+	Restart []string `json:"restart"` // changed settings that only take effect after a restart
+}
+
+// restartSettings are the settings a reload can't change.
+// Each returns a pointer to the setting in a config.
+var restartSettings = []struct {
+	name    string
+	setting func(c *Config) any
+}{
+	{"url", func(c *Config) any { return &c.URL }},
+	{"server.host", func(c *Config) any { return &c.Server.HostName }},
+	{"server.handle_domain", func(c *Config) any { return &c.Server.HandleDomain }},
+	{"server.port", func(c *Config) any { return &c.Server.Port }},
+	{"server.certificate", func(c *Config) any { return &c.Server.Certificate }},
+	{"server.privatekey", func(c *Config) any { return &c.Server.PrivateKey }},
+	{"server.key_dir", func(c *Config) any { return &c.Server.KeyDir }},
+	{"server.fetch_allow", func(c *Config) any { return &c.Server.FetchAllow }},
+	{"server.admin.listen", func(c *Config) any { return &c.Server.Admin.Listen }},
+	{"server.admin.prefix", func(c *Config) any { return &c.Server.Admin.Prefix }},
+	{"server.processed_retention_days", func(c *Config) any { return &c.Server.ProcessedRetentionDays }},
+	{"server.inbox_retention_days", func(c *Config) any { return &c.Server.InboxRetentionDays }},
+	{"server.request_timeout_seconds", func(c *Config) any { return &c.Server.RequestTimeoutSeconds }},
+	{"server.client_timeout_seconds", func(c *Config) any { return &c.Server.ClientTimeoutSeconds }},
+	{"notify", func(c *Config) any { return &c.Notify }},
+}
+
+// errNoConfigSource means the service doesn't know where to reload its config from
+var errNoConfigSource = errors.New("no config source to reload from")
+
+// SetConfigSource sets how the config is read again when it's reloaded
+func (s *ActivityService) SetConfigSource(source func() (Config, error)) {
+	s.source = source
+}
+
+// ReloadConfig reads the config again and applies it
+func (s *ActivityService) ReloadConfig() (ReloadResult, error) {
+	if s.source == nil {
+		return ReloadResult{}, errNoConfigSource
+	}
+	cfg, err := s.source()
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	return s.Reload(cfg)
+}
+
+// Reload applies a changed config to the running service.
+// Users are added and removed, pages are rendered again, feed watchers are restarted,
+// and followers are sent an Update for any actor that changed.
+// Settings that need a restart keep their running values and are listed in the result.
+func (s *ActivityService) Reload(cfg Config) (ReloadResult, error) {
+	var result ReloadResult
+	if problems := cfg.Validate(); len(problems) > 0 {
+		messages := make([]string, 0, len(problems))
+		for _, problem := range problems {
+			messages = append(messages, problem.Error())
+		}
+		return result, fmt.Errorf("config has problems: %s", strings.Join(messages, "; "))
+	}
+
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+
+	for _, restart := range restartSettings {
+		running := reflect.ValueOf(restart.setting(&s.config)).Elem()
+		loaded := reflect.ValueOf(restart.setting(&cfg)).Elem()
+		if !reflect.DeepEqual(running.Interface(), loaded.Interface()) {
+			result.Restart = append(result.Restart, restart.name)
+			loaded.Set(running)
+		}
+	}
+
+	previous := make(map[string]*ActivityUser)
+	actors := make(map[string][]byte)
+	for i := range s.users {
+		previous[s.users[i].name] = &s.users[i]
+		actors[s.users[i].name], _ = s.renderActor(&s.users[i])
+	}
+
+	var problems []string
+	users := make([]ActivityUser, 0, len(cfg.Users))
+	kept := make(map[string]bool)
+	for _, usercfg := range cfg.Users {
+		prev := previous[usercfg.Name]
+		user, err := s.newUser(cfg, usercfg, prev)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("user %s: %s", usercfg.Name, err))
+			if prev == nil {
+				continue
+			}
+			// keep it running as it was
+			user = *prev
+		}
+		users = append(users, user)
+		kept[usercfg.Name] = true
+		if prev == nil {
+			result.Added = append(result.Added, usercfg.Name)
+		}
+	}
+
+	s.state.Lock()
+	s.config = cfg
+	s.users = users
+	s.state.Unlock()
+	s.loadTemplates()
+	inboxes := make([]*ActivityInbox, 0, len(s.users))
+	for i := range s.users {
+		inboxes = append(inboxes, &s.users[i].inbox)
+	}
+	s.inboxQueue.Set(inboxes)
+	if s.webhooks != nil {
+		s.webhooks.setConfig(cfg.webhooks())
+	}
+	s.buildRouter()
+
+	for name, prev := range previous {
+		if kept[name] {
+			continue
+		}
+		result.Removed = append(result.Removed, name)
+		prev.outbox.feed.stop()
+		s.inboxQueue.Drain(prev.inbox.id)
+		prev.store.Close()
+	}
+	sort.Strings(result.Removed)
+
+	for i := range s.users {
+		user := &s.users[i]
+		// the watcher has a copy of the old outbox, so always replace it
+		s.watchFeed(user)
+
+		before, ok := actors[user.name]
+		if !ok {
+			continue
+		}
+		after, err := s.renderActor(user)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("user %s: %s", user.name, err))
+			continue
+		}
+		if !bytes.Equal(before, after) {
+			result.Updated = append(result.Updated, user.name)
+			s.sendActorUpdate(user, after)
+		}
+	}
+
+	telemetry.Log("reloaded config: added %v, removed %v, updated %v, needs restart %v",
+		result.Added, result.Removed, result.Updated, result.Restart)
+	if len(problems) > 0 {
+		return result, errors.New(strings.Join(problems, "; "))
+	}
+	return result, nil
+}
+
+// renderActor returns the actor document of a local user
+func (s *ActivityService) renderActor(user *ActivityUser) ([]byte, error) {
+	return s.override(page.ActorEndpoint).Render(user.meta)
+}
+
+// sendActorUpdate queues an Update of a local actor to all of its followers
+func (s *ActivityService) sendActorUpdate(user *ActivityUser, actor []byte) {
+	followers, err := user.outbox.followers.GetFollowers()
+	if err != nil {
+		telemetry.Error(err, "getting followers of %s", user.name)
+		return
+	}
+	for _, follower := range followers {
+		s.pipeline.QueueLater(&ActorUpdate{
+			outbox:   &user.outbox,
+			localID:  user.meta.UserID,
+			remoteID: follower.ID,
+			actor:    actor,
+		})
+	}
+	telemetry.Log("sending update of %s to %d followers", user.name, len(followers))
+}
+
+// ActorUpdate tells a follower that a local actor's profile changed
+type ActorUpdate struct {
+	outbox   *ActivityOutbox
+	localID  string
+	remoteID string
+	actor    json.RawMessage
+}
+
+func (u *ActorUpdate) String() string {
+	return fmt.Sprintf("Update %s to %s", u.localID, u.remoteID)
+}
+
+func (u *ActorUpdate) Prepare(ctx context.Context, pipeline *OutputPipeline) (*http.Request, error) {
+	remote, err := u.outbox.service.GetActor(ctx, u.remoteID)
+	if err != nil {
+		return nil, fmt.Errorf("looking up remote actor: %w", err)
+	}
+
+	updateObject := struct {
+		Context string          `json:"@context"`
+		Type    string          `json:"type"`
+		ID      string          `json:"id"`
+		Actor   string          `json:"actor"`
+		Object  json.RawMessage `json:"object"`
+		To      []string        `json:"to"`
+		CC      []string        `json:"cc"`
+	}{
+		Context: activity.Context,
+		Type:    activity.UpdateType,
+		ID:      fmt.Sprintf("%s#updates/%s", u.localID, uuid.NewString()),
+		Actor:   u.localID,
+		Object:  u.actor,
+		To:      []string{activity.PublicAddress},
+		CC:      []string{u.remoteID},
+	}
+
+	r, err := u.outbox.service.ActivityRequest(http.MethodPost, remote.Inbox, &updateObject)
+	if err != nil {
+		return nil, fmt.Errorf("creating update request: %w", err)
+	}
+
+	if u.outbox.privKey != nil && !u.outbox.sendUnsigned {
+		sign(u.outbox.privKey, u.outbox.pubKeyID, r)
+	}
+
+	telemetry.Increment("updates_sent", 1)
+	return r, nil
+}
+
+func (u *ActorUpdate) Receive(resp *http.Response) {
+	telemetry.Trace("received response from update %d", resp.StatusCode)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		telemetry.Increment("updates_succeeded", 1)
+	} else {
+		telemetry.Increment("updates_failed", 1)
+		u.outbox.deliveryFailed(activity.UpdateType, u.localID, u.remoteID, fmt.Sprintf("status %d", resp.StatusCode))
+	}
+}
+
+func (u *ActorUpdate) Fail(err error) {
+	telemetry.Increment("updates_failed", 1)
+	u.outbox.deliveryFailed(activity.UpdateType, u.localID, u.remoteID, err.Error())
+}
+
+// adminReload reloads the config
+func (s *ActivityService) adminReload(w http.ResponseWriter, r *http.Request) {
+	result, err := s.ReloadConfig()
+	if errors.Is(err, errNoConfigSource) {
+		adminError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		adminError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, result)
+}
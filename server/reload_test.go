@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+// reloadService creates a service from a config in a temporary directory
+func reloadService(t *testing.T, cfg Config) *ActivityService {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	svc := NewService(cfg)
+	t.Cleanup(func() {
+		for i := range svc.users {
+			svc.users[i].store.Close()
+		}
+		svc.store.Close()
+	})
+	return svc
+}
+
+// reloadConfig is a valid config with the given users
+func reloadConfig(users ...userConfig) Config {
+	return Config{
+		URL: "https://local",
+		Server: serverConfig{
+			KeyDir:       "keys",
+			FetchAllow:   []string{"127.0.0.1", "::1"},
+			SendUnsigned: true,
+		},
+		Users: users,
+	}
+}
+
+// reloadUser is a valid user config
+func reloadUser(name string, displayName string) userConfig {
+	return userConfig{Name: name, DisplayName: displayName, SourceURL: "https://blog/" + name + "/index.xml"}
+}
+
+// statusOf returns the status of a GET to the service
+func statusOf(svc *ActivityService, target string) int {
+	w := httptest.NewRecorder()
+	svc.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	return w.Code
+}
+
+func TestReload_Users(t *testing.T) {
+	cfg := reloadConfig(reloadUser("alice", "Alice"))
+	svc := reloadService(t, cfg)
+	assert.Equal(t, http.StatusOK, statusOf(svc, "/profile/alice"))
+	assert.Equal(t, http.StatusNotFound, statusOf(svc, "/profile/bob"))
+
+	cfg = reloadConfig(reloadUser("bob", "Bob"))
+	cfg.Server.Port = 9090
+	result, err := svc.Reload(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, result.Added)
+	assert.Equal(t, []string{"alice"}, result.Removed)
+	assert.Empty(t, result.Updated)
+	assert.Equal(t, []string{"server.port"}, result.Restart)
+	assert.Equal(t, 0, svc.config.Server.Port, "the running port is kept")
+
+	assert.Equal(t, http.StatusNotFound, statusOf(svc, "/profile/alice"))
+	assert.Equal(t, http.StatusOK, statusOf(svc, "/profile/bob"))
+	assert.Equal(t, http.StatusOK, statusOf(svc, "/.well-known/webfinger?resource=acct:bob@local"))
+
+	// a broken config is refused and the running one kept
+	_, err = svc.Reload(reloadConfig(reloadUser("bad name", "")))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusOK, statusOf(svc, "/profile/bob"))
+}
+
+func TestReload_UpdateActor(t *testing.T) {
+	var lock sync.Mutex
+	var updates []activity.Activity
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var act activity.Activity
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&act))
+			lock.Lock()
+			updates = append(updates, act)
+			lock.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		actor := activity.Actor{
+			Type:  activity.PersonType,
+			ID:    remote.URL + r.URL.Path,
+			Inbox: remote.URL + r.URL.Path + "/inbox",
+		}
+		w.Header().Set("Content-Type", activity.ContentType)
+		w.Write(jsonBytes(&actor))
+	}))
+	defer remote.Close()
+
+	svc := reloadService(t, reloadConfig(reloadUser("alice", "Alice")))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go svc.pipeline.Run(ctx)
+
+	followers := svc.users[0].store.(storage.Followers)
+	require.NoError(t, followers.SaveFollow(storage.Follow{ID: remote.URL + "/users/follower", RequestStatus: "accepted"}))
+
+	// an unchanged config sends nothing
+	result, err := svc.Reload(reloadConfig(reloadUser("alice", "Alice")))
+	require.NoError(t, err)
+	assert.Empty(t, result.Updated)
+
+	result, err = svc.Reload(reloadConfig(reloadUser("alice", "Alice Liddell")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, result.Updated)
+	svc.pipeline.Flush()
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, updates, 1)
+	assert.Equal(t, activity.UpdateType, updates[0].Type)
+	assert.Equal(t, "https://local/activity/alice", updates[0].Actor)
+}
+
+func TestReload_Admin(t *testing.T) {
+	svc, handler := adminService(t)
+	w := adminRequest(t, handler, "POST", "/admin/reload", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	svc.SetConfigSource(func() (Config, error) {
+		return reloadConfig(reloadUser("bad name", "")), nil
+	})
+	w = adminRequest(t, handler, "POST", "/admin/reload", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Run with -race to check a reload doesn't race requests being served
+func TestReload_WhileServing(t *testing.T) {
+	cfg := reloadConfig(reloadUser("alice", "Alice"), reloadUser("bob", "Bob"))
+	cfg.Server.ReceiveUnsigned = true
+	svc := reloadService(t, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go svc.inboxQueue.Run(ctx)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, name := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				statusOf(svc, "/profile/"+name)
+				statusOf(svc, "/nodeinfo/2.0")
+				body := fmt.Sprintf(`{"type":%q,"id":"https://remote.example/follows/%s/%d","actor":"https://remote.example/users/a","object":"https://local/activity/%s"}`,
+					activity.FollowType, name, i, name)
+				w := httptest.NewRecorder()
+				svc.ServeHTTP(w, httptest.NewRequest("POST", "/activity/"+name+"/inbox", strings.NewReader(body)))
+				svc.findUser(name)
+				svc.validAdminToken("token")
+				svc.FeedStatus()
+				svc.Replies()
+			}
+		}(name)
+	}
+
+	for i := 0; i < 20; i++ {
+		users := []userConfig{reloadUser("alice", "Alice")}
+		if i%2 == 1 {
+			users = append(users, reloadUser("bob", "Bob"))
+		}
+		next := reloadConfig(users...)
+		next.Server.ReceiveUnsigned = true
+		_, err := svc.Reload(next)
+		require.NoError(t, err)
+	}
+	close(done)
+	wg.Wait()
+	svc.inboxQueue.Flush()
+}
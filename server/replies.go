@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// Create handles a Create activity.
+// Public notes replying to one of our notes are stored as comments.
+// Other notes mentioning the inbox owner are stored as notifications.
+func (ai *ActivityInbox) Create(act activity.Activity, body []byte) error {
+	telemetry.Increment("create_requests", 1)
+
+	// The actor is the id of the person who created the note
+	actorID := parseID(act.Actor)
+
+	var message = fmt.Sprintf("POST create [%s] by [%s] at inbox [%s]", act.ID, actorID, ai.id)
+	defer func() {
+		telemetry.Log(message)
+	}()
+
+	// Keep the original note json exactly as it was sent
+	var create struct {
+		Object json.RawMessage `json:"object"`
+	}
+	if err := json.Unmarshal(body, &create); err != nil {
+		message += " - rejected, bad object"
+		return reject("unmarshaling Create object: %s", err)
+	}
+	var note activity.Note
+	if err := json.Unmarshal(create.Object, &note); err != nil {
+		message += " - rejected, bad note"
+		return reject("unmarshaling note: %s", err)
+	}
+
+	if note.Type != activity.NoteType || note.ID == "" {
+		message += " - rejected, not a note"
+		return reject("can't create [%s] object [%s]", note.Type, note.ID)
+	}
+	if attributedTo := parseID(note.AttributedTo); attributedTo != actorID {
+		// Someone is trying to create a note on someone else's behalf
+		message += " - rejected, wrong author"
+		return reject("note attributed to [%s] created by [%s]", attributedTo, actorID)
+	}
+	if !sameHost(note.ID, actorID) {
+		message += " - rejected, note on another host"
+		return reject("note [%s] created by [%s]", note.ID, actorID)
+	}
+	// Notes that mention us without being a public reply become notifications
+	mentioned := ai.isMentioned(act, note)
+	if !act.IsPublic() {
+		if mentioned {
+			return ai.notifyNote(act, note, storage.NotifyDirect, &message)
+		}
+		// Private replies shouldn't show up as public comments
+		message += " - rejected, not public"
+		return reject("note [%s] is not public", note.ID)
+	}
+
+	inReplyTo := parseID(note.InReplyTo)
+	if inReplyTo == "" {
+		if mentioned {
+			return ai.notifyNote(act, note, storage.NotifyMention, &message)
+		}
+		message += " - rejected, not a reply"
+		return reject("note [%s] is not a reply", note.ID)
+	}
+	original, err := ai.notes.FindNote(inReplyTo)
+	if err != nil {
+		message += " - failed, database read error"
+		return fmt.Errorf("finding note: %w", err)
+	}
+	if original == nil {
+		if mentioned {
+			return ai.notifyNote(act, note, storage.NotifyMention, &message)
+		}
+		message += " - rejected, not a reply to us"
+		return reject("note [%s] replies to unknown note [%s]", note.ID, inReplyTo)
+	}
+
+	existing, err := ai.replies.FindReply(note.ID)
+	if err != nil {
+		message += " - failed, database read error"
+		return fmt.Errorf("finding reply: %w", err)
+	}
+	if existing != nil && existing.ActorID != actorID {
+		// Someone is trying to replace another actor's reply
+		message += " - rejected, wrong author"
+		return reject("create of [%s] by [%s] but written by [%s]", note.ID, actorID, existing.ActorID)
+	}
+	if existing != nil && existing.IsDeleted() {
+		// The Delete arrived before this Create
+		message += " - already deleted"
+		return nil
+	}
+
+	state, err := ai.replyState(actorID)
+	if err != nil {
+		message += " - failed, database read error"
+		return fmt.Errorf("moderating reply: %w", err)
+	}
+	if existing != nil {
+		// Don't undo a moderator's decision
+		state = existing.State
+	}
+
+	reply := storage.Reply{
+		ID:         note.ID,
+		ActivityID: act.ID,
+		InReplyTo:  inReplyTo,
+		ActorID:    actorID,
+		URL:        note.URL,
+		State:      state,
+		Source:     string(create.Object),
+	}
+	sanitizeReply(&reply, note)
+	reply.Published, err = time.Parse(time.RFC3339, note.Published)
+	if err != nil {
+		reply.Published = time.Now().UTC()
+	}
+	if err := ai.replies.SaveReply(&reply); err != nil {
+		message += " - database write error"
+		return fmt.Errorf("saving reply: %w", err)
+	}
+
+	telemetry.Increment("replies_received", 1)
+	ai.emit(EventReply, map[string]string{
+		"id":        reply.ID,
+		"inReplyTo": reply.InReplyTo,
+		"actor":     reply.ActorID,
+		"url":       reply.URL,
+		"state":     reply.ModerationState(),
+	})
+	message += " - success"
+	return nil
+}
+
+// isMentioned returns true if the note is addressed to the inbox owner or tags them in a Mention
+func (ai *ActivityInbox) isMentioned(act activity.Activity, note activity.Note) bool {
+	if ai.ownerID == "" {
+		return false
+	}
+	for _, list := range [][]string{act.To, act.CC} {
+		for _, address := range list {
+			if address == ai.ownerID {
+				return true
+			}
+		}
+	}
+	tags, ok := note.Tag.([]interface{})
+	if !ok {
+		tags = []interface{}{note.Tag}
+	}
+	for _, item := range tags {
+		tag, ok := item.(map[string]interface{})
+		if ok && tag[activity.TypeProperty] == activity.MentionType && tag["href"] == ai.ownerID {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyNote stores a mention or direct message for the inbox owner
+func (ai *ActivityInbox) notifyNote(act activity.Activity, note activity.Note, kind string, message *string) error {
+	if ai.notifier == nil {
+		*message += " - rejected, no notifications"
+		return reject("note [%s] is not a reply and notifications are off", note.ID)
+	}
+	n := storage.Notification{
+		ID:       act.ID,
+		User:     ai.ownerID,
+		Type:     kind,
+		ActorID:  parseID(act.Actor),
+		ObjectID: note.ID,
+		Content:  sanitizeHTML(note.Content, parseEmojis(note.Tag)),
+		URL:      note.URL,
+	}
+	if n.ID == "" {
+		n.ID = note.ID
+	}
+	if err := ai.notifier.Notify(&n); err != nil {
+		*message += " - database write error"
+		return err
+	}
+	*message += fmt.Sprintf(" - %s notification", kind)
+	return nil
+}
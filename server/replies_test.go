@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+const testReply = `{
+	"@context": "https://www.w3.org/ns/activitystreams",
+	"type": "Create",
+	"id": "https://remote/notes/1/activity",
+	"actor": "https://remote/users/alice",
+	"to": ["https://www.w3.org/ns/activitystreams#Public"],
+	"object": {
+		"type": "Note",
+		"id": "https://remote/notes/1",
+		"attributedTo": "https://remote/users/alice",
+		"inReplyTo": "https://blog/post",
+		"content": "<p>Nice post</p>",
+		"published": "2023-01-02T03:04:05Z",
+		"url": "https://remote/@alice/1"
+	}
+}`
+
+func TestInbox_Create_Reply(t *testing.T) {
+	notes := &mockNotes{}
+	notes.On("FindNote", "https://blog/post").Return(&storage.Note{ID: "https://blog/post"}, nil).Once()
+	replies := &mockReplies{}
+	replies.On("FindReply", "https://remote/notes/1").Return(nil, nil).Once()
+	replies.On("SaveReply", mock.MatchedBy(func(r *storage.Reply) bool {
+		return r.ID == "https://remote/notes/1" && r.InReplyTo == "https://blog/post" &&
+			r.ActorID == "https://remote/users/alice" && r.Content == "<p>Nice post</p>" &&
+			r.Published.Year() == 2023 && r.Source != ""
+	})).Return(nil).Once()
+	processed := &mockProcessed{}
+	processed.On("ClaimProcessed", "https://remote/notes/1/activity").Return(true, nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		notes:          notes,
+		replies:        replies,
+		processed:      processed,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{Body: testReply}))
+
+	// a redelivery shouldn't store the reply again
+	processed.On("ClaimProcessed", "https://remote/notes/1/activity").Return(false, nil).Once()
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{Body: testReply}))
+
+	notes.AssertExpectations(t)
+	replies.AssertExpectations(t)
+	processed.AssertExpectations(t)
+}
+
+func TestInbox_Create_Rejected(t *testing.T) {
+	notes := &mockNotes{}
+	notes.On("FindNote", "https://blog/other").Return(nil, nil)
+	inbox := ActivityInbox{
+		id:             "test",
+		notes:          notes,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	create := func(attributedTo string, to string, inReplyTo string) []byte {
+		return []byte(fmt.Sprintf(`{"type":"Create","id":"x","actor":"https://remote/users/alice","to":[%q],
+			"object":{"type":"Note","id":"https://remote/notes/n","attributedTo":%q,"inReplyTo":%q}}`, to, attributedTo, inReplyTo))
+	}
+
+	var act activity.Activity
+	for _, body := range [][]byte{
+		create("https://remote/users/mallory", activity.PublicAddress, "https://blog/post"),               // wrong author
+		create("https://remote/users/alice", "https://remote/users/alice/followers", "https://blog/post"), // not public
+		create("https://remote/users/alice", activity.PublicAddress, ""),                                  // not a reply
+		create("https://remote/users/alice", activity.PublicAddress, "https://blog/other"),                // not our note
+		[]byte(`{"type":"Create","id":"x","actor":"https://remote/users/alice","to":["https://www.w3.org/ns/activitystreams#Public"],
+			"object":{"type":"Note","id":"https://other/notes/n","attributedTo":"https://remote/users/alice","inReplyTo":"https://blog/post"}}`), // note on another host
+	} {
+		require.NoError(t, json.Unmarshal(body, &act))
+		err := inbox.Create(act, body)
+		assert.ErrorAs(t, err, &rejectedError{}, string(body))
+	}
+}
+
+func TestInbox_Create_OtherAuthor(t *testing.T) {
+	notes := &mockNotes{}
+	notes.On("FindNote", "https://blog/post").Return(&storage.Note{ID: "https://blog/post"}, nil).Once()
+	replies := &mockReplies{}
+	replies.On("FindReply", "https://remote/notes/1").Return(&storage.Reply{
+		ID:      "https://remote/notes/1",
+		ActorID: "https://remote/users/alice",
+		Content: "<p>Nice post</p>",
+		State:   storage.ReplyVisible,
+	}, nil).Once()
+
+	inbox := ActivityInbox{
+		id:             "test",
+		notes:          notes,
+		replies:        replies,
+		pipeline:       NewPipeline(),
+		acceptUnsigned: true,
+	}
+
+	// mallory replays alice's note id with their own content
+	body := []byte(strings.ReplaceAll(testReply, "users/alice", "users/mallory"))
+	var act activity.Activity
+	require.NoError(t, json.Unmarshal(body, &act))
+	err := inbox.Create(act, body)
+	assert.ErrorAs(t, err, &rejectedError{})
+
+	replies.AssertExpectations(t)
+	replies.AssertNotCalled(t, "SaveReply", mock.Anything)
+}
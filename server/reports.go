@@ -0,0 +1,351 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// Actions that can be taken on a report
+const (
+	ReportDismiss     = "dismiss"      // nothing to do
+	ReportHide        = "hide"         // hide the reported replies
+	ReportBlockActor  = "block-actor"  // block the reported actors
+	ReportBlockDomain = "block-domain" // block the servers of the reported actors
+)
+
+// Flag handles a report from a remote moderator about our content
+// or about someone who replied to us.
+func (ai *ActivityInbox) Flag(act activity.Activity, body []byte) error {
+	telemetry.Increment("flag_requests", 1)
+
+	actorID := parseID(act.Actor)
+
+	var message = fmt.Sprintf("POST flag [%s] by [%s] at inbox [%s]", act.ID, actorID, ai.id)
+	defer func() {
+		telemetry.Log(message)
+	}()
+
+	if act.ID == "" {
+		message += " - rejected, no id"
+		return reject("flag has no id")
+	}
+	objects := parseIDs(act.Object)
+	if len(objects) == 0 {
+		message += " - rejected, nothing reported"
+		return reject("flag has no objects")
+	}
+	if ai.reports == nil {
+		message += " - failed, no database"
+		return errors.New("no report storage")
+	}
+
+	var flag struct {
+		Content string `json:"content"`
+	}
+	json.Unmarshal(body, &flag)
+	objectsJSON, _ := json.Marshal(objects)
+
+	report := storage.Report{
+		ID:         act.ID,
+		User:       ai.ownerID,
+		ActorID:    actorID,
+		Content:    flag.Content,
+		Objects:    string(objectsJSON),
+		Status:     storage.ReportOpen,
+		ReceivedAt: time.Now().UTC(),
+		Source:     string(body),
+	}
+	if err := ai.reports.SaveReport(&report); err != nil {
+		message += " - database write error"
+		return fmt.Errorf("saving report: %w", err)
+	}
+
+	telemetry.Increment("reports_received", 1)
+	ai.notify(storage.Notification{
+		ID:       act.ID,
+		Type:     storage.NotifyReport,
+		ActorID:  actorID,
+		ObjectID: objects[0],
+		Content:  sanitizeHTML(flag.Content, nil),
+	})
+	message += " - success"
+	return nil
+}
+
+// ReportQueueItem is a report with the stored replies it refers to
+type ReportQueueItem struct {
+	storage.Report
+	ObjectIDs []string        `json:"objectIds"`
+	Replies   []storage.Reply `json:"replies"` // reported replies we have stored
+	Actors    []string        `json:"actors"`  // reported remote actors, including authors of the replies
+}
+
+// Reports returns the report queue, filtered by status if any are given
+func (s *ActivityService) Reports(statuses ...string) ([]ReportQueueItem, error) {
+	if s.reports == nil {
+		return nil, errors.New("no database")
+	}
+	reports, err := s.reports.GetReports(statuses)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]ReportQueueItem, 0, len(reports))
+	for _, report := range reports {
+		item, err := s.reportQueueItem(report)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Report returns one report from the queue, or nil if there isn't one with the ID
+func (s *ActivityService) Report(id string) (*ReportQueueItem, error) {
+	if s.reports == nil {
+		return nil, errors.New("no database")
+	}
+	report, err := s.reports.FindReport(id)
+	if err != nil || report == nil {
+		return nil, err
+	}
+	item, err := s.reportQueueItem(*report)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// reportQueueItem looks up what a report refers to
+func (s *ActivityService) reportQueueItem(report storage.Report) (ReportQueueItem, error) {
+	item := ReportQueueItem{Report: report}
+	json.Unmarshal([]byte(report.Objects), &item.ObjectIDs)
+
+	actors := make(map[string]bool)
+	for _, id := range item.ObjectIDs {
+		reply, err := s.findReply(id)
+		if err != nil {
+			return item, err
+		}
+		if reply != nil {
+			item.Replies = append(item.Replies, *reply)
+			actors[reply.ActorID] = true
+		} else if s.knowsActor(id) {
+			actors[id] = true
+		}
+	}
+	for id := range actors {
+		item.Actors = append(item.Actors, id)
+	}
+	return item, nil
+}
+
+// findReply looks for a reply stored by any user
+func (s *ActivityService) findReply(id string) (*storage.Reply, error) {
+	users := s.currentUsers()
+	for i := range users {
+		reply, err := users[i].inbox.replies.FindReply(id)
+		if err != nil || reply != nil {
+			return reply, err
+		}
+	}
+	return nil, nil
+}
+
+// ResolveReport takes one of the report actions and closes the report
+func (s *ActivityService) ResolveReport(id string, action string) error {
+	item, err := s.Report(id)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return fmt.Errorf("no report %s", id)
+	}
+
+	switch action {
+	case ReportDismiss:
+		item.Status = storage.ReportDismissed
+	case ReportHide:
+		for _, reply := range item.Replies {
+			if err := s.setReplyState(reply.ID, storage.ReplyHidden); err != nil {
+				return err
+			}
+		}
+		item.Status = storage.ReportResolved
+	case ReportBlockActor, ReportBlockDomain:
+		if len(item.Actors) == 0 {
+			return fmt.Errorf("report %s doesn't refer to any known actors", id)
+		}
+		for _, actorID := range item.Actors {
+			block := storage.Block{ID: actorID, Type: storage.BlockActor, Reason: "report " + id}
+			if action == ReportBlockDomain {
+				u, err := url.Parse(actorID)
+				if err != nil {
+					return err
+				}
+				block.ID, block.Type = strings.ToLower(u.Hostname()), storage.BlockDomain
+			}
+			if err := s.block(block); err != nil {
+				return err
+			}
+		}
+		item.Status = storage.ReportResolved
+	default:
+		return fmt.Errorf("unknown report action %s", action)
+	}
+
+	item.Action = action
+	item.ResolvedAt = time.Now().UTC()
+	return s.reports.SaveReport(&item.Report)
+}
+
+// setReplyState changes the moderation state of a reply stored by any user
+func (s *ActivityService) setReplyState(id string, state string) error {
+	users := s.currentUsers()
+	for i := range users {
+		replies := users[i].inbox.replies
+		reply, err := replies.FindReply(id)
+		if err != nil {
+			return err
+		}
+		if reply != nil {
+			reply.State = state
+			if err := replies.SaveReply(reply); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ForwardReport sends a copy of a report to the servers of the reported actors,
+// so their moderators know about it too. Returns the number of servers notified.
+func (s *ActivityService) ForwardReport(ctx context.Context, id string) (int, error) {
+	item, err := s.Report(id)
+	if err != nil {
+		return 0, err
+	}
+	if item == nil {
+		return 0, fmt.Errorf("no report %s", id)
+	}
+	var user *ActivityUser
+	users := s.currentUsers()
+	for i := range users {
+		if users[i].meta.UserID == item.User {
+			user = &users[i]
+		}
+	}
+	if user == nil {
+		return 0, fmt.Errorf("report %s was received by unknown user %s", id, item.User)
+	}
+
+	// One report per server, naming the actors and replies from that server
+	flags := make(map[string]*FlagActivity)
+	for _, actorID := range item.Actors {
+		u, err := url.Parse(actorID)
+		if err != nil {
+			continue
+		}
+		flag, ok := flags[u.Host]
+		if !ok {
+			flag = &FlagActivity{
+				outbox:   &user.outbox,
+				localID:  user.meta.UserID,
+				remoteID: actorID,
+				content:  item.Content,
+			}
+			flags[u.Host] = flag
+		}
+		flag.objects = append(flag.objects, actorID)
+		for _, reply := range item.Replies {
+			if reply.ActorID == actorID {
+				flag.objects = append(flag.objects, reply.ID)
+			}
+		}
+	}
+
+	s.SendNow(ctx, func() {
+		for _, flag := range flags {
+			s.pipeline.Queue(flag)
+		}
+	})
+	return len(flags), nil
+}
+
+// FlagActivity forwards a report to a remote server
+type FlagActivity struct {
+	outbox   *ActivityOutbox
+	localID  string
+	remoteID string // a reported actor, whose server gets the report
+	objects  []string
+	content  string
+}
+
+func (f *FlagActivity) String() string {
+	return fmt.Sprintf("Flag to %s", f.remoteID)
+}
+
+func (f *FlagActivity) Prepare(ctx context.Context, pipeline *OutputPipeline) (*http.Request, error) {
+	remote, err := f.outbox.service.GetActor(ctx, f.remoteID)
+	if err != nil {
+		return nil, fmt.Errorf("looking up remote actor: %w", err)
+	}
+	inbox := remote.Endpoints.SharedInbox
+	if inbox == "" {
+		inbox = remote.Inbox
+	}
+
+	flagObject := struct {
+		Context string   `json:"@context"`
+		Type    string   `json:"type"`
+		ID      string   `json:"id"`
+		Actor   string   `json:"actor"`
+		Object  []string `json:"object"`
+		Content string   `json:"content"`
+	}{
+		Context: activity.Context,
+		Type:    activity.FlagType,
+		ID:      uuid.NewString(),
+		Actor:   f.localID,
+		Object:  f.objects,
+		Content: f.content,
+	}
+
+	r, err := f.outbox.service.ActivityRequest(http.MethodPost, inbox, &flagObject)
+	if err != nil {
+		return nil, fmt.Errorf("creating flag request: %w", err)
+	}
+
+	if f.outbox.privKey != nil && !f.outbox.sendUnsigned {
+		sign(f.outbox.privKey, f.outbox.pubKeyID, r)
+	}
+
+	telemetry.Increment("flags_sent", 1)
+	return r, nil
+}
+
+func (f *FlagActivity) Receive(resp *http.Response) {
+	telemetry.Trace("received response from flag %d", resp.StatusCode)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		telemetry.Increment("flags_succeeded", 1)
+	} else {
+		telemetry.Increment("flags_failed", 1)
+		f.outbox.deliveryFailed(activity.FlagType, f.remoteID, f.remoteID, fmt.Sprintf("status %d", resp.StatusCode))
+	}
+}
+
+func (f *FlagActivity) Fail(err error) {
+	telemetry.Increment("flags_failed", 1)
+	f.outbox.deliveryFailed(activity.FlagType, f.remoteID, f.remoteID, err.Error())
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+// moderatedService creates a service with real storage for one user
+func moderatedService(t *testing.T) *ActivityService {
+	dir := t.TempDir()
+	store := storage.NewDatabase(filepath.Join(dir, "service.db"))
+	require.NoError(t, store.Open())
+	t.Cleanup(store.Close)
+	userStore := storage.NewDatabase(filepath.Join(dir, "user.db"))
+	require.NoError(t, userStore.Open())
+	t.Cleanup(userStore.Close)
+
+	svc := &ActivityService{
+		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		pipeline:   NewPipeline(),
+		store:      store,
+		actors:     store.(storage.Actors),
+		reports:    store.(storage.Reports),
+		blocks:     store.(storage.Blocks),
+	}
+	svc.users = []ActivityUser{{
+		name:  "test",
+		store: userStore,
+		inbox: ActivityInbox{
+			service:        svc,
+			id:             "test",
+			ownerID:        "https://local/activity/test",
+			followers:      userStore.(storage.Followers),
+			notes:          userStore.(storage.Notes),
+			replies:        userStore.(storage.Replies),
+			reactions:      userStore.(storage.Reactions),
+			reports:        svc.reports,
+			items:          userStore.(storage.InboxItems),
+			queue:          NewInboxQueue(),
+			pipeline:       svc.pipeline,
+			acceptUnsigned: true,
+		},
+	}}
+	svc.users[0].meta.UserID = "https://local/activity/test"
+	return svc
+}
+
+func TestReports_FlagAndHide(t *testing.T) {
+	svc := moderatedService(t)
+	inbox := &svc.users[0].inbox
+	require.NoError(t, inbox.replies.SaveReply(&storage.Reply{
+		ID: "https://remote/notes/1", InReplyTo: "https://blog/post", ActorID: "https://remote/users/spammer", Published: time.Now(),
+	}))
+
+	flag := `{"type":"Flag","id":"https://mod.example/flags/1","actor":"https://mod.example/actor","content":"spam",
+		"object":["https://remote/users/spammer","https://remote/notes/1"]}`
+	require.NoError(t, inbox.Process(context.Background(), &storage.InboxItem{Body: flag}))
+
+	reports, err := svc.Reports(storage.ReportOpen)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "spam", reports[0].Content)
+	assert.Equal(t, []string{"https://remote/users/spammer", "https://remote/notes/1"}, reports[0].ObjectIDs)
+	require.Len(t, reports[0].Replies, 1)
+	assert.Equal(t, []string{"https://remote/users/spammer"}, reports[0].Actors)
+
+	require.NoError(t, svc.ResolveReport("https://mod.example/flags/1", ReportHide))
+	replies, err := inbox.replies.GetReplies("https://blog/post", []string{storage.ReplyVisible})
+	require.NoError(t, err)
+	assert.Empty(t, replies)
+
+	reports, err = svc.Reports(storage.ReportOpen)
+	require.NoError(t, err)
+	assert.Empty(t, reports)
+
+	assert.Error(t, svc.ResolveReport("https://mod.example/flags/1", "explode"))
+}
+
+func TestReports_BlockDomain(t *testing.T) {
+	svc := moderatedService(t)
+	inbox := &svc.users[0].inbox
+	require.NoError(t, inbox.followers.SaveFollow(storage.Follow{ID: "https://bad.example/users/a"}))
+	require.NoError(t, inbox.followers.SaveFollow(storage.Follow{ID: "https://good.example/users/b"}))
+	require.NoError(t, inbox.replies.SaveReply(&storage.Reply{
+		ID: "https://bad.example/notes/1", InReplyTo: "https://blog/post", ActorID: "https://bad.example/users/c", Published: time.Now(),
+	}))
+	require.NoError(t, inbox.replies.SaveReply(&storage.Reply{
+		ID: "https://good.example/notes/2", InReplyTo: "https://blog/post", ActorID: "https://good.example/users/b", Published: time.Now(),
+	}))
+	require.NoError(t, inbox.replies.SaveReply(&storage.Reply{
+		ID: "https://bad.example:8443/notes/3", InReplyTo: "https://blog/post", ActorID: "https://bad.example:8443/users/d", Published: time.Now(),
+	}))
+
+	// wildcards in a blocked domain only match themselves
+	require.NoError(t, svc.Block(context.Background(), "g__d.example", "spam"))
+	replies, err := inbox.replies.GetReplies("https://blog/post", []string{storage.ReplyVisible})
+	require.NoError(t, err)
+	assert.Len(t, replies, 3)
+
+	require.NoError(t, svc.Block(context.Background(), "Bad.Example", "spam"))
+	assert.True(t, svc.isBlocked("https://bad.example/users/z"))
+	assert.False(t, svc.isBlocked("https://good.example/users/b"))
+
+	followers, err := inbox.followers.GetFollowers()
+	require.NoError(t, err)
+	require.Len(t, followers, 1)
+	assert.Equal(t, "https://good.example/users/b", followers[0].ID)
+	replies, err = inbox.replies.GetReplies("https://blog/post", []string{storage.ReplyVisible})
+	require.NoError(t, err)
+	require.Len(t, replies, 1)
+	assert.Equal(t, "https://good.example/notes/2", replies[0].ID)
+
+	// anything else they send is dropped without being stored
+	body := `{"type":"Follow","id":"f","actor":"https://bad.example/users/a","object":"https://local/activity/test"}`
+	r := httptest.NewRequest("POST", "/activity/test/inbox", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	inbox.PostHTTP(recorder, r)
+	assert.Equal(t, http.StatusAccepted, recorder.Result().StatusCode)
+	assert.Len(t, inbox.queue.jobs, 0)
+	items, err := inbox.items.FindInboxItems(nil, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	require.NoError(t, svc.Unblock(context.Background(), "bad.example"))
+	assert.False(t, svc.isBlocked("https://bad.example/users/z"))
+}
+
+func TestBlock_Handle(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/jrd+json")
+		fmt.Fprintf(w, `{"subject":%q,"links":[{"rel":"self","type":"application/activity+json","href":"https://evil.example/users/spam"}]}`,
+			r.URL.Query().Get("resource"))
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+
+	svc := moderatedService(t)
+	svc.finger = NewWebFinger(testFetcher())
+	svc.finger.scheme = "http"
+	inbox := &svc.users[0].inbox
+	require.NoError(t, inbox.followers.SaveFollow(storage.Follow{ID: "https://evil.example/users/spam"}))
+
+	require.NoError(t, svc.RemoveFollower(context.Background(), "test", "acct:spam@"+u.Host))
+	followers, err := inbox.followers.GetFollowers()
+	require.NoError(t, err)
+	assert.Empty(t, followers)
+
+	handle := "@spam@" + u.Host
+	require.NoError(t, svc.Block(context.Background(), handle, "spam"))
+	blocks, err := svc.Blocks()
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "https://evil.example/users/spam", blocks[0].ID)
+	assert.Equal(t, storage.BlockActor, blocks[0].Type)
+	require.NoError(t, svc.Unblock(context.Background(), handle))
+	blocks, err = svc.Blocks()
+	require.NoError(t, err)
+	assert.Empty(t, blocks)
+
+	for _, target := range []string{"spam", "@spam", "evil example", "ftp://evil.example/x"} {
+		err := svc.Block(context.Background(), target, "")
+		assert.ErrorIs(t, err, errBadTarget, target)
+	}
+	blocks, err = svc.Blocks()
+	require.NoError(t, err)
+	assert.Empty(t, blocks)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// ReprocessFilter selects stored inbox activities to be processed again
+type ReprocessFilter struct {
+	User     string    // only this user's inbox, or every inbox if empty
+	ID       string    // a single stored item, ignoring the other criteria
+	Statuses []string  // only items with one of these statuses, defaults to rejected and failed
+	Since    time.Time // only items received since this time
+}
+
+// Reprocess runs stored inbox activities through processing again,
+// e.g. after fixing a bug in a handler. Activities that were already processed
+// successfully are still skipped as duplicates. Meant to be run while the service
+// isn't listening; responses are sent before returning.
+// Returns the number of activities that succeeded and failed.
+func (s *ActivityService) Reprocess(ctx context.Context, filter ReprocessFilter) (succeeded int, failed int, err error) {
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = []string{storage.InboxRejected, storage.InboxFailed}
+	}
+
+	found := false
+	s.SendNow(ctx, func() {
+		users := s.currentUsers()
+		for i := range users {
+			user := &users[i]
+			if filter.User != "" && filter.User != user.name {
+				continue
+			}
+			found = true
+
+			var items []storage.InboxItem
+			if filter.ID != "" {
+				var item *storage.InboxItem
+				item, err = user.inbox.items.FindInboxItem(filter.ID)
+				if err != nil {
+					return
+				}
+				if item != nil {
+					items = append(items, *item)
+				}
+			} else {
+				items, err = user.inbox.items.FindInboxItems(filter.Statuses, filter.Since)
+				if err != nil {
+					return
+				}
+			}
+
+			for _, item := range items {
+				telemetry.Log("reprocessing %s %s received %s", item.Type, item.ActivityID, item.ReceivedAt.Format(time.RFC3339))
+				if err := s.inboxQueue.process(ctx, inboxJob{inbox: &user.inbox, item: item}); err != nil {
+					failed++
+				} else {
+					succeeded++
+				}
+			}
+		}
+	})
+	if err != nil {
+		return succeeded, failed, err
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("no user named %s", filter.User)
+	}
+	return succeeded, failed, nil
+}
@@ -32,6 +32,11 @@ type ItemHandler interface {
 	NewItem(item Item)   // a new feed item is discovered
 }
 
+// ErrorHandler is optionally implemented by an ItemHandler to hear about failed checks
+type ErrorHandler interface {
+	CheckFailed(err error)
+}
+
 // FeedWatcher implements a small service to watch an RSS feed and discover new activity
 type FeedWatcher struct {
 	URL     string
@@ -42,6 +47,7 @@ type FeedWatcher struct {
 	etag         string
 	lastModified string
 	known        map[string]time.Time // known guids to track new and updated items
+	checkNow     chan struct{}        // asks Watch for an immediate check
 }
 
 type ItemParser interface {
@@ -158,26 +164,43 @@ func (c *FeedWatcher) parseItems(body io.Reader) ([]Item, error) {
 func (c *FeedWatcher) Watch(ctx context.Context, period time.Duration) {
 	sigChannel := make(chan os.Signal, 1)
 	signal.Notify(sigChannel, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigChannel)
 	ticker := time.NewTicker(period)
 	defer ticker.Stop()
-	c.Check(ctx)
+	c.checkAndReport(ctx)
 	for {
 		select {
 		case <-ctx.Done():
-			// Parent context cancelled somehow
-			telemetry.Error(ctx.Err(), "watcher context ended")
+			// Parent context cancelled, by shutdown or a config reload
+			telemetry.Trace("watcher context ended")
 			return
 		case <-sigChannel:
 			// CTRL-C
 			telemetry.Trace("watcher received end signal")
 			return
 		case <-ticker.C:
-			err := c.Check(ctx)
-			if err != nil {
-				// We just ignore the error for now
-				// TODO: Should be smarter
-				telemetry.Error(err, "checking feed [%s]", c.URL)
-			}
+			c.checkAndReport(ctx)
+		case <-c.checkNow:
+			c.checkAndReport(ctx)
+		}
+	}
+}
+
+// CheckNow asks a running Watch to check the feed without waiting for the next period
+func (c *FeedWatcher) CheckNow() {
+	select {
+	case c.checkNow <- struct{}{}:
+	default:
+		// a check is already waiting
+	}
+}
+
+func (c *FeedWatcher) checkAndReport(ctx context.Context) {
+	if err := c.Check(ctx); err != nil {
+		// TODO: Should be smarter, maybe back off
+		telemetry.Error(err, "checking feed [%s]", c.URL)
+		if h, ok := c.Handler.(ErrorHandler); ok {
+			h.CheckFailed(err)
 		}
 	}
 }
@@ -189,6 +212,7 @@ func NewFeedWatcher(url string, handler ItemHandler) FeedWatcher {
 		itemParser: gofeedParser{
 			parser: gofeed.NewParser(),
 		},
-		known: make(map[string]time.Time),
+		known:    make(map[string]time.Time),
+		checkNow: make(chan struct{}, 1),
 	}
 }
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"golang.org/x/net/html"
+)
+
+// Elements kept in sanitized html. Anything else is removed, but its text is kept.
+var allowedElements = map[string]bool{
+	"p": true, "br": true, "a": true, "span": true,
+	"strong": true, "b": true, "em": true, "i": true, "u": true, "s": true, "del": true,
+	"code": true, "pre": true, "blockquote": true, "ul": true, "ol": true, "li": true,
+}
+
+// Elements removed along with everything inside them
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "noscript": true, "template": true, "svg": true, "math": true,
+	"head": true, "title": true, "textarea": true, "select": true, "audio": true, "video": true, "canvas": true,
+}
+
+// Classes used by Mastodon and friends for mentions, hashtags and shortened links
+var allowedClasses = map[string]bool{
+	"mention": true, "hashtag": true, "u-url": true, "h-card": true, "invisible": true, "ellipsis": true,
+}
+
+var (
+	shortcodePattern = regexp.MustCompile(`^:[A-Za-z0-9_]+:$`)
+	shortcodesInText = regexp.MustCompile(`:[A-Za-z0-9_]+:`)
+)
+
+// sanitizeHTML reduces remote html to a small set of harmless formatting elements.
+// Links are marked as user-generated, and custom emoji shortcodes are replaced by images.
+// Emoji images from an earlier pass are kept, so sanitized html can be sanitized again.
+func sanitizeHTML(content string, emojis map[string]string) string {
+	var out strings.Builder
+	var open []string // allowed elements that haven't been closed
+	skip := 0         // depth inside dropped elements
+
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				fmt.Fprintf(&out, "</%s>", open[i])
+			}
+			return out.String()
+
+		case html.TextToken:
+			if skip == 0 {
+				out.WriteString(replaceEmojis(html.EscapeString(string(tokenizer.Text())), emojis))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			name := strings.ToLower(token.Data)
+			if droppedElements[name] {
+				if tokenType == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if name == "img" {
+				out.WriteString(sanitizeEmoji(token.Attr))
+				continue
+			}
+			if !allowedElements[name] {
+				continue
+			}
+			out.WriteString("<" + name + sanitizeAttributes(name, token.Attr) + ">")
+			if tokenType == html.StartTagToken && name != "br" {
+				open = append(open, name)
+			}
+
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			name := strings.ToLower(token.Data)
+			if droppedElements[name] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 || !allowedElements[name] {
+				continue
+			}
+			// Close anything left open inside it, and ignore stray end tags
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == name {
+					for j := len(open) - 1; j >= i; j-- {
+						fmt.Fprintf(&out, "</%s>", open[j])
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+}
+
+// sanitizeReply stores the sanitized text of a note in a reply
+func sanitizeReply(reply *storage.Reply, note activity.Note) {
+	emojis := parseEmojis(note.Tag)
+	reply.Content = sanitizeHTML(note.Content, emojis)
+	reply.Summary = sanitizeHTML(note.Summary, emojis)
+	reply.Name = sanitizeHTML(note.Name, emojis)
+}
+
+// sanitizeAttributes returns the safe attributes of an allowed element, ready to write in its tag
+func sanitizeAttributes(name string, attrs []html.Attribute) string {
+	var out strings.Builder
+	for _, attr := range attrs {
+		switch {
+		case attr.Key == "href" && name == "a":
+			if u, err := url.Parse(attr.Val); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				fmt.Fprintf(&out, ` href="%s"`, html.EscapeString(attr.Val))
+			}
+		case attr.Key == "class" && (name == "a" || name == "span"):
+			var classes []string
+			for _, class := range strings.Fields(attr.Val) {
+				if allowedClasses[class] {
+					classes = append(classes, class)
+				}
+			}
+			if len(classes) > 0 {
+				fmt.Fprintf(&out, ` class="%s"`, strings.Join(classes, " "))
+			}
+		}
+	}
+	if name == "a" {
+		out.WriteString(` rel="nofollow ugc noopener"`)
+	}
+	return out.String()
+}
+
+// replaceEmojis replaces custom emoji shortcodes in escaped text with images.
+// Done in one pass so shortcodes inside an inserted image aren't replaced again.
+func replaceEmojis(text string, emojis map[string]string) string {
+	if len(emojis) == 0 {
+		return text
+	}
+	return shortcodesInText.ReplaceAllStringFunc(text, func(shortcode string) string {
+		if icon, ok := emojis[shortcode]; ok {
+			return emojiImage(icon, shortcode)
+		}
+		return shortcode
+	})
+}
+
+// sanitizeEmoji returns an img tag if it's a custom emoji with an http(s) image, or nothing
+func sanitizeEmoji(attrs []html.Attribute) string {
+	var src, alt string
+	emoji := false
+	for _, attr := range attrs {
+		switch attr.Key {
+		case "src":
+			src = attr.Val
+		case "alt":
+			alt = attr.Val
+		case "class":
+			for _, class := range strings.Fields(attr.Val) {
+				emoji = emoji || class == "emoji"
+			}
+		}
+	}
+	u, err := url.Parse(src)
+	if !emoji || err != nil || (u.Scheme != "http" && u.Scheme != "https") || !shortcodePattern.MatchString(alt) {
+		return ""
+	}
+	return emojiImage(src, alt)
+}
+
+// emojiImage is the img tag for a custom emoji
+func emojiImage(icon string, shortcode string) string {
+	return fmt.Sprintf(`<img src="%s" alt="%s" title="%s" class="emoji" width="20" height="20">`,
+		html.EscapeString(icon), shortcode, shortcode)
+}
+
+// parseEmojis finds the custom emoji in a note's tags, returning image URLs by shortcode
+func parseEmojis(tags interface{}) map[string]string {
+	emojis := make(map[string]string)
+	list, ok := tags.([]interface{})
+	if !ok {
+		list = []interface{}{tags}
+	}
+	for _, item := range list {
+		tag, ok := item.(map[string]interface{})
+		if !ok || tag[activity.TypeProperty] != activity.EmojiType {
+			continue
+		}
+		name, _ := tag["name"].(string)
+		if !strings.HasPrefix(name, ":") {
+			name = ":" + name + ":"
+		}
+		icon, _ := tag["icon"].(map[string]interface{})
+		iconURL := parseURL(icon["url"])
+		if u, err := url.Parse(iconURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if shortcodePattern.MatchString(name) {
+			emojis[name] = iconURL
+		}
+	}
+	return emojis
+}
+
+// parseURL returns a url property that may be a string or a Link object
+func parseURL(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case map[string]interface{}:
+		href, _ := t["href"].(string)
+		return href
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := map[string]string{
+		// Mastodon mention
+		`<p><span class="h-card"><a href="https://remote/@alice" class="u-url mention" target="_blank">@<span>alice</span></a></span> hello</p>`: `<p><span class="h-card"><a href="https://remote/@alice" class="u-url mention" rel="nofollow ugc noopener">@<span>alice</span></a></span> hello</p>`,
+		// scripts, styles and tracking pixels
+		`<p>hi<script>alert("x")</script><style>p{}</style><img src="https://tracker/pixel.gif"></p>`: `<p>hi</p>`,
+		// unknown elements are unwrapped, attributes dropped
+		`<div onclick="x()"><p style="color:red">text</p></div>`: `<p>text</p>`,
+		// unsafe links lose their href
+		`<a href="javascript:alert(1)">click</a>`: `<a rel="nofollow ugc noopener">click</a>`,
+		// escaping and unbalanced tags
+		`<p>1 &lt; 2 <b>bold`: `<p>1 &lt; 2 <b>bold</b></p>`,
+		`text</p></b>`:        `text`,
+		`<p>a<br/>b</p>`:      `<p>a<br>b</p>`,
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, sanitizeHTML(in, nil), in)
+	}
+}
+
+func TestSanitizeReply_Emoji(t *testing.T) {
+	var note activity.Note
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "Note",
+		"name": "<b>:blobcat:</b>",
+		"summary": "cw :blobcat:",
+		"content": "<p>hello :blobcat: :unknown: <a href=\"https://x/:blobcat:\">link</a></p>",
+		"tag": [
+			{"type": "Mention", "name": "@bob@example.com"},
+			{"type": "Emoji", "name": ":blobcat:", "icon": {"type": "Image", "url": "https://remote/emoji/blobcat.png"}},
+			{"type": "Emoji", "name": ":evil:", "icon": {"url": "javascript:alert(1)"}}
+		]
+	}`), &note))
+
+	var reply storage.Reply
+	sanitizeReply(&reply, note)
+
+	img := `<img src="https://remote/emoji/blobcat.png" alt=":blobcat:" title=":blobcat:" class="emoji" width="20" height="20">`
+	assert.Equal(t, `<p>hello `+img+` :unknown: <a href="https://x/:blobcat:" rel="nofollow ugc noopener">link</a></p>`, reply.Content)
+	assert.Equal(t, "cw "+img, reply.Summary)
+	assert.Equal(t, "<b>"+img+"</b>", reply.Name)
+}
+
+func TestSanitizeHTML_Emoji(t *testing.T) {
+	// an icon URL containing another shortcode isn't replaced again
+	emojis := map[string]string{
+		":a:": "https://x/:b:.png",
+		":b:": "https://x/b.png",
+	}
+	a := `<img src="https://x/:b:.png" alt=":a:" title=":a:" class="emoji" width="20" height="20">`
+	b := `<img src="https://x/b.png" alt=":b:" title=":b:" class="emoji" width="20" height="20">`
+	content := sanitizeHTML("<p>:a: and :b:</p>", emojis)
+	assert.Equal(t, "<p>"+a+" and "+b+"</p>", content)
+
+	// sanitizing again keeps the emoji, but no other images
+	assert.Equal(t, content, sanitizeHTML(content, nil))
+	assert.Equal(t, "<p></p>", sanitizeHTML(`<p><img src="javascript:alert(1)" alt=":a:" class="emoji"></p>`, nil))
+	assert.Equal(t, "<p></p>", sanitizeHTML(`<p><img src="https://x/a.png" alt="x" onerror="alert(1)" class="emoji"></p>`, nil))
+}
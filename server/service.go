@@ -8,11 +8,13 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"html/template"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -23,15 +25,65 @@ import (
 	"github.com/tkrehbiel/activitylace/server/telemetry"
 )
 
+const userAgent = "Activitylace/0.1 (+https://github.com/tkrehbiel/activitylace)"
+
+// serviceDatabase is the sqlite database for data that isn't specific to one user
+const serviceDatabase = "activitylace.db"
+
+// userDatabase returns the name of the sqlite database for a user
+func userDatabase(name string) string {
+	return fmt.Sprintf("user_%s.db", name)
+}
+
+// Migrate creates or updates the tables of every database the configuration uses
+func Migrate(cfg Config) ([]string, error) {
+	names := []string{serviceDatabase}
+	for _, u := range cfg.Users {
+		names = append(names, userDatabase(u.Name))
+	}
+	for _, name := range names {
+		store := storage.NewDatabase(name)
+		if err := store.Open(); err != nil {
+			return nil, fmt.Errorf("opening sqlite database [%s]: %w", name, err)
+		}
+		store.Close()
+	}
+	return names, nil
+}
+
 type ActivityService struct {
 	config     Config
-	server     http.Server     // for serving http responses
-	router     *mux.Router     // for request routing
-	pipeline   *OutputPipeline // on which output messages are queued
-	client     http.Client     // http client for outgoing requests
-	meta       page.MetaData   // metadata for page templates
-	users      []ActivityUser  // ActivityPub user accounts handled
+	server     http.Server                // for serving http responses
+	router     *mux.Router                // for request routing, while it's being built
+	routes     atomic.Pointer[mux.Router] // router serving requests, replaced when config is reloaded
+	pipeline   *OutputPipeline            // on which output messages are queued
+	inboxQueue *InboxQueue                // on which received activities are processed
+	client     http.Client                // http client for outgoing requests
+	meta       page.MetaData              // metadata for page templates
+	users      []ActivityUser             // ActivityPub user accounts handled
 	actorCache *ccache.Cache[activity.Actor]
+	pages      *ccache.Cache[[]byte]  // dynamic pages, rendered at most once in a while
+	keys       KeyStore               // signing keys for users
+	store      storage.Database       // data storage shared by all users
+	actors     storage.Actors         // remote actors
+	reports    storage.Reports        // reports from remote moderators
+	blocks     storage.Blocks         // blocked actors and domains
+	notifier   *Notifier              // stores and delivers notifications to the owner
+	webhooks   *Webhooks              // posts events to configured webhooks
+	deliveries storage.Deliveries     // log of notes sent to followers
+	admin      *http.Server           // separate listener for the admin api, if configured
+	refreshing sync.Map               // actor IDs being refreshed in the background
+	fetcher    *Fetcher               // for fetching remote documents
+	finger     *WebFinger             // resolves acct: handles
+	dashboard  *Dashboard             // admin html pages
+	runCtx     context.Context        // context the service is running in, for watchers started by a reload
+	reloading  sync.Mutex             // held while config is reloaded
+	state      sync.RWMutex           // guards config and users, which a reload replaces
+	source     func() (Config, error) // reads the config again for reloading
+
+	templates       map[string]string  // template files overriding built-in ones, by name
+	profileTemplate *template.Template // renders profile pages
+	extraPages      []page.StaticPage  // configured static pages
 }
 
 type ActivityUser struct {
@@ -48,28 +100,35 @@ type ActivityUser struct {
 func (s *ActivityService) addHandlers() {
 	s.router.HandleFunc("/", homeHandler).Methods("GET")
 
-	s.addPageHandler(page.NewStaticPage(page.WellKnownHostMeta), s.meta)
-	s.addPageHandler(page.NewStaticPage(page.WellKnownNodeInfo), s.meta)
-	s.addPageHandler(page.NewStaticPage(page.NodeInfo), s.meta)
+	s.addPageHandler(page.NewStaticPage(s.override(page.WellKnownHostMeta)), s.meta)
+	s.addPageHandler(page.NewStaticPage(s.override(page.WellKnownNodeInfo)), s.meta)
+	for _, version := range page.NodeInfoVersions {
+		s.router.HandleFunc("/nodeinfo/"+version, s.nodeInfoHandler(version)).Methods("GET")
+	}
 
-	for _, u := range s.config.Users {
-		page.WellKnownWebFinger.Add(u.Name, s.meta)
+	finger := page.WellKnownWebFinger // copy
+	finger.Account = s.override(page.WebFingerAccount)
+	finger.Pages = nil
+	finger.Domains = append([]string{s.meta.Domain()}, s.config.Server.AliasDomains...)
+	for i := range s.users {
+		finger.Add(s.users[i].name, s.meta)
 	}
-	s.addPageHandler(&page.WellKnownWebFinger, s.meta)
+	s.addPageHandler(&finger, s.meta)
+	s.router.HandleFunc("/authorize_interaction", s.authorizeInteractionHandler).Methods("GET")
 
 	for i := range s.users {
-		// TODO: umeta.LatestNotes = s.outbox[i].GetLatestNotes(10)
 		user := &s.users[i]
 
-		pg := page.ActorEndpoint // copy
+		pg := s.override(page.ActorEndpoint) // copy
 		pg.Path = fmt.Sprintf("/%s/%s", page.SubPath, user.name)
 		s.addPageHandler(page.NewStaticPage(pg), user.meta)
 
-		// TODO: This should be a dynamic page since it should include latest activity
-		pg = page.ProfilePage // copy
-		pg.Path = fmt.Sprintf("/profile/%s", user.name)
+		pg = s.override(page.FeaturedTagsEndpoint) // copy
+		pg.Path = fmt.Sprintf("/%s/%s/tags", page.SubPath, user.name)
 		s.addPageHandler(page.NewStaticPage(pg), user.meta)
 
+		s.router.HandleFunc(fmt.Sprintf("/profile/%s", user.name), s.profileHandler(user)).Methods("GET")
+
 		outpath := fmt.Sprintf("/%s/%s/outbox", page.SubPath, user.name)
 		route := s.router.HandleFunc(outpath, user.outbox.ServeHTTP).Methods("GET") // TODO: filter by Accept
 		if !s.config.Server.AcceptAll {
@@ -86,9 +145,27 @@ func (s *ActivityService) addHandlers() {
 			route.HeadersRegexp("Content-Type", "application/.*json")
 		}
 
+		for _, name := range []string{"likes", "shares"} {
+			route = s.router.HandleFunc(fmt.Sprintf("/%s/%s/%s", page.SubPath, user.name, name), user.outbox.CollectionHTTP(name)).Methods("GET")
+			if !s.config.Server.AcceptAll {
+				route.HeadersRegexp("Accept", "application/.*json")
+			}
+		}
+
+		// Plain json for the blog, so no Accept filter
+		s.router.HandleFunc(fmt.Sprintf("/%s/%s/replies", page.SubPath, user.name), user.outbox.EngagementHTTP).Methods("GET")
+		s.router.HandleFunc(fmt.Sprintf("/profile/%s/replies", user.name), user.outbox.RepliesHTTP).Methods("GET")
+
+	}
+
+	if s.config.Server.Admin.enabled() && s.config.Server.Admin.Listen == "" {
+		s.addAdminHandlers(s.router)
 	}
 
-	// TODO: robots.txt
+	site := s.siteData()
+	for _, pg := range s.extraPages {
+		s.addPageHandler(page.NewStaticPage(pg), site)
+	}
 }
 
 func (s *ActivityService) addPageHandler(pg page.StaticPageHandler, meta any) {
@@ -102,12 +179,28 @@ func (s *ActivityService) addPageHandler(pg page.StaticPageHandler, meta any) {
 // Start running the ActivityPub service and return immediately
 func (s *ActivityService) Start(ctx context.Context) {
 	go s.pipeline.Run(ctx)
+	go s.inboxQueue.Run(ctx)
+	if s.notifier != nil {
+		go s.notifier.Run(ctx)
+	}
+	if s.webhooks != nil {
+		go s.webhooks.Run(ctx)
+	}
 	go func() {
 		err := s.ListenAndServe(ctx)
 		if err != nil && err != http.ErrServerClosed {
 			telemetry.Error(err, "while listening")
 		}
 	}()
+	if s.admin != nil {
+		go func() {
+			telemetry.Log("admin listener starting on %s", s.admin.Addr)
+			err := s.admin.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				telemetry.Error(err, "while listening for admin requests")
+			}
+		}()
+	}
 }
 
 // Stop anything related to the service before exiting
@@ -115,26 +208,75 @@ func (s *ActivityService) Stop(ctx context.Context) {
 	if err := s.server.Shutdown(ctx); err != nil {
 		telemetry.Error(err, "while shutting down server")
 	}
-	for i := range s.users {
-		s.users[i].store.Close()
+	if s.admin != nil {
+		if err := s.admin.Shutdown(ctx); err != nil {
+			telemetry.Error(err, "while shutting down admin server")
+		}
+	}
+	users := s.currentUsers()
+	for i := range users {
+		users[i].store.Close()
+	}
+	if s.store != nil {
+		s.store.Close()
 	}
 	telemetry.LogCounters()
 }
 
+// findUser returns the local user with the given name, or nil
+func (s *ActivityService) findUser(name string) *ActivityUser {
+	users := s.currentUsers()
+	for i := range users {
+		if users[i].name == name {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+// currentConfig returns the running config
+func (s *ActivityService) currentConfig() Config {
+	s.state.RLock()
+	defer s.state.RUnlock()
+	return s.config
+}
+
+// currentUsers returns the running users.
+// A reload replaces the list rather than changing it, so it can be used after a reload.
+func (s *ActivityService) currentUsers() []ActivityUser {
+	s.state.RLock()
+	defer s.state.RUnlock()
+	return s.users
+}
+
+// SendNow runs the output pipeline until everything queued by send has been sent.
+// For commands that are run without starting the service.
+func (s *ActivityService) SendNow(ctx context.Context, send func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.pipeline.Run(ctx)
+	send()
+	s.pipeline.Flush()
+}
+
 // ListenAndServe; listen for http requests and serve responses
 func (s *ActivityService) ListenAndServe(ctx context.Context) error {
 	// Spawn RSS feed watcher goroutines
 	if s.pipeline == nil {
 		panic("ActivityService doesn't have a Pipeline")
 	}
+	s.reloading.Lock()
+	s.runCtx = ctx
 	for i := range s.users {
-		go s.users[i].outbox.WatchRSS(ctx)
+		s.watchFeed(&s.users[i])
 	}
-	if s.config.Server.useTLS() {
-		telemetry.Log("tls listener starting on port %d", s.config.Server.Port)
-		return s.server.ListenAndServeTLS(s.config.Server.Certificate, s.config.Server.PrivateKey)
+	cfg := s.config.Server
+	s.reloading.Unlock()
+	if cfg.useTLS() {
+		telemetry.Log("tls listener starting on port %d", cfg.port())
+		return s.server.ListenAndServeTLS(cfg.Certificate, cfg.PrivateKey)
 	} else {
-		telemetry.Log("http listener starting on port %d", s.config.Server.Port)
+		telemetry.Log("http listener starting on port %d", cfg.port())
 		return s.server.ListenAndServe()
 	}
 }
@@ -153,89 +295,14 @@ func (s *ActivityService) ActivityRequest(method string, url string, v any) (*ht
 	if err != nil {
 		return nil, fmt.Errorf("creating ActivityPub request: %w", err)
 	}
-	r.Header.Add("User-Agent", "Activitylace/0.1 (+https://github.com/tkrehbiel/activitylace)")
+	r.Header.Add("User-Agent", userAgent)
 	r.Header.Add("Accept", activity.ContentType)
 	r.Header.Add("Content-Type", activity.ContentType)
-	r.Header.Add("Host", s.config.PublicHost())
+	r.Header.Add("Host", s.currentConfig().PublicHost())
 	r.Header.Add("Date", time.Now().UTC().Format(http.TimeFormat))
 	return r, nil
 }
 
-// GetActor finds the remote endpoint for the actor ID, which is assumed to be a URL.
-// Blocks until we get a response or the context is cancelled or times out.
-// TODO: Include a context param.
-func (s *ActivityService) GetActor(id string) (*activity.Actor, error) {
-	item := s.actorCache.Get(id)
-	if item != nil && !item.Expired() {
-		telemetry.Trace("found actor %s in cache", id)
-		cached := item.Value()
-		return &cached, nil
-	}
-
-	// TODO: maybe support webfingering an acct:x@y resource too
-	// TODO: make this more asynchronous, and (optionally?) cache the results locally
-	// TODO: retry periodically?
-
-	r, err := s.ActivityRequest("GET", id, nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := s.client.Do(r)
-	if err != nil {
-		return nil, err
-	}
-	var actor activity.Actor
-	decoder := json.NewDecoder(resp.Body)
-	if err := decoder.Decode(&actor); err != nil {
-		telemetry.Error(err, "decoding json body")
-		return nil, err
-	}
-
-	s.actorCache.Set(id, actor, 10*time.Minute)
-
-	return &actor, nil
-}
-
-// GetActorPublicKey fetches the public key ID associated with the given actor URL.
-// Blocks until a result is returned.
-// TODO: Include a context param.
-func (s *ActivityService) GetActorPublicKey(id string) crypto.PublicKey {
-	// TODO: Cache this result!
-	url, err := url.Parse(id)
-	if err != nil {
-		telemetry.Error(err, "parsing public key ID [%s]", id)
-		return nil
-	}
-	url.Fragment = "" // remove the fragment
-
-	actor, err := s.GetActor(url.String())
-	if err != nil {
-		telemetry.Error(err, "fetching remote actor")
-		return nil
-	}
-
-	if actor.ID != url.String() {
-		telemetry.Error(err, "remote actor ID [%s] doesn't match [%s]", actor.ID, url.String())
-		return nil
-	}
-	if actor.PublicKey.ID != id {
-		telemetry.Error(err, "remote public key ID [%s] doesn't match [%s]", actor.PublicKey.ID, id)
-		return nil
-	}
-	pubKeyPem := actor.PublicKey.TransformedKey()
-	der, _ := pem.Decode([]byte(pubKeyPem))
-	if der == nil {
-		telemetry.Error(nil, "can't decode pem [%s]", pubKeyPem)
-		return nil
-	}
-	pubKey, err := x509.ParsePKIXPublicKey(der.Bytes)
-	if err != nil {
-		telemetry.Error(err, "parsing public key [%s]", pubKeyPem)
-		return nil
-	}
-	return pubKey
-}
-
 func decodePrivateKey(content []byte) (any, error) {
 	p, _ := pem.Decode(content)
 	if p == nil {
@@ -264,16 +331,29 @@ func decodePrivateKey(content []byte) (any, error) {
 func NewService(cfg Config) *ActivityService {
 	svc := ActivityService{
 		client: http.Client{
-			Timeout: time.Second * 15,
+			Timeout: cfg.Server.clientTimeout(),
 		},
 		config:     cfg,
-		router:     mux.NewRouter(),
 		users:      make([]ActivityUser, 0),
 		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		pages:      ccache.New(ccache.Configure[[]byte]()),
+		keys:       NewKeyStore(cfg.Server.KeyDir),
+		fetcher:    NewFetcher(cfg.Server.FetchAllow),
 	}
+	svc.finger = NewWebFinger(svc.fetcher)
 
 	svc.pipeline = NewPipeline()
-	svc.pipeline.client = svc.client
+	svc.inboxQueue = NewInboxQueue()
+	if cfg.Server.ProcessedRetentionDays > 0 {
+		svc.inboxQueue.retention = time.Duration(cfg.Server.ProcessedRetentionDays) * 24 * time.Hour
+	}
+	if cfg.Server.InboxRetentionDays > 0 {
+		svc.inboxQueue.inboxRetention = time.Duration(cfg.Server.InboxRetentionDays) * 24 * time.Hour
+	}
+	// Inboxes are wherever remote actors say they are, so deliveries get the same
+	// protection as fetches. Only the operator's own destinations may be private.
+	svc.pipeline.client = svc.fetcher.Client(cfg.Server.clientTimeout())
+	svc.pipeline.trusted = svc.client
 
 	u, err := url.Parse(cfg.URL)
 	if err != nil {
@@ -283,106 +363,168 @@ func NewService(cfg Config) *ActivityService {
 
 	svc.pipeline.host = u.Host
 
+	store := storage.NewDatabase(serviceDatabase)
+	if err := store.Open(); err != nil {
+		telemetry.Error(err, "opening sqlite database [%s]", serviceDatabase)
+	} else {
+		svc.store = store
+		svc.actors = store.(storage.Actors)
+		svc.reports = store.(storage.Reports)
+		svc.blocks = store.(storage.Blocks)
+		svc.webhooks = NewWebhooks(cfg.webhooks(), store.(storage.WebhookDeliveries))
+		svc.notifier = NewNotifier(cfg.Notify, store.(storage.Notifications), svc.webhooks)
+		svc.deliveries = store.(storage.Deliveries)
+	}
+
 	// metadata available to page templates
 	svc.meta = page.MetaData{
-		URL:      cfg.URL,
-		HostName: u.Hostname(),
+		URL:          cfg.URL,
+		HostName:     u.Hostname(),
+		HandleDomain: cfg.Server.HandleDomain,
 	}
 
 	// configure inboxes and outboxes
 	for _, usercfg := range cfg.Users {
-		serverUser := ActivityUser{
-			name: usercfg.Name,
-			meta: svc.meta.NewUserMetaData(usercfg.Name),
+		user, err := svc.newUser(cfg, usercfg, nil)
+		if err != nil {
+			telemetry.Error(err, "initializing user %s", usercfg.Name)
+			continue
 		}
+		svc.users = append(svc.users, user)
+		telemetry.Trace("user %s initialized", user.name)
+	}
 
-		umeta := &serverUser.meta
+	for i := range svc.users {
+		svc.inboxQueue.Add(&svc.users[i].inbox)
+	}
 
-		if usercfg.PrivKeyFile != "" {
-			der, err := os.ReadFile(usercfg.PrivKeyFile)
-			if err != nil {
-				telemetry.Error(err, "reading private key file [%s]", usercfg.PrivKeyFile)
-				continue
-			}
+	// configure web handlers
+	svc.loadTemplates()
+	svc.buildRouter()
 
-			key, err := decodePrivateKey(der)
-			if err != nil {
-				telemetry.Error(err, "decoding private key [%s]", usercfg.PrivKeyFile)
-				continue
-			}
-			serverUser.privKey = key
-		}
+	svc.server = http.Server{
+		Handler:      &svc,
+		Addr:         fmt.Sprintf(":%d", cfg.Server.port()),
+		WriteTimeout: cfg.Server.requestTimeout(),
+		ReadTimeout:  cfg.Server.requestTimeout(),
+		IdleTimeout:  time.Second * 60,
+	}
 
-		if usercfg.PubKeyFile != "" {
-			b, err := os.ReadFile(usercfg.PubKeyFile)
-			if err != nil {
-				telemetry.Error(err, "reading public key file [%s]", usercfg.PubKeyFile)
-				continue
-			}
-			umeta.UserPublicKey = string(b)
+	if cfg.Server.Admin.enabled() && cfg.Server.Admin.Listen != "" {
+		router := mux.NewRouter()
+		svc.addAdminHandlers(router)
+		router.Use(RequestLoggerMiddleware(router))
+		svc.admin = &http.Server{
+			Handler:      router,
+			Addr:         cfg.Server.Admin.Listen,
+			WriteTimeout: cfg.Server.requestTimeout(),
+			ReadTimeout:  cfg.Server.requestTimeout(),
+			IdleTimeout:  time.Second * 60,
 		}
+	}
 
-		dbName := fmt.Sprintf("user_%s.db", usercfg.Name)
-		store := storage.NewDatabase(dbName)
-		serverUser.store = store
+	telemetry.Trace("service initialized")
+	return &svc
+}
 
-		umeta.UserDisplayName = usercfg.DisplayName
-		umeta.UserType = "Person"
-		if usercfg.Type != "" {
-			umeta.UserType = usercfg.Type
-		}
+// newUser sets up a local user account from its config.
+// When reloading, the previous account's database is kept open and reused.
+func (s *ActivityService) newUser(cfg Config, usercfg userConfig, previous *ActivityUser) (ActivityUser, error) {
+	user := ActivityUser{
+		name: usercfg.Name,
+		meta: s.meta.NewUserMetaData(usercfg.Name),
+	}
 
-		serverUser.outbox = ActivityOutbox{
-			service:        &svc,
-			id:             path.Join(svc.meta.URL, fmt.Sprintf("%s/%s/outbox", page.SubPath, usercfg.Name)),
-			ownerID:        usercfg.Name,
-			rssURL:         usercfg.SourceURL,
-			notes:          store.(storage.Notes),
-			followers:      store.(storage.Followers),
-			pipeline:       svc.pipeline,
-			privKey:        serverUser.privKey,
-			pubKeyID:       umeta.UserPublicKeyID,
-			acceptUnsigned: cfg.Server.ReceiveUnsigned,
-			sendUnsigned:   cfg.Server.SendUnsigned,
-		}
+	umeta := &user.meta
 
-		serverUser.inbox = ActivityInbox{
-			service:        &svc,
-			id:             path.Join(svc.meta.URL, fmt.Sprintf("%s/%s/inbox", page.SubPath, usercfg.Name)),
-			ownerID:        serverUser.meta.UserID,
-			followers:      store.(storage.Followers),
-			pipeline:       svc.pipeline,
-			privKey:        serverUser.privKey,
-			pubKeyID:       umeta.UserPublicKeyID,
-			acceptUnsigned: cfg.Server.ReceiveUnsigned,
-			sendUnsigned:   cfg.Server.SendUnsigned,
+	key, pub, err := loadUserKeys(s.keys, usercfg)
+	if err != nil {
+		return user, fmt.Errorf("loading keys: %w", err)
+	}
+	user.privKey = key
+	umeta.UserPublicKey = pub
+
+	dbName := userDatabase(usercfg.Name)
+	feed := &feedState{status: FeedStatus{User: usercfg.Name, URL: usercfg.SourceURL}}
+	if previous != nil {
+		user.store = previous.store
+		feed = previous.outbox.feed
+		feed.update(func(status *FeedStatus) {
+			status.URL = usercfg.SourceURL
+		})
+	} else {
+		user.store = storage.NewDatabase(dbName)
+		if err := user.store.Open(); err != nil {
+			return user, fmt.Errorf("opening sqlite database [%s]: %w", dbName, err)
 		}
+	}
+	store := user.store
 
-		if err := serverUser.store.Open(); err != nil {
-			telemetry.Error(err, "opening sqlite database [%s]", dbName)
-		} else {
-			svc.users = append(svc.users, serverUser)
-		}
+	usercfg.profile(umeta)
+	umeta.UserType = "Person"
+	if usercfg.Type != "" {
+		umeta.UserType = usercfg.Type
+	}
+	movedTo, err := store.(storage.Settings).GetSetting(movedToSetting)
+	if err != nil {
+		telemetry.Error(err, "database error")
+	}
+	umeta.MovedTo = movedTo
+
+	user.outbox = ActivityOutbox{
+		service:        s,
+		id:             path.Join(s.meta.URL, fmt.Sprintf("%s/%s/outbox", page.SubPath, usercfg.Name)),
+		ownerID:        usercfg.Name,
+		actorID:        user.meta.UserID,
+		rssURL:         usercfg.SourceURL,
+		notes:          store.(storage.Notes),
+		replies:        store.(storage.Replies),
+		reactions:      store.(storage.Reactions),
+		followers:      store.(storage.Followers),
+		pipeline:       s.pipeline,
+		privKey:        user.privKey,
+		pubKeyID:       umeta.UserPublicKeyID,
+		acceptUnsigned: cfg.Server.ReceiveUnsigned,
+		sendUnsigned:   cfg.Server.SendUnsigned,
+		feed:           feed,
+	}
 
-		telemetry.Trace("user %s initialized", serverUser.name)
+	user.inbox = ActivityInbox{
+		service:        s,
+		id:             path.Join(s.meta.URL, fmt.Sprintf("%s/%s/inbox", page.SubPath, usercfg.Name)),
+		ownerID:        user.meta.UserID,
+		followers:      store.(storage.Followers),
+		notes:          store.(storage.Notes),
+		replies:        store.(storage.Replies),
+		reactions:      store.(storage.Reactions),
+		reports:        s.reports,
+		notifier:       s.notifier,
+		policy:         usercfg.Replies,
+		items:          store.(storage.InboxItems),
+		processed:      store.(storage.ProcessedActivities),
+		queue:          s.inboxQueue,
+		pipeline:       s.pipeline,
+		privKey:        user.privKey,
+		pubKeyID:       umeta.UserPublicKeyID,
+		acceptUnsigned: cfg.Server.ReceiveUnsigned,
+		sendUnsigned:   cfg.Server.SendUnsigned,
 	}
+	return user, nil
+}
 
-	// configure web handlers
-	svc.addHandlers()
+// buildRouter creates the routes for the current users and starts serving them
+func (s *ActivityService) buildRouter() {
+	s.router = mux.NewRouter()
+	s.addHandlers()
 
 	// Log all requests in the router without having to explicitly do so
-	svc.router.Use(RequestLoggerMiddleware(svc.router))
-
-	svc.server = http.Server{
-		Handler:      svc.router,
-		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
-		WriteTimeout: time.Second * 15,
-		ReadTimeout:  time.Second * 15,
-		IdleTimeout:  time.Second * 60,
-	}
+	s.router.Use(RequestLoggerMiddleware(s.router))
+	s.routes.Store(s.router)
+}
 
-	telemetry.Trace("service initialized")
-	return &svc
+// ServeHTTP routes a request with the current router
+func (s *ActivityService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.routes.Load().ServeHTTP(w, r)
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request) {
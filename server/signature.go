@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
@@ -10,9 +11,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/go-fed/httpsig"
+	"github.com/tkrehbiel/activitylace/server/activity"
 )
 
 // At first I tried to use github.com/go-fed/httpsig but I had trouble communicating with Mastodon.
@@ -94,16 +97,102 @@ func verify(cert publicKeyLoader, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	if r.Body != nil {
+		// Without a signed digest the body could be swapped for anything
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		r.Body = io.NopCloser(bytes.NewBuffer(body))
+		if len(body) > 0 {
+			if !isSigned(r, "digest") {
+				return fmt.Errorf("digest isn't signed")
+			}
+			if digest, found := sha256Digest(r); !found || digest != computeDigest(body) {
+				return fmt.Errorf("digest doesn't match body")
+			}
+		}
+	}
 	pubKeyId := verifier.KeyId()
-	pubKey := cert.GetActorPublicKey(pubKeyId)
+	pubKey := cert.GetActorPublicKey(r.Context(), pubKeyId)
 	if pubKey == nil {
 		return fmt.Errorf("no public key to verify request signature")
 	}
 	algo := httpsig.RSA_SHA256
-	// The verifier will verify the Digest in addition to the HTTP signature
 	return verifier.Verify(pubKey, algo)
 }
 
+// keyOwner is the actor whose key signed a request.
+// GetActorPublicKey only accepts keys served from the actor's own ID.
+func keyOwner(r *http.Request) string {
+	verifier, err := httpsig.NewVerifier(r)
+	if err != nil {
+		return ""
+	}
+	u, err := url.Parse(verifier.KeyId())
+	if err != nil {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// isSigned returns true if a header is part of a request's signature
+func isSigned(r *http.Request, header string) bool {
+	sig := r.Header.Get("Signature")
+	if sig == "" {
+		sig = strings.TrimPrefix(r.Header.Get("Authorization"), "Signature ")
+	}
+	for _, param := range strings.Split(sig, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if found && name == "headers" {
+			for _, h := range strings.Fields(strings.Trim(value, `"`)) {
+				if strings.EqualFold(h, header) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// sha256Digest finds the SHA-256 value in a request's Digest header
+func sha256Digest(r *http.Request) (string, bool) {
+	for _, digest := range strings.Split(r.Header.Get("Digest"), ",") {
+		algo, value, found := strings.Cut(strings.TrimSpace(digest), "=")
+		if found && strings.EqualFold(algo, "SHA-256") {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// checkSignature does the checks on a signed request that don't need the sender's public key:
+// the signature header must be well-formed, and the digest, if any, must match the body.
+func checkSignature(r *http.Request, body []byte) error {
+	if _, err := httpsig.NewVerifier(r); err != nil {
+		return err
+	}
+	if digest, found := sha256Digest(r); found && digest != computeDigest(body) {
+		return fmt.Errorf("digest doesn't match body")
+	}
+	return nil
+}
+
+// senderHost is the host of the key a request is signed with,
+// or of the activity's actor if it isn't signed
+func senderHost(r *http.Request, act activity.Activity) string {
+	id := parseID(act.Actor)
+	if verifier, err := httpsig.NewVerifier(r); err == nil {
+		id = verifier.KeyId()
+	}
+	u, err := url.Parse(id)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
 type publicKeyLoader interface {
-	GetActorPublicKey(id string) crypto.PublicKey
+	GetActorPublicKey(ctx context.Context, id string) crypto.PublicKey
 }
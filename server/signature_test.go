@@ -2,14 +2,17 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -21,13 +24,14 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tkrehbiel/activitylace/server/activity"
 	"github.com/tkrehbiel/activitylace/server/page"
+	"github.com/tkrehbiel/activitylace/server/storage"
 )
 
 type mockLoader struct {
 	mock.Mock
 }
 
-func (m *mockLoader) GetActorPublicKey(id string) crypto.PublicKey {
+func (m *mockLoader) GetActorPublicKey(ctx context.Context, id string) crypto.PublicKey {
 	args := m.Called(id)
 	if k, ok := args.Get(0).(crypto.PublicKey); ok {
 		return k
@@ -145,6 +149,7 @@ func TestSignAndVerify_External(t *testing.T) {
 
 	svc := ActivityService{
 		actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+		fetcher:    testFetcher(),
 	}
 
 	actorPage := page.ActorEndpoint
@@ -184,3 +189,65 @@ func TestSignAndVerify_External(t *testing.T) {
 
 	assert.NoError(t, verify(&svc, r))
 }
+
+func TestVerify_Digest(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	loader := &mockLoader{}
+	loader.On("GetActorPublicKey", "abc").Return(&privKey.PublicKey)
+
+	// body swapped after signing
+	r := httptest.NewRequest("POST", "http://127.0.0.1/path", bytes.NewBufferString("signed body"))
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	require.NoError(t, sign(privKey, "abc", r))
+	r.Body = io.NopCloser(bytes.NewBufferString("other body"))
+	assert.ErrorContains(t, verify(loader, r), "digest doesn't match")
+
+	// signed without a digest, so the body could be anything
+	r = httptest.NewRequest("POST", "http://127.0.0.1/path", nil)
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	require.NoError(t, sign(privKey, "abc", r))
+	body := []byte("unsigned body")
+	r.Body = io.NopCloser(bytes.NewBuffer(body))
+	r.Header.Set("Digest", "SHA-256="+computeDigest(body))
+	assert.ErrorContains(t, verify(loader, r), "digest isn't signed")
+}
+
+func TestInbox_Process_SignedByOther(t *testing.T) {
+	privBlock, _ := pem.Decode([]byte(testPrivateKey))
+	require.NotNil(t, privBlock)
+	privKey, err := x509.ParsePKCS8PrivateKey(privBlock.Bytes)
+	require.NoError(t, err)
+
+	staticPage := page.NewStaticPage(page.ActorEndpoint)
+	remoteActor := httptest.NewServer(staticPage)
+	defer remoteActor.Close()
+	staticPage.Init(page.UserMetaData{
+		UserID:          remoteActor.URL,
+		UserPublicKeyID: remoteActor.URL + "#main-key",
+		UserPublicKey:   testPublicKey,
+	})
+
+	// a correctly signed request carrying someone else's activity
+	body := `{"type":"Like","id":"https://other.example/likes/1","actor":"https://other.example/users/mallory","object":"https://blog/post"}`
+	r := httptest.NewRequest("POST", "http://blog/activity/test/inbox", bytes.NewBufferString(body))
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	r.Header.Set("Host", r.Host)
+	require.NoError(t, sign(privKey, remoteActor.URL+"#main-key", r))
+	headers, err := json.Marshal(r.Header)
+	require.NoError(t, err)
+
+	inbox := ActivityInbox{
+		service: &ActivityService{
+			actorCache: ccache.New(ccache.Configure[activity.Actor]()),
+			fetcher:    testFetcher(),
+		},
+		id:       "test",
+		pipeline: NewPipeline(),
+	}
+	item := storage.InboxItem{Method: r.Method, Host: r.Host, Path: r.URL.Path, Headers: string(headers), Body: body}
+	err = inbox.Process(context.Background(), &item)
+	assert.ErrorAs(t, err, &rejectedError{})
+	assert.ErrorContains(t, err, "signed by")
+	assert.Equal(t, storage.SignatureVerified, item.Verified)
+}
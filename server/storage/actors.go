@@ -1,18 +1,35 @@
 package storage
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Actor represents an ORM object for a _remote_ actor, not a local one
 type Actor struct {
 	ID          string
 	DisplayName string
 	Server      string
-	Source      string // json source
+	Inbox       string
+	SharedInbox string
+	PublicKeyID string
+	PublicKey   string
+	FetchedAt   time.Time // when the actor was last fetched from its server
+	Source      string    // json source
 }
 
 type Actors interface {
+	CountActors() (int64, error)
 	FindActor(id string) (*Actor, error)
 	SaveActor(a *Actor) error
+	DeleteActor(id string) error
+}
+
+func (s *sqliteDatabase) CountActors() (int64, error) {
+	var n int64
+	tx := s.db.Model(&Actor{}).Count(&n)
+	return n, tx.Error
 }
 
 func (s *sqliteDatabase) FindActor(id string) (*Actor, error) {
@@ -30,3 +47,8 @@ func (s *sqliteDatabase) SaveActor(a *Actor) error {
 	tx := s.db.Save(a)
 	return tx.Error
 }
+
+func (s *sqliteDatabase) DeleteActor(id string) error {
+	tx := s.db.Delete(&Actor{ID: id})
+	return tx.Error
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	BlockActor  = "actor"
+	BlockDomain = "domain"
+)
+
+// Block represents an ORM object for a remote actor or a whole domain we ignore
+type Block struct {
+	ID        string    `json:"id"`   // actor ID or domain name
+	Type      string    `json:"type"` // actor or domain
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Blocks interface {
+	GetBlocks() ([]Block, error)
+	SaveBlock(b *Block) error
+	DeleteBlock(id string) error
+	IsBlocked(actorID string, domain string) (bool, error)
+}
+
+func (s *sqliteDatabase) GetBlocks() (blocks []Block, err error) {
+	tx := s.db.Order("created_at").Find(&blocks)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return blocks, nil
+}
+
+func (s *sqliteDatabase) SaveBlock(b *Block) error {
+	tx := s.db.Save(b)
+	return tx.Error
+}
+
+func (s *sqliteDatabase) DeleteBlock(id string) error {
+	tx := s.db.Delete(&Block{ID: id})
+	return tx.Error
+}
+
+// IsBlocked returns true if either the actor or its domain is blocked
+func (s *sqliteDatabase) IsBlocked(actorID string, domain string) (bool, error) {
+	var count int64
+	tx := s.db.Model(&Block{}).Where("(id = ? AND type = ?) OR (id = ? AND type = ?)", actorID, BlockActor, domain, BlockDomain).Count(&count)
+	return count > 0, tx.Error
+}
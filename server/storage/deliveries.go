@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Delivery represents an ORM object for a note sent, or being sent, to a follower's inbox
+type Delivery struct {
+	ID         string    `json:"id"`
+	User       string    `json:"user"`       // name of the local user sending it
+	ObjectID   string    `json:"object"`     // id of the note
+	Target     string    `json:"target"`     // remote actor ID
+	Status     string    `json:"status"`     // pending, delivered or failed
+	Attempts   int       `json:"attempts"`   // how many times it has been sent
+	StatusCode int       `json:"statusCode"` // the last http status returned
+	LastError  string    `json:"lastError"`  // why the last attempt failed
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type Deliveries interface {
+	// GetDeliveries returns the newest deliveries first, optionally with the given statuses
+	GetDeliveries(statuses []string, limit int) ([]Delivery, error)
+	// GetObjectDeliveries returns every delivery of a note
+	GetObjectDeliveries(user string, objectID string) ([]Delivery, error)
+	FindDelivery(id string) (*Delivery, error)
+	SaveDelivery(d *Delivery) error
+}
+
+func (s *sqliteDatabase) GetDeliveries(statuses []string, limit int) (deliveries []Delivery, err error) {
+	tx := s.db.Order("created_at desc")
+	if len(statuses) > 0 {
+		tx = tx.Where("status IN ?", statuses)
+	}
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	tx = tx.Find(&deliveries)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return deliveries, nil
+}
+
+func (s *sqliteDatabase) GetObjectDeliveries(user string, objectID string) (deliveries []Delivery, err error) {
+	tx := s.db.Order("created_at").Where(&Delivery{User: user, ObjectID: objectID}).Find(&deliveries)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return deliveries, nil
+}
+
+func (s *sqliteDatabase) FindDelivery(id string) (*Delivery, error) {
+	var delivery Delivery
+	tx := s.db.First(&delivery, Delivery{ID: id})
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &delivery, nil
+}
+
+func (s *sqliteDatabase) SaveDelivery(d *Delivery) error {
+	tx := s.db.Save(d)
+	return tx.Error
+}
@@ -3,9 +3,9 @@ package storage
 import "gorm.io/gorm"
 
 type Follow struct {
-	ID            string
-	RequestID     string
-	RequestStatus string // pending or accepted
+	ID            string `json:"id"`
+	RequestID     string `json:"requestId"`
+	RequestStatus string `json:"status"` // pending or accepted
 }
 
 type Followers interface {
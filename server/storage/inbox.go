@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Inbox item processing states
+const (
+	InboxQueued    = "queued"    // waiting to be processed, possibly retried
+	InboxDone      = "done"      // processed successfully
+	InboxDuplicate = "duplicate" // already processed when it arrived
+	InboxRejected  = "rejected"  // processing failed permanently
+	InboxFailed    = "failed"    // gave up after too many attempts
+)
+
+// Signature verification results
+const (
+	SignatureUnchecked = ""         // not verified yet
+	SignatureSkipped   = "skipped"  // unsigned activities are accepted
+	SignatureVerified  = "verified" // signature was valid
+	SignatureFailed    = "failed"   // signature was invalid or the key couldn't be found
+)
+
+// InboxItem is an ORM object for an activity received by an inbox, exactly as it arrived,
+// along with how it was processed. Kept for auditing and reprocessing.
+type InboxItem struct {
+	ID          string
+	ActivityID  string
+	Type        string
+	Method      string
+	Host        string
+	Sender      string `gorm:"index"` // host that signed it, or of the actor if it isn't signed
+	Path        string
+	Headers     string // json-encoded http headers
+	Body        string
+	Verified    string // signature verification result
+	Status      string
+	Attempts    int
+	NextAttempt time.Time
+	Error       string // last processing error
+	ReceivedAt  time.Time
+	ProcessedAt time.Time
+}
+
+type InboxItems interface {
+	SaveInboxItem(item *InboxItem) error
+	DeleteInboxItem(id string) error
+	GetDueInboxItems(now time.Time) ([]InboxItem, error)
+	FindInboxItem(id string) (*InboxItem, error)
+	FindInboxItems(statuses []string, since time.Time) ([]InboxItem, error)
+	PurgeInboxItems(before time.Time) (int64, error)
+	CountUnverified(sender string) (int64, error)
+}
+
+func (s *sqliteDatabase) SaveInboxItem(item *InboxItem) error {
+	tx := s.db.Save(item)
+	return tx.Error
+}
+
+func (s *sqliteDatabase) DeleteInboxItem(id string) error {
+	tx := s.db.Delete(&InboxItem{ID: id})
+	if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+		return tx.Error
+	}
+	return nil
+}
+
+// GetDueInboxItems finds queued items that are ready to be (re)processed
+func (s *sqliteDatabase) GetDueInboxItems(now time.Time) (items []InboxItem, err error) {
+	tx := s.db.Where("status = ? AND next_attempt <= ?", InboxQueued, now).Order("received_at").Find(&items)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return items, nil
+}
+
+func (s *sqliteDatabase) FindInboxItem(id string) (*InboxItem, error) {
+	var item InboxItem
+	tx := s.db.First(&item, InboxItem{ID: id})
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &item, nil
+}
+
+// FindInboxItems finds items received since the given time, optionally with one of the given statuses
+func (s *sqliteDatabase) FindInboxItems(statuses []string, since time.Time) (items []InboxItem, err error) {
+	tx := s.db.Where("received_at >= ?", since)
+	if len(statuses) > 0 {
+		tx = tx.Where("status IN ?", statuses)
+	}
+	tx = tx.Order("received_at").Find(&items)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return items, nil
+}
+
+// PurgeInboxItems deletes items received before the given time that aren't waiting to be processed
+func (s *sqliteDatabase) PurgeInboxItems(before time.Time) (int64, error) {
+	tx := s.db.Where("received_at < ? AND status <> ?", before, InboxQueued).Delete(&InboxItem{})
+	if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
+
+// CountUnverified counts items from a sender waiting for their signature to be checked
+func (s *sqliteDatabase) CountUnverified(sender string) (int64, error) {
+	var count int64
+	tx := s.db.Model(&InboxItem{}).Where("sender = ? AND status = ? AND verified = ?", sender, InboxQueued, SignatureUnchecked).Count(&count)
+	return count, tx.Error
+}
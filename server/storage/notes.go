@@ -12,15 +12,22 @@ type Note struct {
 	Published time.Time `json:"published"`
 	Content   string    `json:"content"`
 	URL       string    `json:"url"`
-	Source    string    // json source
+	Source    string    `json:"-"` // json source
 }
 
 type Notes interface {
+	CountNotes() (int64, error)
 	GetLatestNotes(n int) ([]Note, error)
 	FindNote(id string) (*Note, error)
 	SaveNote(n *Note) error
 }
 
+func (s *sqliteDatabase) CountNotes() (int64, error) {
+	var n int64
+	tx := s.db.Model(&Note{}).Count(&n)
+	return n, tx.Error
+}
+
 func (s *sqliteDatabase) GetLatestNotes(n int) (notes []Note, err error) {
 	tx := s.db.Order("published desc").Limit(n).Find(&notes)
 	if tx.Error == gorm.ErrRecordNotFound {
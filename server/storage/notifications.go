@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	NotifyMention = "mention" // a public note mentioning the user
+	NotifyDirect  = "direct"  // a note addressed only to the user and maybe others
+	NotifyFollow  = "follow"
+	NotifyLike    = "like"
+	NotifyBoost   = "boost"
+	NotifyReport  = "report"
+)
+
+// Notification represents an ORM object for something the blog owner should know about
+type Notification struct {
+	ID        string    `json:"id" gorm:"primaryKey"`   // id of the activity that caused it
+	User      string    `json:"user" gorm:"primaryKey"` // local actor ID it concerns, one activity can reach several
+	Type      string    `json:"type"`                   // mention, direct, follow, like, boost or report
+	ActorID   string    `json:"actor"`                  // who did it
+	ObjectID  string    `json:"object"`                 // the note, or the local note it refers to
+	Content   string    `json:"content"`                // sanitized html content, if any
+	URL       string    `json:"url"`                    // human-readable link, if any
+	CreatedAt time.Time `json:"createdAt"`              // when it was received
+	Digested  bool      `json:"digested"`               // whether it was written to a digest
+}
+
+type Notifications interface {
+	// GetNotifications returns the newest notifications first, optionally of the given types
+	GetNotifications(since time.Time, types []string, limit int) ([]Notification, error)
+	GetUndigestedNotifications() ([]Notification, error)
+	SaveNotification(n *Notification) error
+	MarkNotificationsDigested(ids []string) error
+}
+
+func (s *sqliteDatabase) GetNotifications(since time.Time, types []string, limit int) (notifications []Notification, err error) {
+	tx := s.db.Order("created_at desc").Where("created_at > ?", since)
+	if len(types) > 0 {
+		tx = tx.Where("type IN ?", types)
+	}
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	tx = tx.Find(&notifications)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return notifications, nil
+}
+
+func (s *sqliteDatabase) GetUndigestedNotifications() (notifications []Notification, err error) {
+	tx := s.db.Order("created_at").Where("digested = ?", false).Find(&notifications)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return notifications, nil
+}
+
+func (s *sqliteDatabase) SaveNotification(n *Notification) error {
+	tx := s.db.Save(n)
+	return tx.Error
+}
+
+func (s *sqliteDatabase) MarkNotificationsDigested(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx := s.db.Model(&Notification{}).Where("id IN ?", ids).Update("digested", true)
+	return tx.Error
+}
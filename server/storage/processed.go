@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProcessedActivity is an ORM object recording the ID of an activity that was already handled,
+// so that redelivered activities aren't handled twice
+type ProcessedActivity struct {
+	ID          string
+	ProcessedAt time.Time
+}
+
+type ProcessedActivities interface {
+	IsProcessed(id string) (bool, error)
+	ClaimProcessed(id string) (bool, error)
+	ReleaseProcessed(id string) error
+	PurgeProcessed(before time.Time) (int64, error)
+}
+
+func (s *sqliteDatabase) IsProcessed(id string) (bool, error) {
+	var count int64
+	tx := s.db.Model(&ProcessedActivity{}).Where("id = ?", id).Count(&count)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return count > 0, nil
+}
+
+// ClaimProcessed records an activity as processed, returning false if it already was.
+// The insert fails on the primary key, so only one of two concurrent deliveries can claim it.
+func (s *sqliteDatabase) ClaimProcessed(id string) (bool, error) {
+	tx := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&ProcessedActivity{ID: id, ProcessedAt: time.Now().UTC()})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected == 1, nil
+}
+
+// ReleaseProcessed gives up a claim on an activity that couldn't be processed
+func (s *sqliteDatabase) ReleaseProcessed(id string) error {
+	tx := s.db.Delete(&ProcessedActivity{ID: id})
+	if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+		return tx.Error
+	}
+	return nil
+}
+
+// PurgeProcessed forgets activities processed before the given time
+func (s *sqliteDatabase) PurgeProcessed(before time.Time) (int64, error) {
+	tx := s.db.Where("processed_at < ?", before).Delete(&ProcessedActivity{})
+	if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+		return 0, tx.Error
+	}
+	return tx.RowsAffected, nil
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reaction represents an ORM object for a remote Like or Announce of one of our notes
+type Reaction struct {
+	ID        string // id of the Like or Announce activity
+	Type      string // Like or Announce
+	ObjectID  string `gorm:"index"` // ID of our note
+	ActorID   string
+	Published time.Time
+}
+
+type Reactions interface {
+	FindReaction(id string) (*Reaction, error)
+	SaveReaction(r *Reaction) error
+	DeleteReaction(id string) error
+	DeleteActorReactions(actorID string) (int64, error)
+	CountReactions(objectID string, reactionType string) (int, error)
+}
+
+func (s *sqliteDatabase) FindReaction(id string) (*Reaction, error) {
+	var reaction Reaction
+	tx := s.db.First(&reaction, Reaction{ID: id})
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &reaction, nil
+}
+
+func (s *sqliteDatabase) SaveReaction(r *Reaction) error {
+	tx := s.db.Save(r)
+	return tx.Error
+}
+
+func (s *sqliteDatabase) DeleteReaction(id string) error {
+	tx := s.db.Delete(&Reaction{ID: id})
+	return tx.Error
+}
+
+// DeleteActorReactions removes every reaction by an actor
+func (s *sqliteDatabase) DeleteActorReactions(actorID string) (int64, error) {
+	tx := s.db.Where(&Reaction{ActorID: actorID}).Delete(&Reaction{})
+	return tx.RowsAffected, tx.Error
+}
+
+// CountReactions counts the actors who reacted to an object, so an actor
+// who liked something twice is only counted once
+func (s *sqliteDatabase) CountReactions(objectID string, reactionType string) (int, error) {
+	var count int64
+	tx := s.db.Model(&Reaction{}).Where(&Reaction{ObjectID: objectID, Type: reactionType}).Distinct("actor_id").Count(&count)
+	return int(count), tx.Error
+}
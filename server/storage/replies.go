@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Moderation states of replies
+const (
+	ReplyVisible  = "visible"  // shown to everyone
+	ReplyPending  = "pending"  // waiting for approval
+	ReplyHidden   = "hidden"   // shown only to readers who choose to reveal it
+	ReplyRejected = "rejected" // never shown
+)
+
+// Reply represents an ORM object for a remote note replying to one of our notes
+type Reply struct {
+	ID         string    `json:"id"`
+	ActivityID string    `json:"-"`
+	InReplyTo  string    `json:"inReplyTo"` // ID of our note
+	ActorID    string    `json:"actor"`
+	Name       string    `json:"name,omitempty"`
+	Summary    string    `json:"summary,omitempty"` // content warning
+	Content    string    `json:"content"`           // sanitized html
+	Published  time.Time `json:"published"`
+	URL        string    `json:"url"`
+	Updated    time.Time `json:"updated,omitempty"`
+	Deleted    time.Time `json:"-"`     // tombstoned by its author
+	State      string    `json:"state"` // moderation state, visible if empty
+	Source     string    `json:"-"`     // json source, as received
+}
+
+// IsDeleted returns true if the reply was deleted by its author
+func (r Reply) IsDeleted() bool {
+	return !r.Deleted.IsZero()
+}
+
+// ModerationState returns the moderation state, replies stored before moderation are visible
+func (r Reply) ModerationState() string {
+	if r.State == "" {
+		return ReplyVisible
+	}
+	return r.State
+}
+
+type Replies interface {
+	GetReplies(inReplyTo string, states []string) ([]Reply, error)
+	FindReplies(states []string) ([]Reply, error)
+	CountActorReplies(actorID string, states []string) (int, error)
+	FindReply(id string) (*Reply, error)
+	SaveReply(r *Reply) error
+	DeleteActorReplies(actorID string) (int64, error)
+	SetActorRepliesState(actorID string, state string) (int64, error)
+	SetDomainRepliesState(domain string, state string) (int64, error)
+}
+
+// whereStates filters replies that haven't been deleted by moderation state, or any state if none are given
+func (s *sqliteDatabase) whereStates(states []string) *gorm.DB {
+	tx := s.db.Where("deleted IS NULL OR deleted = ?", time.Time{})
+	if len(states) > 0 {
+		for _, state := range states {
+			if state == ReplyVisible {
+				// stored before replies were moderated
+				states = append(states[:len(states):len(states)], "")
+				break
+			}
+		}
+		tx = tx.Where("COALESCE(state, ?) IN ?", "", states)
+	}
+	return tx
+}
+
+// GetReplies returns the replies to a note that haven't been deleted, with any of the given moderation states
+func (s *sqliteDatabase) GetReplies(inReplyTo string, states []string) (replies []Reply, err error) {
+	tx := s.whereStates(states).Where(&Reply{InReplyTo: inReplyTo}).Order("published").Find(&replies)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return replies, nil
+}
+
+// FindReplies returns replies to any note with any of the given moderation states, newest first
+func (s *sqliteDatabase) FindReplies(states []string) (replies []Reply, err error) {
+	tx := s.whereStates(states).Order("published desc").Find(&replies)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return replies, nil
+}
+
+// CountActorReplies counts an actor's replies with any of the given moderation states
+func (s *sqliteDatabase) CountActorReplies(actorID string, states []string) (int, error) {
+	var count int64
+	tx := s.whereStates(states).Model(&Reply{}).Where(&Reply{ActorID: actorID}).Count(&count)
+	return int(count), tx.Error
+}
+
+func (s *sqliteDatabase) FindReply(id string) (*Reply, error) {
+	var reply Reply
+	tx := s.db.First(&reply, Reply{ID: id})
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &reply, nil
+}
+
+func (s *sqliteDatabase) SaveReply(r *Reply) error {
+	tx := s.db.Save(r)
+	return tx.Error
+}
+
+// DeleteActorReplies removes every reply by an actor
+func (s *sqliteDatabase) DeleteActorReplies(actorID string) (int64, error) {
+	tx := s.db.Where(&Reply{ActorID: actorID}).Delete(&Reply{})
+	return tx.RowsAffected, tx.Error
+}
+
+// SetActorRepliesState changes the moderation state of every reply by an actor
+func (s *sqliteDatabase) SetActorRepliesState(actorID string, state string) (int64, error) {
+	tx := s.db.Model(&Reply{}).Where(&Reply{ActorID: actorID}).Update("state", state)
+	return tx.RowsAffected, tx.Error
+}
+
+// SetDomainRepliesState changes the moderation state of every reply by actors on a domain, on any port
+func (s *sqliteDatabase) SetDomainRepliesState(domain string, state string) (int64, error) {
+	domain = likeEscaper.Replace(domain)
+	var patterns []any
+	for _, scheme := range []string{"https://", "http://"} {
+		patterns = append(patterns, scheme+domain+"/%", scheme+domain+":%")
+	}
+	where := strings.TrimSuffix(strings.Repeat(`actor_id LIKE ? ESCAPE '\' OR `, len(patterns)), " OR ")
+	tx := s.db.Model(&Reply{}).Where(where, patterns...).Update("state", state)
+	return tx.RowsAffected, tx.Error
+}
+
+// likeEscaper makes wildcards in a LIKE pattern match themselves
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportResolved  = "resolved"
+)
+
+// Report represents an ORM object for a Flag sent by a remote moderator
+type Report struct {
+	ID         string    `json:"id"`         // id of the Flag activity
+	User       string    `json:"user"`       // local user whose inbox received it
+	ActorID    string    `json:"actor"`      // who reported it
+	Content    string    `json:"content"`    // the reporter's comment
+	Objects    string    `json:"objects"`    // json list of reported actor and object IDs
+	Status     string    `json:"status"`     // open, dismissed or resolved
+	Action     string    `json:"action"`     // what was done about it
+	ReceivedAt time.Time `json:"receivedAt"` // when it was received
+	ResolvedAt time.Time `json:"resolvedAt"` // when something was done about it
+	Source     string    `json:"-"`          // json source
+}
+
+type Reports interface {
+	GetReports(statuses []string) ([]Report, error)
+	FindReport(id string) (*Report, error)
+	SaveReport(r *Report) error
+}
+
+// GetReports returns reports with any of the given statuses, or all reports if none are given
+func (s *sqliteDatabase) GetReports(statuses []string) (reports []Report, err error) {
+	tx := s.db.Order("received_at")
+	if len(statuses) > 0 {
+		tx = tx.Where("status IN ?", statuses)
+	}
+	tx = tx.Find(&reports)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return reports, nil
+}
+
+func (s *sqliteDatabase) FindReport(id string) (*Report, error) {
+	var report Report
+	tx := s.db.First(&report, Report{ID: id})
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &report, nil
+}
+
+func (s *sqliteDatabase) SaveReport(r *Report) error {
+	tx := s.db.Save(r)
+	return tx.Error
+}
@@ -0,0 +1,31 @@
+package storage
+
+import "gorm.io/gorm"
+
+// Setting represents an ORM object for a value changed at runtime rather than in config
+type Setting struct {
+	Name  string `gorm:"primaryKey"`
+	Value string
+}
+
+type Settings interface {
+	GetSetting(name string) (string, error)
+	SaveSetting(name string, value string) error
+}
+
+// GetSetting returns the value of a setting, or an empty string if it was never set
+func (s *sqliteDatabase) GetSetting(name string) (string, error) {
+	var setting Setting
+	tx := s.db.First(&setting, Setting{Name: name})
+	if tx.Error == gorm.ErrRecordNotFound {
+		return "", nil
+	} else if tx.Error != nil {
+		return "", tx.Error
+	}
+	return setting.Value, nil
+}
+
+func (s *sqliteDatabase) SaveSetting(name string, value string) error {
+	tx := s.db.Save(&Setting{Name: name, Value: value})
+	return tx.Error
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -22,6 +23,16 @@ type sqliteDatabase struct {
 	Actors
 	Notes
 	Followers
+	InboxItems
+	ProcessedActivities
+	Replies
+	Reactions
+	Settings
+	Reports
+	Blocks
+	Notifications
+	WebhookDeliveries
+	Deliveries
 	connection string
 	db         *gorm.DB
 	sqldb      *sql.DB
@@ -53,19 +64,26 @@ func (s *sqliteDatabase) Open() error {
 	if err != nil {
 		return err
 	}
+	// sqlite only allows one writer, and background goroutines share the service database
+	s.sqldb.SetMaxOpenConns(1)
 	s.db = db
 	// create tables
-	s.db.Migrator().AutoMigrate(&Actor{})
-	s.db.Migrator().AutoMigrate(&Note{})
-	s.db.Migrator().AutoMigrate(&Follow{})
+	for _, model := range []any{
+		&Actor{}, &Note{}, &Follow{}, &InboxItem{}, &ProcessedActivity{}, &Reply{}, &Reaction{},
+		&Setting{}, &Report{}, &Block{}, &Notification{}, &WebhookDelivery{}, &Delivery{},
+	} {
+		if err := s.db.Migrator().AutoMigrate(model); err != nil {
+			return fmt.Errorf("migrating %T: %w", model, err)
+		}
+	}
 	return nil
 }
 
+// Close the database. Later queries return errors rather than panicking,
+// since work started before a reload removed a user may still be finishing.
 func (s *sqliteDatabase) Close() {
-	if s.db != nil {
+	if s.sqldb != nil {
 		s.sqldb.Close()
-		s.sqldb = nil
-		s.db = nil
 	}
 }
 
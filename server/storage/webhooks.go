@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// WebhookDelivery represents an ORM object for an event posted, or to be posted, to a webhook
+type WebhookDelivery struct {
+	ID          string    `json:"id"`          // unique id, also sent to the webhook
+	Webhook     string    `json:"webhook"`     // url of the webhook
+	Event       string    `json:"event"`       // type of event
+	Payload     string    `json:"payload"`     // json body posted
+	Status      string    `json:"status"`      // pending, delivered or failed
+	Attempts    int       `json:"attempts"`    // how many times it has been posted
+	StatusCode  int       `json:"statusCode"`  // the last http status returned
+	LastError   string    `json:"lastError"`   // why the last attempt failed
+	CreatedAt   time.Time `json:"createdAt"`   // when the event happened
+	NextAttempt time.Time `json:"nextAttempt"` // when a pending delivery should be tried
+}
+
+type WebhookDeliveries interface {
+	// GetWebhookDeliveries returns the newest deliveries first, optionally with the given statuses
+	GetWebhookDeliveries(statuses []string, limit int) ([]WebhookDelivery, error)
+	FindWebhookDelivery(id string) (*WebhookDelivery, error)
+	SaveWebhookDelivery(d *WebhookDelivery) error
+}
+
+func (s *sqliteDatabase) GetWebhookDeliveries(statuses []string, limit int) (deliveries []WebhookDelivery, err error) {
+	tx := s.db.Order("created_at desc")
+	if len(statuses) > 0 {
+		tx = tx.Where("status IN ?", statuses)
+	}
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	tx = tx.Find(&deliveries)
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return deliveries, nil
+}
+
+func (s *sqliteDatabase) FindWebhookDelivery(id string) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	tx := s.db.First(&delivery, WebhookDelivery{ID: id})
+	if tx.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &delivery, nil
+}
+
+func (s *sqliteDatabase) SaveWebhookDelivery(d *WebhookDelivery) error {
+	tx := s.db.Save(d)
+	return tx.Error
+}
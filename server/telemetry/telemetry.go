@@ -135,6 +135,17 @@ func GetCounter(name string) int {
 	return data.counters[name]
 }
 
+// Counters returns a copy of all counters, thread-safe
+func Counters() map[string]int {
+	data.counterLock.Lock()
+	defer data.counterLock.Unlock()
+	counters := make(map[string]int, len(data.counters))
+	for k, v := range data.counters {
+		counters[k] = v
+	}
+	return counters
+}
+
 func LogCounters() {
 	s := make([]string, 0)
 	data.counterLock.Lock()
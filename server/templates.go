@@ -0,0 +1,76 @@
+package server
+
+import (
+	"html/template"
+	"os"
+
+	"github.com/tkrehbiel/activitylace/server/page"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// loadTemplates reads the template files and extra pages named in the config.
+// Built-in templates are kept for files that can't be used; Validate reports why.
+func (s *ActivityService) loadTemplates() {
+	templates, err := page.ReadTemplates(s.config.Server.TemplateDir)
+	if err != nil {
+		telemetry.Error(err, "reading templates in [%s]", s.config.Server.TemplateDir)
+	}
+	if filename := s.config.Server.ProfileTemplate; filename != "" {
+		if b, err := os.ReadFile(filename); err != nil {
+			telemetry.Error(err, "reading profile template [%s]", filename)
+		} else {
+			templates[page.ProfileTemplateName] = string(b)
+		}
+	}
+	for name, source := range templates {
+		if err := page.CheckTemplate(name, source); err != nil {
+			telemetry.Error(err, "using the built-in %s instead", name)
+			delete(templates, name)
+		}
+	}
+	s.templates = templates
+
+	profile := page.ProfileTemplate
+	if source, ok := templates[page.ProfileTemplateName]; ok {
+		profile = source
+	}
+	s.profileTemplate = template.Must(template.New("profile").Parse(profile))
+	s.pages.Clear()
+
+	s.extraPages = nil
+	for _, pagecfg := range s.config.Pages {
+		filename := pagecfg.file(s.config.Server.TemplateDir)
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			telemetry.Error(err, "reading page [%s]", filename)
+			continue
+		}
+		if err := page.CheckPage(filename, string(b)); err != nil {
+			telemetry.Error(err, "leaving out page %s", pagecfg.Path)
+			continue
+		}
+		s.extraPages = append(s.extraPages, page.StaticPage{
+			Path:        pagecfg.Path,
+			Accept:      "*/*",
+			ContentType: pagecfg.contentType(),
+			Template:    string(b),
+		})
+	}
+}
+
+// override returns a built-in page with its template replaced by a template file, if there is one
+func (s *ActivityService) override(pg page.StaticPage) page.StaticPage {
+	if source, ok := s.templates[pg.Name]; ok {
+		pg.Template = source
+	}
+	return pg
+}
+
+// siteData is the data for extra pages
+func (s *ActivityService) siteData() page.SiteData {
+	data := page.SiteData{MetaData: s.meta}
+	for i := range s.users {
+		data.Users = append(data.Users, s.users[i].meta)
+	}
+	return data
+}
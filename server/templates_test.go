@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates_Override(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "actor.json"), []byte(`{"id": "{{ .UserID }}", "name": {{ json .UserDisplayName }}}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "profile.html"), []byte(`<h1>{{ .UserDisplayName }}</h1>`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "robots.txt"), []byte("User-agent: *\nSitemap: {{ .URL }}/sitemap.xml\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "about.html"), []byte(`{{ range .Users }}<p>{{ .UserDisplayName }}</p>{{ end }}`), 0600))
+
+	cfg := reloadConfig(reloadUser("alice", "Alice"))
+	cfg.Server.TemplateDir = dir
+	cfg.Server.AcceptAll = true
+	cfg.Pages = []pageConfig{
+		{Path: "/robots.txt", File: "robots.txt"},
+		{Path: "/about", File: "about.html"},
+	}
+	require.Empty(t, cfg.Validate())
+	svc := reloadService(t, cfg)
+
+	get := func(target string, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		svc.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code, target)
+		return w
+	}
+	assert.JSONEq(t, `{"id": "https://local/activity/alice", "name": "Alice"}`, get("/activity/alice", "application/activity+json").Body.String())
+	assert.Equal(t, "<h1>Alice</h1>", get("/profile/alice", "text/html").Body.String())
+	w := get("/robots.txt", "*/*")
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "User-agent: *\nSitemap: https://local/sitemap.xml", w.Body.String())
+	w = get("/about", "text/html")
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<p>Alice</p>", w.Body.String())
+}
+
+func TestTemplates_Validate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "webfinger.json"), []byte(`{"subject": {{ .UserName }}}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "page.txt"), []byte(`{{ .Nope }}`), 0600))
+
+	cfg := reloadConfig(reloadUser("alice", "Alice"))
+	cfg.Server.TemplateDir = dir
+	cfg.Pages = []pageConfig{
+		{Path: "robots.txt", File: "missing.txt"},
+		{Path: "/.well-known/nodeinfo", File: "page.txt"},
+		{Path: "/profile/alice/more", File: "page.txt"},
+	}
+	var problems []string
+	for _, err := range cfg.Validate() {
+		problems = append(problems, err.Error())
+	}
+	require.Len(t, problems, 7)
+	assert.Contains(t, problems[0], "server.template_dir: webfinger.json doesn't render valid json")
+	assert.Equal(t, "pages[0].path [robots.txt] must start with /", problems[1])
+	assert.Equal(t, "pages[0].file ["+filepath.Join(dir, "missing.txt")+"] can't be read: no such file or directory", problems[2])
+	assert.Equal(t, "pages[1].path [/.well-known/nodeinfo] is already served by activitylace", problems[3])
+	assert.Contains(t, problems[4], "pages[1]: page.txt")
+	assert.Equal(t, "pages[2].path [/profile/alice/more] is already served by activitylace", problems[5])
+	assert.Contains(t, problems[6], "pages[2]: page.txt")
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/karlseguin/ccache/v3"
+	"github.com/tkrehbiel/activitylace/server/activity"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+const handleCacheTime = 24 * time.Hour
+
+// WebFinger resolves acct: handles like @user@host to ActivityPub actor IDs
+type WebFinger struct {
+	fetcher *Fetcher
+	scheme  string // https except when testing
+	cache   *ccache.Cache[string]
+}
+
+func NewWebFinger(fetcher *Fetcher) *WebFinger {
+	return &WebFinger{
+		fetcher: fetcher,
+		scheme:  "https",
+		cache:   ccache.New(ccache.Configure[string]()),
+	}
+}
+
+type webFingerLink struct {
+	Rel      string `json:"rel"`
+	Type     string `json:"type,omitempty"`
+	Href     string `json:"href,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+type webFingerResponse struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []webFingerLink `json:"links"`
+}
+
+// errBadTarget means something given to an admin command is neither an actor nor a domain
+var errBadTarget = errors.New("not an actor id, handle or domain")
+
+// resolveActor returns the actor ID for an actor ID or a handle such as @user@host
+func (s *ActivityService) resolveActor(ctx context.Context, target string) (string, error) {
+	target = strings.TrimSpace(target)
+	switch {
+	case absoluteURL(target):
+		return target, nil
+	case IsHandle(target):
+		return s.finger.Resolve(ctx, target)
+	}
+	return "", fmt.Errorf("[%s]: %w", target, errBadTarget)
+}
+
+// handleRegex matches user@host with an optional leading @ or acct: prefix
+var handleRegex = regexp.MustCompile(`^(?:acct:|@)?([^@\s/]+)@([^@\s/]+)$`)
+
+// mentionRegex finds @user@host handles within some text
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@/])@([\w.-]+)@([\w-]+(?:\.[\w-]+)+)`)
+
+// ParseHandle splits a handle such as @user@host, user@host or acct:user@host into its parts
+func ParseHandle(handle string) (user string, host string, err error) {
+	matches := handleRegex.FindStringSubmatch(strings.TrimSpace(handle))
+	if matches == nil {
+		return "", "", fmt.Errorf("not a valid handle [%s]", handle)
+	}
+	return matches[1], strings.ToLower(matches[2]), nil
+}
+
+// IsHandle returns true if the string looks like a handle rather than a URL
+func IsHandle(s string) bool {
+	_, _, err := ParseHandle(s)
+	return err == nil
+}
+
+// ParseMentions finds all the @user@host handles mentioned in some text
+func ParseMentions(text string) []string {
+	handles := make([]string, 0)
+	for _, m := range mentionRegex.FindAllStringSubmatch(text, -1) {
+		handles = append(handles, fmt.Sprintf("%s@%s", m[1], strings.ToLower(m[2])))
+	}
+	return handles
+}
+
+// Resolve finds the actor ID for a handle.
+// Tries /.well-known/webfinger first, then falls back to the host-meta LRDD template.
+func (f *WebFinger) Resolve(ctx context.Context, handle string) (string, error) {
+	user, host, err := ParseHandle(handle)
+	if err != nil {
+		return "", err
+	}
+	resource := fmt.Sprintf("acct:%s@%s", user, host)
+
+	item := f.cache.Get(resource)
+	if item != nil && !item.Expired() {
+		return item.Value(), nil
+	}
+
+	telemetry.Increment("webfinger_lookups", 1)
+	endpoint := fmt.Sprintf("%s://%s/.well-known/webfinger?resource=%s", f.scheme, host, url.QueryEscape(resource))
+	jrd, err := f.lookup(ctx, endpoint)
+	if err != nil {
+		telemetry.Trace("webfinger failed for %s, trying host-meta: %s", resource, err)
+		template, hmErr := f.lrddTemplate(ctx, host)
+		if hmErr != nil {
+			return "", fmt.Errorf("resolving %s: %w", resource, err)
+		}
+		jrd, err = f.lookup(ctx, strings.ReplaceAll(template, "{uri}", url.QueryEscape(resource)))
+		if err != nil {
+			return "", fmt.Errorf("resolving %s: %w", resource, err)
+		}
+	}
+
+	for _, link := range jrd.Links {
+		if link.Rel == "self" && link.Href != "" && isActivityType(link.Type) {
+			f.cache.Set(resource, link.Href, handleCacheTime)
+			return link.Href, nil
+		}
+	}
+	return "", fmt.Errorf("no actor link for %s", resource)
+}
+
+// ResolveMentions resolves every handle mentioned in some text.
+// Handles that can't be resolved are left out.
+func (f *WebFinger) ResolveMentions(ctx context.Context, text string) map[string]string {
+	ids := make(map[string]string)
+	for _, handle := range ParseMentions(text) {
+		id, err := f.Resolve(ctx, handle)
+		if err != nil {
+			telemetry.Error(err, "resolving mention [%s]", handle)
+			continue
+		}
+		ids[handle] = id
+	}
+	return ids
+}
+
+func (f *WebFinger) lookup(ctx context.Context, endpoint string) (*webFingerResponse, error) {
+	body, err := f.fetcher.Get(ctx, endpoint, webfingerContentTypes)
+	if err != nil {
+		return nil, err
+	}
+	var jrd webFingerResponse
+	if err := json.Unmarshal(body, &jrd); err != nil {
+		return nil, fmt.Errorf("decoding webfinger response: %w", err)
+	}
+	return &jrd, nil
+}
+
+// lrddTemplate finds the webfinger template advertised in a host's host-meta document
+func (f *WebFinger) lrddTemplate(ctx context.Context, host string) (string, error) {
+	endpoint := fmt.Sprintf("%s://%s/.well-known/host-meta", f.scheme, host)
+	body, err := f.fetcher.Get(ctx, endpoint, hostmetaContentTypes)
+	if err != nil {
+		return "", err
+	}
+	var xrd struct {
+		Links []struct {
+			Rel      string `xml:"rel,attr"`
+			Template string `xml:"template,attr"`
+		} `xml:"Link"`
+	}
+	if err := xml.Unmarshal(body, &xrd); err != nil {
+		return "", fmt.Errorf("decoding host-meta: %w", err)
+	}
+	for _, link := range xrd.Links {
+		if link.Rel == "lrdd" && link.Template != "" {
+			return link.Template, nil
+		}
+	}
+	return "", fmt.Errorf("no lrdd template in [%s]", endpoint)
+}
+
+// isActivityType is true for the content types used for ActivityPub actors
+func isActivityType(contentType string) bool {
+	return contentType == activity.ContentType || strings.HasPrefix(contentType, "application/ld+json")
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHandle(t *testing.T) {
+	for _, handle := range []string{"@alice@Example.com", "alice@example.com", "acct:alice@example.com"} {
+		user, host, err := ParseHandle(handle)
+		require.NoError(t, err, handle)
+		assert.Equal(t, "alice", user)
+		assert.Equal(t, "example.com", host)
+	}
+	for _, bad := range []string{"https://example.com/users/alice", "alice", "@alice", "a@b@c"} {
+		assert.False(t, IsHandle(bad), bad)
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	mentions := ParseMentions("hey @alice@example.com and @bob@other.social, not me@email.com or @local")
+	assert.Equal(t, []string{"alice@example.com", "bob@other.social"}, mentions)
+}
+
+func TestWebFinger_Resolve(t *testing.T) {
+	lookups := 0
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		assert.Equal(t, "/.well-known/webfinger", r.URL.Path)
+		subject := r.URL.Query().Get("resource")
+		w.Header().Set("Content-Type", "application/jrd+json")
+		fmt.Fprintf(w, `{"subject":%q,"links":[
+			{"rel":"http://webfinger.net/rel/profile-page","type":"text/html","href":"https://x/@alice"},
+			{"rel":"self","type":"application/activity+json","href":"https://x/users/alice"}]}`, subject)
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+
+	finger := NewWebFinger(testFetcher())
+	finger.scheme = "http"
+
+	id, err := finger.Resolve(context.Background(), "@alice@"+u.Host)
+	require.NoError(t, err)
+	assert.Equal(t, "https://x/users/alice", id)
+
+	// second lookup should be cached
+	id, err = finger.Resolve(context.Background(), "acct:alice@"+u.Host)
+	require.NoError(t, err)
+	assert.Equal(t, "https://x/users/alice", id)
+	assert.Equal(t, 1, lookups)
+}
+
+func TestWebFinger_HostMetaFallback(t *testing.T) {
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/host-meta":
+			w.Header().Set("Content-Type", "application/xrd+xml")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">
+	<Link rel="lrdd" template="%s/wf?resource={uri}"/>
+</XRD>`, remote.URL)
+		case "/wf":
+			assert.Contains(t, r.URL.Query().Get("resource"), "acct:bob@")
+			w.Header().Set("Content-Type", "application/jrd+json")
+			fmt.Fprint(w, `{"links":[{"rel":"self","type":"application/ld+json; profile=\"https://www.w3.org/ns/activitystreams\"","href":"https://y/bob"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+
+	finger := NewWebFinger(testFetcher())
+	finger.scheme = "http"
+
+	id, err := finger.Resolve(context.Background(), "bob@"+u.Host)
+	require.NoError(t, err)
+	assert.Equal(t, "https://y/bob", id)
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tkrehbiel/activitylace/server/storage"
+	"github.com/tkrehbiel/activitylace/server/telemetry"
+)
+
+// Events posted to webhooks
+const (
+	EventFollow         = "follow"
+	EventUnfollow       = "unfollow"
+	EventReply          = "reply"
+	EventReplyDeleted   = "reply.deleted"
+	EventLike           = "like"
+	EventBoost          = "boost"
+	EventDeliveryFailed = "delivery.failed"
+	EventPublished      = "note.published"
+	EventNotification   = "notification" // data is the notification
+)
+
+// webhookAttempts is how many times an event is posted before giving up
+const webhookAttempts = 5
+
+// Headers sent with each webhook post
+const (
+	webhookEventHeader     = "X-Activitylace-Event"
+	webhookDeliveryHeader  = "X-Activitylace-Delivery"
+	webhookSignatureHeader = "X-Activitylace-Signature" // sha256=<hex hmac of the body>
+)
+
+type webhookConfig struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`           // key for the HMAC-SHA256 signature of each payload
+	Events []string `json:"events,omitempty"` // events posted, all if empty
+}
+
+// wants returns true if the webhook should be told about the event
+func (c webhookConfig) wants(event string) bool {
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is the json body posted to webhooks
+type WebhookPayload struct {
+	ID        string    `json:"id"`    // delivery id
+	Event     string    `json:"event"` // what happened
+	User      string    `json:"user"`  // local actor ID it happened to
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"` // details of the event
+}
+
+// Webhooks posts events to configured webhooks.
+// They have their own output pipeline so they never hold up ActivityPub deliveries.
+type Webhooks struct {
+	lock     sync.Mutex
+	config   []webhookConfig
+	store    storage.WebhookDeliveries
+	pipeline *OutputPipeline
+	backoff  time.Duration // delay before the first retry, doubled for each one after
+	running  atomic.Bool
+}
+
+func NewWebhooks(cfg []webhookConfig, store storage.WebhookDeliveries) *Webhooks {
+	wh := &Webhooks{
+		store:    store,
+		pipeline: NewPipeline(),
+		backoff:  time.Minute,
+	}
+	wh.setConfig(cfg)
+	return wh
+}
+
+// setConfig replaces the configured webhooks, skipping any without a url
+func (wh *Webhooks) setConfig(cfg []webhookConfig) {
+	hooks := make([]webhookConfig, 0, len(cfg))
+	for _, hook := range cfg {
+		if hook.URL != "" {
+			hooks = append(hooks, hook)
+		}
+	}
+	wh.lock.Lock()
+	defer wh.lock.Unlock()
+	wh.config = hooks
+}
+
+// hooks returns the configured webhooks
+func (wh *Webhooks) hooks() []webhookConfig {
+	wh.lock.Lock()
+	defer wh.lock.Unlock()
+	return wh.config
+}
+
+// Emit records an event for each interested webhook and queues it for posting.
+// Events emitted while the service isn't running are posted when it starts.
+func (wh *Webhooks) Emit(event string, user string, data any) {
+	if wh == nil {
+		return
+	}
+	for _, hook := range wh.hooks() {
+		if !hook.wants(event) {
+			continue
+		}
+		payload := WebhookPayload{
+			ID:        uuid.NewString(),
+			Event:     event,
+			User:      user,
+			CreatedAt: time.Now().UTC(),
+			Data:      data,
+		}
+		body, err := json.Marshal(&payload)
+		if err != nil {
+			telemetry.Error(err, "marshaling %s webhook payload", event)
+			continue
+		}
+		delivery := storage.WebhookDelivery{
+			ID:          payload.ID,
+			Webhook:     hook.URL,
+			Event:       event,
+			Payload:     string(body),
+			Status:      storage.WebhookPending,
+			CreatedAt:   payload.CreatedAt,
+			NextAttempt: payload.CreatedAt,
+		}
+		if err := wh.store.SaveWebhookDelivery(&delivery); err != nil {
+			telemetry.Error(err, "database error")
+			continue
+		}
+		telemetry.Increment("webhook_events", 1)
+		wh.queue(delivery)
+	}
+}
+
+// queue posts a delivery when it is due, if the pipeline is running
+func (wh *Webhooks) queue(delivery storage.WebhookDelivery) {
+	if !wh.running.Load() {
+		return
+	}
+	handler := &webhookDelivery{hooks: wh, delivery: delivery}
+	if wait := time.Until(delivery.NextAttempt); wait > 0 {
+		time.AfterFunc(wait, func() {
+			if wh.running.Load() {
+				wh.pipeline.Queue(handler)
+			}
+		})
+		return
+	}
+	go wh.pipeline.Queue(handler)
+}
+
+// Run posts events until the context is done, starting with any left pending from before
+func (wh *Webhooks) Run(ctx context.Context) {
+	wh.running.Store(true)
+	defer wh.running.Store(false)
+
+	pending, err := wh.store.GetWebhookDeliveries([]string{storage.WebhookPending}, 0)
+	if err != nil {
+		telemetry.Error(err, "database error")
+	}
+	for _, delivery := range pending {
+		wh.queue(delivery)
+	}
+	wh.pipeline.Run(ctx)
+}
+
+// Deliveries returns the webhook log, newest first
+func (wh *Webhooks) Deliveries(statuses []string, limit int) ([]storage.WebhookDelivery, error) {
+	return wh.store.GetWebhookDeliveries(statuses, limit)
+}
+
+// Retry queues a failed delivery to be posted again
+func (wh *Webhooks) Retry(id string) error {
+	delivery, err := wh.store.FindWebhookDelivery(id)
+	if err != nil {
+		return fmt.Errorf("finding webhook delivery: %w", err)
+	}
+	if delivery == nil {
+		return fmt.Errorf("no webhook delivery [%s]", id)
+	}
+	delivery.Status = storage.WebhookPending
+	delivery.Attempts = 0
+	delivery.NextAttempt = time.Now().UTC()
+	if err := wh.store.SaveWebhookDelivery(delivery); err != nil {
+		return fmt.Errorf("saving webhook delivery: %w", err)
+	}
+	wh.queue(*delivery)
+	return nil
+}
+
+// find returns the configuration of a webhook, or nil if it's no longer configured
+func (wh *Webhooks) find(url string) *webhookConfig {
+	hooks := wh.hooks()
+	for i := range hooks {
+		if hooks[i].URL == url {
+			return &hooks[i]
+		}
+	}
+	return nil
+}
+
+// signWebhook returns the signature header value of a payload
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// emit tells webhooks about something that happened at this inbox
+func (ai *ActivityInbox) emit(event string, data any) {
+	if ai.service != nil {
+		ai.service.webhooks.Emit(event, ai.ownerID, data)
+	}
+}
+
+// emit tells webhooks about something that happened at this outbox
+func (ao *ActivityOutbox) emit(event string, data any) {
+	if ao.service != nil {
+		ao.service.webhooks.Emit(event, ao.actorID, data)
+	}
+}
+
+// webhookDelivery is a QueueHandler posting one event to one webhook
+type webhookDelivery struct {
+	hooks    *Webhooks
+	delivery storage.WebhookDelivery
+}
+
+func (d *webhookDelivery) String() string {
+	return fmt.Sprintf("Webhook %s to %s", d.delivery.Event, d.delivery.Webhook)
+}
+
+// Trusted because webhook urls come from the config, and may well be on a private network
+func (d *webhookDelivery) Trusted() bool {
+	return true
+}
+
+func (d *webhookDelivery) Prepare(ctx context.Context, pipeline *OutputPipeline) (*http.Request, error) {
+	hook := d.hooks.find(d.delivery.Webhook)
+	if hook == nil {
+		return nil, fmt.Errorf("webhook [%s] is no longer configured", d.delivery.Webhook)
+	}
+	body := []byte(d.delivery.Payload)
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating webhook request: %w", err)
+	}
+	r.Header.Add("User-Agent", userAgent)
+	r.Header.Add("Content-Type", "application/json")
+	r.Header.Add(webhookEventHeader, d.delivery.Event)
+	r.Header.Add(webhookDeliveryHeader, d.delivery.ID)
+	if hook.Secret != "" {
+		r.Header.Add(webhookSignatureHeader, signWebhook(hook.Secret, body))
+	}
+	d.delivery.Attempts++
+	telemetry.Increment("webhooks_sent", 1)
+	return r, nil
+}
+
+func (d *webhookDelivery) Receive(resp *http.Response) {
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	d.delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		telemetry.Increment("webhooks_succeeded", 1)
+		d.delivery.Status = storage.WebhookDelivered
+		d.delivery.LastError = ""
+		if err := d.hooks.store.SaveWebhookDelivery(&d.delivery); err != nil {
+			telemetry.Error(err, "database error")
+		}
+		return
+	}
+	d.Fail(fmt.Errorf("webhook returned status %d", resp.StatusCode))
+}
+
+// Fail records a failed attempt and schedules a retry, if there are any left
+func (d *webhookDelivery) Fail(err error) {
+	telemetry.Increment("webhooks_failed", 1)
+	d.delivery.LastError = err.Error()
+	if d.delivery.Attempts == 0 || d.delivery.Attempts >= webhookAttempts || d.hooks.find(d.delivery.Webhook) == nil {
+		d.delivery.Status = storage.WebhookFailed
+	} else {
+		d.delivery.Status = storage.WebhookPending
+		d.delivery.NextAttempt = time.Now().UTC().Add(d.hooks.backoff << (d.delivery.Attempts - 1))
+	}
+	if err := d.hooks.store.SaveWebhookDelivery(&d.delivery); err != nil {
+		telemetry.Error(err, "database error")
+	}
+	if d.delivery.Status == storage.WebhookPending {
+		d.hooks.queue(d.delivery)
+	}
+}
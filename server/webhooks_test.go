@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrehbiel/activitylace/server/storage"
+)
+
+func testWebhooks(t *testing.T, cfg []webhookConfig) *Webhooks {
+	store := storage.NewDatabase(filepath.Join(t.TempDir(), "service.db"))
+	require.NoError(t, store.Open())
+	t.Cleanup(store.Close)
+	wh := NewWebhooks(cfg, store.(storage.WebhookDeliveries))
+	wh.backoff = 10 * time.Millisecond
+	return wh
+}
+
+// waitForDeliveries waits until no deliveries are pending
+func waitForDeliveries(t *testing.T, wh *Webhooks) []storage.WebhookDelivery {
+	var deliveries []storage.WebhookDelivery
+	require.Eventually(t, func() bool {
+		pending, err := wh.Deliveries([]string{storage.WebhookPending}, 0)
+		require.NoError(t, err)
+		if len(pending) > 0 {
+			return false
+		}
+		deliveries, err = wh.Deliveries(nil, 0)
+		require.NoError(t, err)
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	return deliveries
+}
+
+func TestWebhooks_Emit(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, signWebhook("secret", body), r.Header.Get(webhookSignatureHeader))
+		assert.Equal(t, EventFollow, r.Header.Get(webhookEventHeader))
+		var payload WebhookPayload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, r.Header.Get(webhookDeliveryHeader), payload.ID)
+		assert.Equal(t, "https://blog/activity/test", payload.User)
+		assert.Equal(t, map[string]interface{}{"actor": "https://remote/users/alice"}, payload.Data)
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	wh := testWebhooks(t, []webhookConfig{
+		{URL: server.URL, Secret: "secret", Events: []string{EventFollow}},
+		{URL: server.URL + "/replies", Events: []string{EventReply}},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// emitted before running, so it waits in the log
+	wh.Emit(EventFollow, "https://blog/activity/test", map[string]string{"actor": "https://remote/users/alice"})
+	pending, err := wh.Deliveries([]string{storage.WebhookPending}, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	go wh.Run(ctx)
+	deliveries := waitForDeliveries(t, wh)
+	require.Len(t, deliveries, 1, "only the interested webhook gets the event")
+	assert.Equal(t, storage.WebhookDelivered, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+	assert.Equal(t, int32(1), received.Load())
+}
+
+func TestWebhooks_Retry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	wh := testWebhooks(t, []webhookConfig{{URL: server.URL}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go wh.Run(ctx)
+	require.Eventually(t, wh.running.Load, time.Second, time.Millisecond)
+
+	wh.Emit(EventLike, "https://blog/activity/test", nil)
+	deliveries := waitForDeliveries(t, wh)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, storage.WebhookDelivered, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Empty(t, deliveries[0].LastError)
+}
+
+func TestWebhooks_GiveUp(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	wh := testWebhooks(t, []webhookConfig{{URL: server.URL}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go wh.Run(ctx)
+	require.Eventually(t, wh.running.Load, time.Second, time.Millisecond)
+
+	wh.Emit(EventBoost, "https://blog/activity/test", nil)
+	deliveries := waitForDeliveries(t, wh)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, storage.WebhookFailed, deliveries[0].Status)
+	assert.Equal(t, webhookAttempts, deliveries[0].Attempts)
+	assert.Equal(t, "webhook returned status 500", deliveries[0].LastError)
+	assert.Equal(t, int32(webhookAttempts), calls.Load())
+
+	// a manual retry starts over
+	require.NoError(t, wh.Retry(deliveries[0].ID))
+	waitForDeliveries(t, wh)
+	assert.Equal(t, int32(2*webhookAttempts), calls.Load())
+}